@@ -1,6 +1,10 @@
 package main
 
-import "backend-service/internal/app"
+import (
+	"backend-service/internal/app"
+	// the runtime image has no zoneinfo, location time zones come from here
+	_ "time/tzdata"
+)
 
 func main() {
 	app.Run()
@@ -7,7 +7,10 @@ import (
 	"backend-service/internal/storages"
 	"backend-service/pkg/database"
 	"backend-service/pkg/jwt"
+	"backend-service/pkg/notify"
 	"backend-service/pkg/s3"
+	"backend-service/pkg/totp"
+	"context"
 	"github.com/joho/godotenv"
 	"github.com/rs/zerolog"
 	"os"
@@ -30,6 +33,9 @@ func Run() {
 	}
 	// cfg
 	cfg := config.GetConfig()
+	if err := cfg.Booking.NoShowPolicy.Validate(); err != nil {
+		logger.Fatal().Err(err).Msg("Invalid BOOKING_NO_SHOW_POLICY")
+	}
 	logger.Info().Msg("Config: OK")
 	// postgres
 	pg, err := database.NewPostgresDB(cfg.Postgres.DBHost, cfg.Postgres.DBPort, cfg.Postgres.DBUser, cfg.Postgres.DBName, cfg.Postgres.DBPass, cfg.Postgres.DBSSLMode)
@@ -37,22 +43,87 @@ func Run() {
 		logger.Error().Msgf("Error connecting to PostgreSQL: %v", err)
 	}
 	logger.Info().Msg("Postgres: OK")
+	// redis holds token nonces and revocations, auth does not work without it
+	rdb, err := database.NewRedis(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Error connecting to Redis")
+	}
+	logger.Info().Msg("Redis: OK")
 	// storage
 	storage := storages.NewStorage(storages.StorageDeps{
 		PostgresDB: pg,
+		Redis:      rdb,
+		NonceTTL:   cfg.JWT.RefreshTokenTTL,
 		Log:        logger,
 	})
+	// totp secrets are encrypted at rest, without a key two-factor auth is off
+	var totpCipher *totp.SecretCipher
+	if cfg.TwoFactor.SecretKey == "" {
+		logger.Warn().Msg("TWO_FACTOR_SECRET_KEY is not set, two-factor authentication is unavailable")
+	} else if totpCipher, err = totp.NewSecretCipher(cfg.TwoFactor.SecretKey); err != nil {
+		logger.Fatal().Err(err).Msg("Invalid TWO_FACTOR_SECRET_KEY")
+	}
 	// services
 	service := services.NewService(services.ServiceDeps{
-		Log:     logger,
-		Storage: storage,
+		Log:         logger,
+		Storage:     storage,
+		EmailSender: notify.NewLogSender(logger, "email"),
+		SMSSender:   notify.NewLogSender(logger, "sms"),
+		Booking:     cfg.Booking,
+		TwoFactor:   cfg.TwoFactor,
+		TOTPCipher:  totpCipher,
+		SessionTTL:  cfg.JWT.RefreshTokenTTL,
 	})
+	// waitlist offers nobody answered free their slots for the next client
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			expired, err := service.AppointmentService.ExpireWaitlistOffers(context.Background())
+			if err != nil {
+				logger.Error().Err(err).Msg("Failed to expire waitlist offers")
+				continue
+			}
+			if expired > 0 {
+				logger.Info().Int("expired", expired).Msg("Waitlist offers expired")
+			}
+		}
+	}()
+	// scheduled appointments nobody arrived for become no-shows
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			marked, err := service.AppointmentService.MarkNoShows(context.Background())
+			if err != nil {
+				logger.Error().Err(err).Msg("Failed to mark no-shows")
+				continue
+			}
+			if marked > 0 {
+				logger.Info().Int("marked", marked).Msg("Appointments marked as no-show")
+			}
+		}
+	}()
+	// jwt keys
+	keys, err := jwt.LoadKeyDir(cfg.JWT.KeysDir, cfg.JWT.ActiveKeyID)
+	if err != nil {
+		if !cfg.JWT.AllowEphemeralKey {
+			logger.Fatal().Err(err).Msg("Failed to load JWT keys")
+		}
+		logger.Warn().Err(err).Msg("Failed to load JWT keys, using an ephemeral key: tokens will not survive a restart")
+		key, err := jwt.GenerateEd25519Key(cfg.JWT.ActiveKeyID)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to generate JWT key")
+		}
+		keys, _ = jwt.NewKeySet(key.ID, key)
+	}
 	// jwt service
 	jwtService := jwt.New(jwt.Config{
-		SecretKey:       cfg.AppSecretKey,
-		AccessTokenTTL:  time.Hour * 24 * 7,
-		RefreshTokenTTL: 0,
-	}, nil)
+		Keys:                  keys,
+		AccessTokenTTL:        cfg.JWT.AccessTokenTTL,
+		RefreshTokenTTL:       cfg.JWT.RefreshTokenTTL,
+		ImpersonationTokenTTL: cfg.JWT.ImpersonationTokenTTL,
+	}, storage.NonceStorage, storage.RevocationStorage)
 
 	// S3
 	s3Client, err := s3.New(cfg.S3.Endpoint, cfg.S3.AccessKey, cfg.S3.SecretKey, cfg.S3.Bucket, cfg.S3.Region, cfg.S3.UseSSL)
@@ -3,14 +3,90 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
-	AppPort      string
-	AppSecretKey string
-	Postgres     Postgres
-	S3           S3
+	AppPort   string
+	JWT       JWT
+	Booking   Booking
+	TwoFactor TwoFactor
+	Postgres  Postgres
+	Redis     Redis
+	S3        S3
+}
+
+type JWT struct {
+	// Каталог с PEM-ключами подписи, имя файла без .pem — kid
+	KeysDir     string
+	ActiveKeyID string
+	// Только для разработки: без ключей в KeysDir подписывать временным ключом,
+	// токены не переживают перезапуск
+	AllowEphemeralKey bool
+	AccessTokenTTL    time.Duration
+	RefreshTokenTTL   time.Duration
+	// Время жизни токена для входа администратора от имени клиента
+	ImpersonationTokenTTL time.Duration
+}
+
+// Booking содержит правила записи на обслуживание.
+type Booking struct {
+	// Запрещать запись, пока не подтвержден ни email, ни телефон
+	RequireVerifiedContact bool
+	// Шаг, с которым предлагаются свободные времена начала записи
+	SlotStep time.Duration
+	// Назначать механика при записи и предлагать только время, когда есть свободный механик
+	AssignMechanics bool
+	// За сколько до начала запись уже нельзя перенести
+	RescheduleCutoff time.Duration
+	// Сколько клиент из листа ожидания может думать над предложенным временем
+	WaitlistOfferTTL time.Duration
+	// Адрес администратора мастерской для уведомлений о действиях клиентов, пустой — не уведомлять
+	StaffNotifyEmail string
+	// Через сколько после начала запись, на которую клиент не пришел, отмечается неявкой
+	NoShowGrace time.Duration
+	// Что делать с клиентом, у которого NoShowLimit неявок и больше
+	NoShowPolicy NoShowPolicy
+	NoShowLimit  int
+	// До какого момента перед началом запись отменяется бесплатно
+	CancelFreeBefore time.Duration
+	// Позже этого момента клиент не может отменить запись сам
+	CancelCutoff time.Duration
+	// Плата за позднюю отмену в процентах от стоимости услуг записи
+	CancelFeePercent int
+}
+
+// NoShowPolicy — ограничение записи для клиентов, которые не приходят.
+type NoShowPolicy string
+
+const (
+	// Неявки только считаются
+	NoShowPolicyNone NoShowPolicy = "none"
+	// Новые записи клиента требуют предоплаты
+	NoShowPolicyPrepayment NoShowPolicy = "prepayment"
+	// Клиент не может записаться сам, пока сотрудник не снимет блокировку
+	NoShowPolicyBlock NoShowPolicy = "block"
+)
+
+func (p NoShowPolicy) Validate() error {
+	switch p {
+	case NoShowPolicyNone, NoShowPolicyPrepayment, NoShowPolicyBlock:
+		return nil
+	}
+	return fmt.Errorf("invalid no-show policy %q, expected none, prepayment or block", p)
+}
+
+// TwoFactor содержит настройки двухфакторной аутентификации.
+type TwoFactor struct {
+	// Название сервиса, которое видно в приложении-аутентификаторе
+	Issuer string
+	// Роли, которым привилегированные действия доступны только после входа с TOTP
+	RequiredRoles []string
+	// Ключ AES-256 в hex для шифрования TOTP-секретов в базе. Без него
+	// двухфакторная аутентификация недоступна
+	SecretKey string
 }
 
 type Postgres struct {
@@ -22,6 +98,12 @@ type Postgres struct {
 	DBSSLMode string
 }
 
+type Redis struct {
+	Addr     string
+	Password string
+	DB       int
+}
+
 type S3 struct {
 	Endpoint  string
 	AccessKey string
@@ -52,10 +134,82 @@ func getEnvBool(key string, def bool) bool {
 	return val == "true" || val == "1"
 }
 
+// Для целых чисел
+func getEnvInt(key string, def int) int {
+	val := os.Getenv(key)
+	if val == "" {
+		fmt.Printf("%s environment variable is not set. Using default value: %d\n", key, def)
+		return def
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		fmt.Printf("%s environment variable is invalid. Using default value: %d\n", key, def)
+		return def
+	}
+	return n
+}
+
+// Для длительностей в формате time.ParseDuration (15m, 720h)
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	val := os.Getenv(key)
+	if val == "" {
+		fmt.Printf("%s environment variable is not set. Using default value: %s\n", key, def)
+		return def
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		fmt.Printf("%s environment variable is invalid. Using default value: %s\n", key, def)
+		return def
+	}
+	return d
+}
+
+// Для списков через запятую
+func getEnvList(key string, def []string) []string {
+	val := os.Getenv(key)
+	if val == "" {
+		fmt.Printf("%s environment variable is not set. Using default value: %s\n", key, strings.Join(def, ","))
+		return def
+	}
+	var list []string
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func GetConfig() Config {
 	return Config{
-		AppPort:      getEnv("APP_PORT", "8080"),
-		AppSecretKey: getEnv("APP_SECRET_KEY", "secret"),
+		AppPort: getEnv("APP_PORT", "8080"),
+		JWT: JWT{
+			KeysDir:               getEnv("JWT_KEYS_DIR", "./keys"),
+			ActiveKeyID:           getEnv("JWT_ACTIVE_KEY_ID", "default"),
+			AllowEphemeralKey:     getEnvBool("JWT_ALLOW_EPHEMERAL_KEY", false),
+			AccessTokenTTL:        getEnvDuration("JWT_ACCESS_TOKEN_TTL", 15*time.Minute),
+			RefreshTokenTTL:       getEnvDuration("JWT_REFRESH_TOKEN_TTL", 30*24*time.Hour),
+			ImpersonationTokenTTL: getEnvDuration("JWT_IMPERSONATION_TOKEN_TTL", 10*time.Minute),
+		},
+		Booking: Booking{
+			RequireVerifiedContact: getEnvBool("BOOKING_REQUIRE_VERIFIED_CONTACT", false),
+			SlotStep:               getEnvDuration("BOOKING_SLOT_STEP", 30*time.Minute),
+			AssignMechanics:        getEnvBool("BOOKING_ASSIGN_MECHANICS", true),
+			RescheduleCutoff:       getEnvDuration("BOOKING_RESCHEDULE_CUTOFF", 2*time.Hour),
+			WaitlistOfferTTL:       getEnvDuration("BOOKING_WAITLIST_OFFER_TTL", 30*time.Minute),
+			StaffNotifyEmail:       getEnv("BOOKING_STAFF_NOTIFY_EMAIL", ""),
+			NoShowGrace:            getEnvDuration("BOOKING_NO_SHOW_GRACE", 30*time.Minute),
+			NoShowPolicy:           NoShowPolicy(getEnv("BOOKING_NO_SHOW_POLICY", string(NoShowPolicyNone))),
+			NoShowLimit:            getEnvInt("BOOKING_NO_SHOW_LIMIT", 2),
+			CancelFreeBefore:       getEnvDuration("BOOKING_CANCEL_FREE_BEFORE", 24*time.Hour),
+			CancelCutoff:           getEnvDuration("BOOKING_CANCEL_CUTOFF", 2*time.Hour),
+			CancelFeePercent:       getEnvInt("BOOKING_CANCEL_FEE_PERCENT", 50),
+		},
+		TwoFactor: TwoFactor{
+			Issuer:        getEnv("TWO_FACTOR_ISSUER", "AutoMasterPro"),
+			RequiredRoles: getEnvList("TWO_FACTOR_REQUIRED_ROLES", []string{"admin", "manager"}),
+			SecretKey:     getEnv("TWO_FACTOR_SECRET_KEY", ""),
+		},
 		Postgres: Postgres{
 			DBHost:    getEnv("DB_HOST", "localhost"),
 			DBPort:    getEnv("DB_PORT", "5432"),
@@ -64,6 +218,11 @@ func GetConfig() Config {
 			DBPass:    getEnv("DB_PASS", "password"),
 			DBSSLMode: getEnv("DBSSL_MODE", "disable"),
 		},
+		Redis: Redis{
+			Addr:     getEnv("REDIS_ADDR", "localhost:6379"),
+			Password: getEnv("REDIS_PASS", ""),
+			DB:       getEnvInt("REDIS_DB", 0),
+		},
 		S3: S3{
 			Endpoint:  getEnv("S3_ENDPOINT", "localhost:9000"),
 			AccessKey: getEnv("S3_ACCESS_KEY", "minioadmin"),
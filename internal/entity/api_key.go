@@ -0,0 +1,58 @@
+package entity
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// APIKey — долгоживущий ключ доступа партнера или внутреннего сервиса.
+// Ключ не привязан к пользователю: его права ограничены списком Permissions.
+type APIKey struct {
+	ID          uuid.UUID    `json:"id"`
+	Name        string       `json:"name"`
+	Prefix      string       `json:"prefix"`
+	Permissions []Permission `json:"permissions"`
+	CreatedBy   *uuid.UUID   `json:"created_by,omitempty"`
+	CreatedAt   *time.Time   `json:"created_at,omitempty"`
+	ExpiresAt   *time.Time   `json:"expires_at,omitempty"`
+	LastUsedAt  *time.Time   `json:"last_used_at,omitempty"`
+	RevokedAt   *time.Time   `json:"revoked_at,omitempty"`
+}
+
+func (k *APIKey) HasPermission(permission Permission) bool {
+	for _, p := range k.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+type APIKeyCreate struct {
+	Name        string       `json:"name,omitempty"`
+	Permissions []Permission `json:"permissions,omitempty"`
+	ExpiresAt   *time.Time   `json:"expires_at,omitempty"`
+}
+
+func (e *APIKeyCreate) Validate() error {
+	if e.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	if len(e.Permissions) == 0 {
+		return fmt.Errorf("at least one permission is required")
+	}
+
+	for _, p := range e.Permissions {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if e.ExpiresAt != nil && !e.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("expires_at must be in the future")
+	}
+
+	return nil
+}
@@ -3,32 +3,88 @@ package entity
 import (
 	"fmt"
 	"github.com/google/uuid"
+	"strings"
 	"time"
 )
 
 type AppointmentStatus string
 
 const (
-	AppointmentStatusScheduled  AppointmentStatus = "scheduled"
-	AppointmentStatusCompleted  AppointmentStatus = "completed"
-	AppointmentStatusCancelled  AppointmentStatus = "cancelled"
-	AppointmentStatusInProgress AppointmentStatus = "in_progress"
+	// Время удержано под предложение из листа ожидания, пока клиент не ответит
+	AppointmentStatusReserved      AppointmentStatus = "reserved"
+	AppointmentStatusScheduled     AppointmentStatus = "scheduled"
+	AppointmentStatusCheckedIn     AppointmentStatus = "checked_in"
+	AppointmentStatusInProgress    AppointmentStatus = "in_progress"
+	AppointmentStatusAwaitingParts AppointmentStatus = "awaiting_parts"
+	AppointmentStatusReady         AppointmentStatus = "ready"
+	AppointmentStatusCompleted     AppointmentStatus = "completed"
+	AppointmentStatusCancelled     AppointmentStatus = "cancelled"
+	AppointmentStatusNoShow        AppointmentStatus = "no_show"
 )
 
+// appointmentTransitions описывает, в какие статусы можно перевести запись
+// из текущего. Завершенная, отмененная и неявка — конечные статусы.
+var appointmentTransitions = map[AppointmentStatus][]AppointmentStatus{
+	AppointmentStatusReserved:      {AppointmentStatusScheduled, AppointmentStatusCancelled},
+	AppointmentStatusScheduled:     {AppointmentStatusCheckedIn, AppointmentStatusCancelled, AppointmentStatusNoShow},
+	AppointmentStatusCheckedIn:     {AppointmentStatusInProgress, AppointmentStatusCancelled},
+	AppointmentStatusInProgress:    {AppointmentStatusAwaitingParts, AppointmentStatusReady},
+	AppointmentStatusAwaitingParts: {AppointmentStatusInProgress, AppointmentStatusCancelled},
+	AppointmentStatusReady:         {AppointmentStatusCompleted},
+	AppointmentStatusCompleted:     {},
+	AppointmentStatusCancelled:     {},
+	AppointmentStatusNoShow:        {},
+}
+
+func (s AppointmentStatus) Validate() error {
+	if _, ok := appointmentTransitions[s]; !ok {
+		return fmt.Errorf("invalid status: must be one of reserved, scheduled, checked_in, in_progress, awaiting_parts, ready, completed, cancelled or no_show")
+	}
+	return nil
+}
+
+// CanTransitionTo сообщает, разрешен ли переход из статуса s в next.
+func (s AppointmentStatus) CanTransitionTo(next AppointmentStatus) bool {
+	for _, allowed := range appointmentTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// ReleasesSlot сообщает, освобождает ли статус посты и механиков записи.
+func (s AppointmentStatus) ReleasesSlot() bool {
+	return s == AppointmentStatusCancelled || s == AppointmentStatusNoShow
+}
+
 type Appointment struct {
-	ID              uuid.UUID         `json:"id"`
-	UserID          uuid.UUID         `json:"user_id"`
-	VehicleID       uuid.UUID         `json:"vehicle_id"`
-	AppointmentTime time.Time         `json:"appointment_time"`
-	Status          AppointmentStatus `json:"status"`
-	Services        []*Service        `json:"services,omitempty"`
-	Attachments     []string          `json:"attachments"`
-	CreatedAt       *time.Time        `json:"created_at,omitempty"`
-	UpdatedAt       *time.Time        `json:"updated_at,omitempty"`
-	DeletedAt       *time.Time        `json:"deleted_at,omitempty"`
+	ID              uuid.UUID              `json:"id"`
+	UserID          uuid.UUID              `json:"user_id"`
+	LocationID      uuid.UUID              `json:"location_id"`
+	VehicleID       uuid.UUID              `json:"vehicle_id"`
+	AppointmentTime time.Time              `json:"appointment_time"`
+	EndsAt          time.Time              `json:"ends_at"`
+	Status          AppointmentStatus      `json:"status"`
+	Services        []*Service             `json:"services,omitempty"`
+	Resources       []*AppointmentResource `json:"resources,omitempty"`
+	Mechanics       []*AppointmentMechanic `json:"mechanics,omitempty"`
+	Attachments     []string               `json:"attachments"`
+	// Стоимость услуг по ценам на момент записи, читается только при получении одной записи
+	ServicesTotal float64 `json:"-"`
+	// Запись клиента с неявками, которую нужно оплатить заранее
+	PrepaymentRequired bool       `json:"prepayment_required"`
+	PrepaidAt          *time.Time `json:"prepaid_at,omitempty"`
+	// Заполняются при отмене записи
+	CancellationTerms *CancellationTerms `json:"cancellation_terms,omitempty"`
+	CancellationFee   *float64           `json:"cancellation_fee,omitempty"`
+	CreatedAt         *time.Time         `json:"created_at,omitempty"`
+	UpdatedAt         *time.Time         `json:"updated_at,omitempty"`
+	DeletedAt         *time.Time         `json:"deleted_at,omitempty"`
 }
 
 type AppointmentCreate struct {
+	LocationID      uuid.UUID   `json:"location_id"`
 	VehicleID       uuid.UUID   `json:"vehicle_id"`
 	AppointmentTime time.Time   `json:"appointment_time"`
 	ServiceIDs      []uuid.UUID `json:"service_ids"`
@@ -36,6 +92,10 @@ type AppointmentCreate struct {
 }
 
 func (a *AppointmentCreate) Validate() error {
+	if a.LocationID == uuid.Nil {
+		return fmt.Errorf("location_id is required")
+	}
+
 	if a.VehicleID == uuid.Nil {
 		return fmt.Errorf("vehicle_id is required")
 	}
@@ -49,17 +109,7 @@ func (a *AppointmentCreate) Validate() error {
 		return fmt.Errorf("appointment time must be in the future")
 	}
 
-	// Check if appointment is within business hours (assuming 9 AM to 6 PM)
-	hour := a.AppointmentTime.Hour()
-	if hour < 9 || hour >= 18 {
-		return fmt.Errorf("appointments must be scheduled between 9 AM and 6 PM")
-	}
-
-	// Check if it's a weekday
-	if a.AppointmentTime.Weekday() == time.Saturday || a.AppointmentTime.Weekday() == time.Sunday {
-		return fmt.Errorf("appointments can only be scheduled on weekdays")
-	}
-
+	// Business hours are checked by the service against the workshop calendar
 	if len(a.ServiceIDs) == 0 {
 		return fmt.Errorf("at least one service must be selected")
 	}
@@ -70,47 +120,41 @@ func (a *AppointmentCreate) Validate() error {
 func (a *AppointmentCreate) ToAppointment(userID uuid.UUID) *Appointment {
 	return &Appointment{
 		UserID:          userID,
+		LocationID:      a.LocationID,
 		VehicleID:       a.VehicleID,
 		AppointmentTime: a.AppointmentTime,
 		Status:          AppointmentStatusScheduled,
 	}
 }
 
+// AppointmentUpdate — изменение записи. Reason сохраняется в истории
+// статусов, если меняется статус.
 type AppointmentUpdate struct {
 	AppointmentTime *time.Time         `json:"appointment_time,omitempty"`
 	Status          *AppointmentStatus `json:"status,omitempty"`
+	Reason          *string            `json:"reason,omitempty"`
 	ServiceIDs      []uuid.UUID        `json:"service_ids,omitempty"`
 	Attachments     []string           `json:"attachments,omitempty"`
 }
 
 func (a *AppointmentUpdate) Validate() error {
 	if a.AppointmentTime != nil {
-		// Check if appointment time is in the future
-		//if a.AppointmentTime.Before(time.Now()) {
-		//	return fmt.Errorf("appointment time must be in the future")
-		//}
-
-		// Check if appointment is within business hours (assuming 9 AM to 6 PM)
-		hour := a.AppointmentTime.Hour()
-		if hour < 9 || hour >= 18 {
-			return fmt.Errorf("appointments must be scheduled between 9 AM and 6 PM")
-		}
-
-		// Check if it's a weekday
-		if a.AppointmentTime.Weekday() == time.Saturday || a.AppointmentTime.Weekday() == time.Sunday {
-			return fmt.Errorf("appointments can only be scheduled on weekdays")
-		}
+		return fmt.Errorf("appointment_time is changed by rescheduling the appointment")
 	}
 
 	if a.Status != nil {
-		switch *a.Status {
-		case AppointmentStatusScheduled, AppointmentStatusCompleted, AppointmentStatusCancelled, AppointmentStatusInProgress:
-			// Valid status
-		default:
-			return fmt.Errorf("invalid status: must be one of scheduled, completed, or cancelled")
+		if err := a.Status.Validate(); err != nil {
+			return err
+		}
+		if *a.Status == AppointmentStatusCancelled {
+			return fmt.Errorf("status cancelled is set by cancelling the appointment")
 		}
 	}
 
+	if a.Reason != nil && len(*a.Reason) > MaxStatusReasonLength {
+		return fmt.Errorf("reason must not exceed %d characters", MaxStatusReasonLength)
+	}
+
 	if len(a.ServiceIDs) > 0 {
 		// If services are being updated, ensure at least one is selected
 		if len(a.ServiceIDs) == 0 {
@@ -121,6 +165,101 @@ func (a *AppointmentUpdate) Validate() error {
 	return nil
 }
 
+// AppointmentCancel — отмена записи. Override отменяет запись вопреки
+// политике отмены и без платы; он доступен сотрудникам, а обоснование
+// передается в Reason.
+type AppointmentCancel struct {
+	Reason   *string `json:"reason,omitempty"`
+	Override bool    `json:"override,omitempty"`
+}
+
+func (a *AppointmentCancel) Validate() error {
+	if a.Reason != nil && len(*a.Reason) > MaxStatusReasonLength {
+		return fmt.Errorf("reason must not exceed %d characters", MaxStatusReasonLength)
+	}
+
+	if a.Override && (a.Reason == nil || strings.TrimSpace(*a.Reason) == "") {
+		return fmt.Errorf("reason is required to override the cancellation policy")
+	}
+
+	return nil
+}
+
+// CancellationTerms — на каких условиях отменена запись.
+type CancellationTerms string
+
+const (
+	CancellationTermsFree CancellationTerms = "free"
+	// Отмена позже бесплатного окна, начисляется плата
+	CancellationTermsLate CancellationTerms = "late"
+	// Сотрудник отменил запись вопреки политике отмены
+	CancellationTermsOverridden CancellationTerms = "overridden"
+)
+
+// AppointmentCancellation — итог отмены: условия, причина и начисленная плата.
+type AppointmentCancellation struct {
+	AppointmentID uuid.UUID         `json:"appointment_id"`
+	Status        AppointmentStatus `json:"status"`
+	Terms         CancellationTerms `json:"terms"`
+	Reason        *string           `json:"reason,omitempty"`
+	Fee           float64           `json:"fee"`
+}
+
+const MaxStatusReasonLength = 500
+
+// AppointmentReschedule — перенос записи на другое время.
+type AppointmentReschedule struct {
+	AppointmentTime time.Time `json:"appointment_time"`
+	Reason          *string   `json:"reason,omitempty"`
+}
+
+func (a *AppointmentReschedule) Validate() error {
+	if a.AppointmentTime.IsZero() {
+		return fmt.Errorf("appointment_time is required")
+	}
+
+	if a.AppointmentTime.Before(time.Now()) {
+		return fmt.Errorf("appointment time must be in the future")
+	}
+
+	if a.Reason != nil && len(*a.Reason) > MaxStatusReasonLength {
+		return fmt.Errorf("reason must not exceed %d characters", MaxStatusReasonLength)
+	}
+
+	return nil
+}
+
+// AppointmentTimeChange — запись в истории переносов: прежнее и новое время.
+type AppointmentTimeChange struct {
+	ID            uuid.UUID `json:"id"`
+	AppointmentID uuid.UUID `json:"appointment_id"`
+	FromTime      time.Time `json:"from_time"`
+	ToTime        time.Time `json:"to_time"`
+	Actor         Actor     `json:"actor"`
+	Reason        *string   `json:"reason,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Actor — кто выполнил действие: пользователь (при имперсонации —
+// администратор) или API-ключ. Пустой — сама система, например при
+// истечении предложения из листа ожидания.
+type Actor struct {
+	UserID   *uuid.UUID `json:"user_id,omitempty"`
+	APIKeyID *uuid.UUID `json:"api_key_id,omitempty"`
+}
+
+// AppointmentStatusChange — запись в истории статусов. FromStatus пуст
+// у первой записи, сделанной при создании.
+type AppointmentStatusChange struct {
+	ID            uuid.UUID          `json:"id"`
+	AppointmentID uuid.UUID          `json:"appointment_id"`
+	FromStatus    *AppointmentStatus `json:"from_status,omitempty"`
+	ToStatus      AppointmentStatus  `json:"to_status"`
+	Actor         Actor              `json:"actor"`
+	Reason        *string            `json:"reason,omitempty"`
+	CreatedAt     time.Time          `json:"created_at"`
+}
+
 type AppointmentResponse struct {
 	ID              uuid.UUID         `json:"id"`
 	Vehicle         *Vehicle          `json:"vehicle"`
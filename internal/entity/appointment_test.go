@@ -0,0 +1,45 @@
+package entity
+
+import "testing"
+
+func TestAppointmentStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from AppointmentStatus
+		to   AppointmentStatus
+		want bool
+	}{
+		{AppointmentStatusReserved, AppointmentStatusScheduled, true},
+		{AppointmentStatusReserved, AppointmentStatusCheckedIn, false},
+		{AppointmentStatusScheduled, AppointmentStatusCheckedIn, true},
+		{AppointmentStatusScheduled, AppointmentStatusNoShow, true},
+		{AppointmentStatusScheduled, AppointmentStatusInProgress, false},
+		{AppointmentStatusCheckedIn, AppointmentStatusInProgress, true},
+		{AppointmentStatusInProgress, AppointmentStatusAwaitingParts, true},
+		{AppointmentStatusInProgress, AppointmentStatusCancelled, false},
+		{AppointmentStatusAwaitingParts, AppointmentStatusInProgress, true},
+		{AppointmentStatusReady, AppointmentStatusCompleted, true},
+		{AppointmentStatusReady, AppointmentStatusScheduled, false},
+		{AppointmentStatusCompleted, AppointmentStatusScheduled, false},
+		{AppointmentStatusCancelled, AppointmentStatusScheduled, false},
+		{AppointmentStatusNoShow, AppointmentStatusCheckedIn, false},
+		{AppointmentStatus("unknown"), AppointmentStatusScheduled, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+				t.Errorf("CanTransitionTo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppointmentStatusFinal(t *testing.T) {
+	for _, status := range []AppointmentStatus{AppointmentStatusCompleted, AppointmentStatusCancelled, AppointmentStatusNoShow} {
+		for next := range appointmentTransitions {
+			if status.CanTransitionTo(next) {
+				t.Errorf("%s is final but can move to %s", status, next)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package entity
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// Максимальный диапазон поиска свободного времени
+const MaxAvailabilityRange = 31 * 24 * time.Hour
+
+// TimeRange — полуинтервал [Start, End).
+type TimeRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+func (r TimeRange) Overlaps(other TimeRange) bool {
+	return r.Start.Before(other.End) && other.Start.Before(r.End)
+}
+
+// In возвращает тот же интервал по часам пояса loc.
+func (r TimeRange) In(loc *time.Location) TimeRange {
+	return TimeRange{Start: r.Start.In(loc), End: r.End.In(loc)}
+}
+
+// AvailabilityQuery — параметры поиска свободного времени в филиале. From и
+// To — дни по календарю филиала, включительно.
+type AvailabilityQuery struct {
+	LocationID uuid.UUID
+	From       time.Time
+	To         time.Time
+	ServiceIDs []uuid.UUID
+}
+
+func (q *AvailabilityQuery) Validate() error {
+	if q.LocationID == uuid.Nil {
+		return fmt.Errorf("location_id is required")
+	}
+
+	if q.From.IsZero() {
+		return fmt.Errorf("from is required")
+	}
+
+	if q.To.IsZero() {
+		q.To = q.From
+	}
+
+	if q.To.Before(q.From) {
+		return fmt.Errorf("to must not be before from")
+	}
+
+	if q.To.Sub(q.From) >= MaxAvailabilityRange {
+		return fmt.Errorf("range must not exceed %d days", int(MaxAvailabilityRange.Hours()/24))
+	}
+
+	if len(q.ServiceIDs) == 0 {
+		return fmt.Errorf("at least one service must be selected")
+	}
+
+	return nil
+}
+
+// DayAvailability — свободные времена начала записи в один день.
+type DayAvailability struct {
+	Date  string      `json:"date"`
+	Slots []time.Time `json:"slots"`
+}
+
+type AvailabilityResponse struct {
+	DurationMin int                `json:"duration_min"`
+	Days        []*DayAvailability `json:"days"`
+}
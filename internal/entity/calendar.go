@@ -0,0 +1,238 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+// BusinessHours — часы работы в день недели, время в формате HH:MM.
+// Перерыв необязателен.
+type BusinessHours struct {
+	Weekday    time.Weekday `json:"weekday"`
+	OpenTime   string       `json:"open_time"`
+	CloseTime  string       `json:"close_time"`
+	BreakStart *string      `json:"break_start,omitempty"`
+	BreakEnd   *string      `json:"break_end,omitempty"`
+}
+
+func (h *BusinessHours) Validate() error {
+	if h.Weekday < time.Sunday || h.Weekday > time.Saturday {
+		return fmt.Errorf("weekday must be between 0 (Sunday) and 6 (Saturday)")
+	}
+
+	open, err := parseClock(h.OpenTime)
+	if err != nil {
+		return fmt.Errorf("invalid open_time: %w", err)
+	}
+	closing, err := parseClock(h.CloseTime)
+	if err != nil {
+		return fmt.Errorf("invalid close_time: %w", err)
+	}
+	if closing <= open {
+		return fmt.Errorf("close_time must be after open_time")
+	}
+
+	if (h.BreakStart == nil) != (h.BreakEnd == nil) {
+		return fmt.Errorf("break_start and break_end must be set together")
+	}
+	if h.BreakStart != nil {
+		breakStart, err := parseClock(*h.BreakStart)
+		if err != nil {
+			return fmt.Errorf("invalid break_start: %w", err)
+		}
+		breakEnd, err := parseClock(*h.BreakEnd)
+		if err != nil {
+			return fmt.Errorf("invalid break_end: %w", err)
+		}
+		if breakEnd <= breakStart || breakStart <= open || breakEnd >= closing {
+			return fmt.Errorf("break must lie inside working hours")
+		}
+	}
+
+	return nil
+}
+
+type WeeklyBusinessHours struct {
+	Days []BusinessHours `json:"days"`
+}
+
+func (e *WeeklyBusinessHours) Validate() error {
+	seen := make(map[time.Weekday]bool, len(e.Days))
+	for i := range e.Days {
+		if err := e.Days[i].Validate(); err != nil {
+			return err
+		}
+		if seen[e.Days[i].Weekday] {
+			return fmt.Errorf("weekday %d is listed twice", e.Days[i].Weekday)
+		}
+		seen[e.Days[i].Weekday] = true
+	}
+	return nil
+}
+
+type CalendarDayKind string
+
+const (
+	// Мастерская закрыта
+	CalendarDayHoliday CalendarDayKind = "holiday"
+	// Предпраздничный день: без указанного close_time закрывается на час раньше
+	CalendarDayShort CalendarDayKind = "short_day"
+	// Рабочий выходной: без указанных часов работает как в понедельник
+	CalendarDayWorking CalendarDayKind = "working_day"
+)
+
+const (
+	CalendarSourceManual = "manual"
+	CalendarSourceImport = "import"
+)
+
+// CalendarDay — отступление от недельного графика, дата в формате YYYY-MM-DD.
+type CalendarDay struct {
+	Date      string          `json:"date"`
+	Kind      CalendarDayKind `json:"kind"`
+	OpenTime  *string         `json:"open_time,omitempty"`
+	CloseTime *string         `json:"close_time,omitempty"`
+	Name      *string         `json:"name,omitempty"`
+	Source    string          `json:"source"`
+}
+
+func (d *CalendarDay) Validate() error {
+	if _, err := time.Parse("2006-01-02", d.Date); err != nil {
+		return fmt.Errorf("invalid date, expected YYYY-MM-DD")
+	}
+
+	switch d.Kind {
+	case CalendarDayHoliday, CalendarDayShort, CalendarDayWorking:
+	default:
+		return fmt.Errorf("invalid kind: must be one of holiday, short_day or working_day")
+	}
+
+	if d.OpenTime != nil {
+		if _, err := parseClock(*d.OpenTime); err != nil {
+			return fmt.Errorf("invalid open_time: %w", err)
+		}
+	}
+	if d.CloseTime != nil {
+		if _, err := parseClock(*d.CloseTime); err != nil {
+			return fmt.Errorf("invalid close_time: %w", err)
+		}
+	}
+	if d.OpenTime != nil && d.CloseTime != nil && *d.CloseTime <= *d.OpenTime {
+		return fmt.Errorf("close_time must be after open_time")
+	}
+
+	return nil
+}
+
+// BusinessCalendar собирает часовой пояс, недельный график и календарь дней
+// для расчета рабочего времени.
+type BusinessCalendar struct {
+	Location *time.Location
+	Hours    []BusinessHours
+	Days     map[string]*CalendarDay
+}
+
+func (c *BusinessCalendar) hoursOn(weekday time.Weekday) *BusinessHours {
+	for i := range c.Hours {
+		if c.Hours[i].Weekday == weekday {
+			return &c.Hours[i]
+		}
+	}
+	return nil
+}
+
+// WorkingIntervals возвращает рабочие интервалы дня без перерыва.
+// Пустой результат — выходной.
+func (c *BusinessCalendar) WorkingIntervals(day time.Time) []TimeRange {
+	day = day.In(c.Location)
+	override := c.Days[day.Format("2006-01-02")]
+
+	hours := c.hoursOn(day.Weekday())
+	if override != nil {
+		switch override.Kind {
+		case CalendarDayHoliday:
+			return nil
+		case CalendarDayShort, CalendarDayWorking:
+			// Рабочий выходной, в том числе сокращенный, работает как понедельник
+			if hours == nil {
+				hours = c.hoursOn(time.Monday)
+			}
+		}
+	}
+	if hours == nil {
+		return nil
+	}
+
+	open, _ := parseClock(hours.OpenTime)
+	closing, _ := parseClock(hours.CloseTime)
+	if override != nil {
+		if override.OpenTime != nil {
+			open, _ = parseClock(*override.OpenTime)
+		}
+		switch {
+		case override.CloseTime != nil:
+			closing, _ = parseClock(*override.CloseTime)
+		case override.Kind == CalendarDayShort:
+			closing -= time.Hour
+		}
+	}
+	if closing <= open {
+		return nil
+	}
+
+	if hours.BreakStart == nil {
+		return []TimeRange{{Start: clockOn(day, open), End: clockOn(day, closing)}}
+	}
+
+	breakStart, _ := parseClock(*hours.BreakStart)
+	breakEnd, _ := parseClock(*hours.BreakEnd)
+	var intervals []TimeRange
+	if breakStart > open {
+		intervals = append(intervals, TimeRange{Start: clockOn(day, open), End: clockOn(day, minDuration(breakStart, closing))})
+	}
+	if breakEnd < closing {
+		intervals = append(intervals, TimeRange{Start: clockOn(day, maxDuration(breakEnd, open)), End: clockOn(day, closing)})
+	}
+	return intervals
+}
+
+// Covers сообщает, укладывается ли интервал целиком в один рабочий интервал.
+func (c *BusinessCalendar) Covers(r TimeRange) bool {
+	for _, interval := range c.WorkingIntervals(r.Start) {
+		if !r.Start.Before(interval.Start) && !r.End.After(interval.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// DayStart возвращает полночь дня t по времени мастерской.
+func (c *BusinessCalendar) DayStart(t time.Time) time.Time {
+	return DayStart(t, c.Location)
+}
+
+// DayStart возвращает полночь дня t в поясе loc.
+func DayStart(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// clockOn возвращает момент, когда на часах дня day показывает offset.
+// Считается по настенному времени, поэтому переход на летнее время его не сдвигает.
+func clockOn(day time.Time, offset time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, int(offset/time.Minute), 0, 0, day.Location())
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,27 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	// Администратор получил токен для работы от имени пользователя
+	ImpersonationActionStarted = "impersonation_started"
+	// Изменяющий запрос, выполненный от имени пользователя
+	ImpersonationActionRequest = "request"
+)
+
+// ImpersonationAuditEntry — запись журнала имперсонации: кто (ActorID)
+// и от чьего имени (UserID) выполнил действие.
+type ImpersonationAuditEntry struct {
+	ID        uuid.UUID  `json:"id"`
+	ActorID   uuid.UUID  `json:"actor_id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Action    string     `json:"action"`
+	Method    string     `json:"method,omitempty"`
+	Path      string     `json:"path,omitempty"`
+	Status    int        `json:"status,omitempty"`
+	IP        string     `json:"ip,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
@@ -0,0 +1,50 @@
+package entity
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// Location — филиал мастерской со своим адресом, часовым поясом, часами
+// работы, постами, услугами и сотрудниками.
+type Location struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Address   string     `json:"address"`
+	TimeZone  string     `json:"time_zone"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+func (l *Location) Validate() error {
+	if l.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if l.Address == "" {
+		return fmt.Errorf("address is required")
+	}
+	if l.TimeZone == "" {
+		return fmt.Errorf("time_zone is required")
+	}
+	if _, err := time.LoadLocation(l.TimeZone); err != nil {
+		return fmt.Errorf("unknown time_zone %q", l.TimeZone)
+	}
+	return nil
+}
+
+// Zone возвращает часовой пояс филиала.
+func (l *Location) Zone() (*time.Location, error) {
+	loc, err := time.LoadLocation(l.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone of location %s: %w", l.ID, err)
+	}
+	return loc, nil
+}
+
+// UserLocationUpdate — филиал, которым ограничены права сотрудника.
+// Пустой LocationID снимает ограничение.
+type UserLocationUpdate struct {
+	LocationID *uuid.UUID `json:"location_id"`
+}
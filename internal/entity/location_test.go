@@ -0,0 +1,33 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+// Пояс мастерской по умолчанию из миграции бизнес-календаря
+const defaultTimeZone = "Europe/Moscow"
+
+func TestLocationZone(t *testing.T) {
+	location := &Location{Name: "Основной филиал", Address: "Москва", TimeZone: defaultTimeZone}
+	if err := location.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	loc, err := location.Zone()
+	if err != nil {
+		t.Fatalf("Zone() error = %v", err)
+	}
+
+	noon := time.Date(2026, time.October, 19, 12, 0, 0, 0, loc)
+	if _, offset := noon.Zone(); offset != 3*60*60 {
+		t.Errorf("offset = %d, want %d", offset, 3*60*60)
+	}
+}
+
+func TestLocationValidateUnknownZone(t *testing.T) {
+	location := &Location{Name: "Филиал", Address: "Москва", TimeZone: "Europe/Nowhere"}
+	if err := location.Validate(); err == nil {
+		t.Error("Validate() error = nil, want error")
+	}
+}
@@ -0,0 +1,33 @@
+package entity
+
+import "fmt"
+
+type PasswordResetRequest struct {
+	Email string `json:"email,omitempty"`
+}
+
+func (e *PasswordResetRequest) Validate() error {
+	if e.Email == "" {
+		return fmt.Errorf("email is required")
+	}
+	return nil
+}
+
+type PasswordResetConfirm struct {
+	Email       string `json:"email,omitempty"`
+	Code        string `json:"code,omitempty"`
+	NewPassword string `json:"new_password,omitempty"`
+}
+
+func (e *PasswordResetConfirm) Validate() error {
+	if e.Email == "" {
+		return fmt.Errorf("email is required")
+	}
+	if e.Code == "" {
+		return fmt.Errorf("code is required")
+	}
+	if e.NewPassword == "" {
+		return fmt.Errorf("new password is required")
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package entity
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"regexp"
+	"time"
+)
+
+// Типы ресурсов и категории услуг — короткие идентификаторы вроде lift,
+// alignment_stand, paint_booth
+var slugPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// DefaultResourceType получает каждая новая услуга: пока ей не заданы
+// другие типы, она занимает подъемник, как и до появления ресурсов
+const DefaultResourceType = "lift"
+
+func ValidateResourceType(resourceType string) error {
+	if !slugPattern.MatchString(resourceType) {
+		return fmt.Errorf("invalid resource type %q: use lowercase letters, digits and underscores", resourceType)
+	}
+	return nil
+}
+
+// Resource — пост или оборудование мастерской, которое занимает запись.
+// Capacity — сколько машин ресурс принимает одновременно.
+type Resource struct {
+	ID         uuid.UUID  `json:"id"`
+	LocationID uuid.UUID  `json:"location_id"`
+	Name       string     `json:"name"`
+	Type       string     `json:"type"`
+	Capacity   int        `json:"capacity"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+}
+
+func (r *Resource) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if r.LocationID == uuid.Nil {
+		return fmt.Errorf("location_id is required")
+	}
+	if err := ValidateResourceType(r.Type); err != nil {
+		return err
+	}
+	if r.Capacity == 0 {
+		r.Capacity = 1
+	}
+	if r.Capacity < 0 {
+		return fmt.Errorf("capacity must be greater than 0")
+	}
+	return nil
+}
+
+// ServiceResourceTypes — типы ресурсов, которые нужны услуге.
+type ServiceResourceTypes struct {
+	ResourceTypes []string `json:"resource_types"`
+}
+
+func (e *ServiceResourceTypes) Validate() error {
+	seen := make(map[string]bool, len(e.ResourceTypes))
+	for _, resourceType := range e.ResourceTypes {
+		if err := ValidateResourceType(resourceType); err != nil {
+			return err
+		}
+		if seen[resourceType] {
+			return fmt.Errorf("duplicate resource type %q", resourceType)
+		}
+		seen[resourceType] = true
+	}
+	return nil
+}
+
+// AppointmentResource — ресурс, назначенный записи.
+type AppointmentResource struct {
+	ResourceID uuid.UUID `json:"resource_id"`
+	Name       string    `json:"name"`
+	Type       string    `json:"type"`
+	Unit       int       `json:"unit"`
+}
+
+// ResourceAllocation — занятость одного места ресурса.
+type ResourceAllocation struct {
+	ResourceID uuid.UUID
+	Unit       int
+	TimeRange
+}
@@ -0,0 +1,134 @@
+package entity
+
+import "fmt"
+
+type Role string
+
+const (
+	RoleClient       Role = "client"
+	RoleReceptionist Role = "receptionist"
+	RoleMechanic     Role = "mechanic"
+	RoleManager      Role = "manager"
+	RoleAdmin        Role = "admin"
+)
+
+type Permission string
+
+const (
+	// Управление каталогом услуг
+	PermissionServicesManage Permission = "services:manage"
+	// Управление постами и оборудованием мастерской
+	PermissionResourcesManage Permission = "resources:manage"
+	// Управление профилями, навыками и графиками сотрудников
+	PermissionStaffManage Permission = "staff:manage"
+	// Настройка часов работы филиалов и праздничного календаря
+	PermissionCalendarManage Permission = "calendar:manage"
+	// Открытие и закрытие филиалов, их адреса и часовые пояса
+	PermissionLocationsManage Permission = "locations:manage"
+	// Доступ к чужим автомобилям
+	PermissionVehiclesReadAll   Permission = "vehicles:read_all"
+	PermissionVehiclesManageAll Permission = "vehicles:manage_all"
+	// Доступ к чужим записям, включая смену статуса
+	PermissionAppointmentsReadAll   Permission = "appointments:read_all"
+	PermissionAppointmentsManageAll Permission = "appointments:manage_all"
+	// Ведение заказов-нарядов: работы, запчасти, скидки и закрытие
+	PermissionWorkOrdersManage Permission = "work_orders:manage"
+	// Просмотр клиентской базы
+	PermissionClientsRead Permission = "clients:read"
+	// Управление пользователями и их ролями
+	PermissionUsersManage Permission = "users:manage"
+	// Вход от имени клиента для поддержки
+	PermissionUsersImpersonate Permission = "users:impersonate"
+	// Выпуск и отзыв API-ключей
+	PermissionAPIKeysManage Permission = "api_keys:manage"
+)
+
+// rolePermissions описывает, какие разрешения выдает каждая роль.
+// Свои автомобили и записи доступны любому пользователю и разрешений не требуют.
+// У сотрудника с филиалом разрешения на записи, заказы-наряды, посты, услуги
+// и сотрудников действуют только в этом филиале.
+var rolePermissions = map[Role][]Permission{
+	RoleClient: {},
+	RoleReceptionist: {
+		PermissionVehiclesReadAll,
+		PermissionAppointmentsReadAll,
+		PermissionAppointmentsManageAll,
+		PermissionWorkOrdersManage,
+		PermissionClientsRead,
+	},
+	RoleMechanic: {
+		PermissionVehiclesReadAll,
+		PermissionAppointmentsReadAll,
+		PermissionWorkOrdersManage,
+	},
+	RoleManager: {
+		PermissionServicesManage,
+		PermissionResourcesManage,
+		PermissionStaffManage,
+		PermissionVehiclesReadAll,
+		PermissionVehiclesManageAll,
+		PermissionAppointmentsReadAll,
+		PermissionAppointmentsManageAll,
+		PermissionWorkOrdersManage,
+		PermissionClientsRead,
+	},
+	RoleAdmin: {
+		PermissionServicesManage,
+		PermissionResourcesManage,
+		PermissionStaffManage,
+		PermissionVehiclesReadAll,
+		PermissionVehiclesManageAll,
+		PermissionAppointmentsReadAll,
+		PermissionAppointmentsManageAll,
+		PermissionWorkOrdersManage,
+		PermissionClientsRead,
+		PermissionUsersManage,
+		PermissionUsersImpersonate,
+		PermissionAPIKeysManage,
+		PermissionCalendarManage,
+		PermissionLocationsManage,
+	},
+}
+
+func (r Role) Validate() error {
+	if _, ok := rolePermissions[r]; !ok {
+		return fmt.Errorf("invalid role: %s", r)
+	}
+	return nil
+}
+
+func (r Role) Permissions() []Permission {
+	return rolePermissions[r]
+}
+
+func (r Role) HasPermission(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate проверяет, что разрешение выдается хотя бы одной ролью.
+func (p Permission) Validate() error {
+	for _, permissions := range rolePermissions {
+		for _, permission := range permissions {
+			if permission == p {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("invalid permission: %s", p)
+}
+
+type RoleUpdate struct {
+	Role Role `json:"role"`
+}
+
+func (e *RoleUpdate) Validate() error {
+	if e.Role == "" {
+		return fmt.Errorf("role is required")
+	}
+	return e.Role.Validate()
+}
@@ -8,10 +8,12 @@ import (
 
 type Service struct {
 	ID          uuid.UUID  `json:"id" db:"id"`
+	LocationID  uuid.UUID  `json:"location_id" db:"location_id"`
 	Name        string     `json:"name" db:"name"`
 	Description *string    `json:"description" db:"description"`
 	Price       float64    `json:"price" db:"price"`
 	DurationMin int        `json:"duration_min" db:"duration_min"`
+	Category    *string    `json:"category" db:"category"`
 	CreatedAt   *time.Time `json:"created_at,omitempty" db:"created_at"`
 	UpdatedAt   *time.Time `json:"updated_at,omitempty" db:"updated_at"`
 	DeletedAt   *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
@@ -21,11 +23,26 @@ func (s *Service) Validate() error {
 	if s.Name == "" {
 		return fmt.Errorf("name is required")
 	}
+	if s.LocationID == uuid.Nil {
+		return fmt.Errorf("location_id is required")
+	}
 	if s.Price < 0 {
 		return fmt.Errorf("price must be greater than 0")
 	}
 	if s.DurationMin < 0 {
 		return fmt.Errorf("duration must be greater than 0")
 	}
+	if s.Category != nil {
+		if err := ValidateServiceCategory(*s.Category); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ValidateServiceCategory(category string) error {
+	if !slugPattern.MatchString(category) {
+		return fmt.Errorf("invalid category %q: use lowercase letters, digits and underscores", category)
+	}
 	return nil
 }
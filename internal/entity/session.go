@@ -0,0 +1,26 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type Session struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	DeviceName string     `json:"device_name"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"user_agent"`
+	Current    bool       `json:"current"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// SessionMeta описывает устройство, с которого выполнен вход.
+type SessionMeta struct {
+	DeviceName string
+	IP         string
+	UserAgent  string
+}
@@ -0,0 +1,222 @@
+package entity
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// StaffProfile — сотрудник, который выполняет работы. Skills — категории
+// услуг, которые он умеет выполнять.
+type StaffProfile struct {
+	UserID   uuid.UUID `json:"user_id"`
+	FullName string    `json:"full_name"`
+	// Филиал, где работает сотрудник. Без филиала работы ему не назначаются
+	LocationID *uuid.UUID     `json:"location_id,omitempty"`
+	Role       Role           `json:"role"`
+	Skills     []string       `json:"skills"`
+	Active     bool           `json:"active"`
+	Schedule   []WorkingHours `json:"schedule"`
+	CreatedAt  *time.Time     `json:"created_at,omitempty"`
+	UpdatedAt  *time.Time     `json:"updated_at,omitempty"`
+}
+
+// HasSkills сообщает, может ли сотрудник выполнить услуги всех категорий.
+func (p *StaffProfile) HasSkills(categories []string) bool {
+	for _, category := range categories {
+		found := false
+		for _, skill := range p.Skills {
+			if skill == category {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// WorkingHoursOn возвращает смену в день недели или nil, если это выходной.
+func (p *StaffProfile) WorkingHoursOn(weekday time.Weekday) *WorkingHours {
+	for i := range p.Schedule {
+		if p.Schedule[i].Weekday == weekday {
+			return &p.Schedule[i]
+		}
+	}
+	return nil
+}
+
+type StaffProfileUpdate struct {
+	Skills []string `json:"skills"`
+	Active *bool    `json:"active,omitempty"`
+}
+
+func (e *StaffProfileUpdate) Validate() error {
+	for _, skill := range e.Skills {
+		if err := ValidateServiceCategory(skill); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WorkingHours — смена в один день недели, время в формате HH:MM.
+type WorkingHours struct {
+	Weekday   time.Weekday `json:"weekday"`
+	StartTime string       `json:"start_time"`
+	EndTime   string       `json:"end_time"`
+}
+
+func (h *WorkingHours) Validate() error {
+	if h.Weekday < time.Sunday || h.Weekday > time.Saturday {
+		return fmt.Errorf("weekday must be between 0 (Sunday) and 6 (Saturday)")
+	}
+
+	start, err := parseClock(h.StartTime)
+	if err != nil {
+		return fmt.Errorf("invalid start_time: %w", err)
+	}
+	end, err := parseClock(h.EndTime)
+	if err != nil {
+		return fmt.Errorf("invalid end_time: %w", err)
+	}
+	if end <= start {
+		return fmt.Errorf("end_time must be after start_time")
+	}
+
+	return nil
+}
+
+// Covers сообщает, укладывается ли интервал в смену того дня, в который он
+// начинается. Интервал должен быть по часам мастерской.
+func (h *WorkingHours) Covers(r TimeRange) bool {
+	if r.Start.Weekday() != h.Weekday {
+		return false
+	}
+
+	start, err := parseClock(h.StartTime)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(h.EndTime)
+	if err != nil {
+		return false
+	}
+
+	return !r.Start.Before(clockOn(r.Start, start)) && !r.End.After(clockOn(r.Start, end))
+}
+
+// parseClock переводит HH:MM в смещение от начала дня.
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM")
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+type WeeklySchedule struct {
+	Days []WorkingHours `json:"days"`
+}
+
+func (e *WeeklySchedule) Validate() error {
+	seen := make(map[time.Weekday]bool, len(e.Days))
+	for i := range e.Days {
+		if err := e.Days[i].Validate(); err != nil {
+			return err
+		}
+		if seen[e.Days[i].Weekday] {
+			return fmt.Errorf("weekday %d is listed twice", e.Days[i].Weekday)
+		}
+		seen[e.Days[i].Weekday] = true
+	}
+	return nil
+}
+
+type ScheduleExceptionKind string
+
+const (
+	ScheduleExceptionVacation  ScheduleExceptionKind = "vacation"
+	ScheduleExceptionSickLeave ScheduleExceptionKind = "sick_leave"
+	ScheduleExceptionDayOff    ScheduleExceptionKind = "day_off"
+)
+
+// ScheduleException — отсутствие сотрудника, даты в формате YYYY-MM-DD включительно.
+type ScheduleException struct {
+	ID        uuid.UUID             `json:"id"`
+	UserID    uuid.UUID             `json:"user_id"`
+	Kind      ScheduleExceptionKind `json:"kind"`
+	StartsOn  string                `json:"starts_on"`
+	EndsOn    string                `json:"ends_on"`
+	Note      *string               `json:"note,omitempty"`
+	CreatedAt *time.Time            `json:"created_at,omitempty"`
+}
+
+// Covers сообщает, приходится ли день на отсутствие.
+func (e *ScheduleException) Covers(day time.Time) bool {
+	date := day.Format("2006-01-02")
+	return e.StartsOn <= date && date <= e.EndsOn
+}
+
+func (e *ScheduleException) Validate() error {
+	switch e.Kind {
+	case ScheduleExceptionVacation, ScheduleExceptionSickLeave, ScheduleExceptionDayOff:
+	default:
+		return fmt.Errorf("invalid kind: must be one of vacation, sick_leave or day_off")
+	}
+
+	startsOn, err := time.Parse("2006-01-02", e.StartsOn)
+	if err != nil {
+		return fmt.Errorf("invalid starts_on, expected YYYY-MM-DD")
+	}
+	endsOn, err := time.Parse("2006-01-02", e.EndsOn)
+	if err != nil {
+		return fmt.Errorf("invalid ends_on, expected YYYY-MM-DD")
+	}
+	if endsOn.Before(startsOn) {
+		return fmt.Errorf("ends_on must not be before starts_on")
+	}
+
+	return nil
+}
+
+// AppointmentMechanic — механик, назначенный на запись.
+type AppointmentMechanic struct {
+	UserID   uuid.UUID `json:"user_id"`
+	FullName string    `json:"full_name"`
+}
+
+// MechanicAllocation — время, на которое механик занят записью.
+type MechanicAllocation struct {
+	UserID        uuid.UUID
+	AppointmentID uuid.UUID
+	TimeRange
+}
+
+type AppointmentMechanicsUpdate struct {
+	MechanicIDs []uuid.UUID `json:"mechanic_ids"`
+}
+
+func (e *AppointmentMechanicsUpdate) Validate() error {
+	if len(e.MechanicIDs) == 0 {
+		return fmt.Errorf("at least one mechanic must be selected")
+	}
+
+	seen := make(map[uuid.UUID]bool, len(e.MechanicIDs))
+	for _, id := range e.MechanicIDs {
+		if seen[id] {
+			return fmt.Errorf("mechanic %s is listed twice", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// AppointmentRequirements — что занимает запись помимо самого времени:
+// по одному ресурсу каждого типа и перечисленных механиков.
+type AppointmentRequirements struct {
+	ResourceTypes []string
+	MechanicIDs   []uuid.UUID
+}
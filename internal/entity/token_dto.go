@@ -0,0 +1,18 @@
+package entity
+
+import "fmt"
+
+type TokenRefresh struct {
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+func (e *TokenRefresh) Validate() error {
+	if e.AccessToken == "" {
+		return fmt.Errorf("access token is required")
+	}
+	if e.RefreshToken == "" {
+		return fmt.Errorf("refresh token is required")
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package entity
+
+import "fmt"
+
+// LoginResult — итог первого шага входа. Если у пользователя включена
+// двухфакторная аутентификация, вместо выдачи токенов возвращается
+// TwoFactorToken, который нужно обменять на токены вместе с кодом.
+type LoginResult struct {
+	User           *User
+	TwoFactorToken string
+}
+
+// TOTPEnrollment отдается при подключении приложения-аутентификатора.
+// URI кодируется в QR-код на клиенте.
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type TwoFactorLogin struct {
+	Token string `json:"two_factor_token,omitempty"`
+	// TOTP-код или код восстановления
+	Code string `json:"code,omitempty"`
+}
+
+func (e *TwoFactorLogin) Validate() error {
+	if e.Token == "" {
+		return fmt.Errorf("two factor token is required")
+	}
+	if e.Code == "" {
+		return fmt.Errorf("code is required")
+	}
+	return nil
+}
+
+type TwoFactorCode struct {
+	Code string `json:"code,omitempty"`
+}
+
+func (e *TwoFactorCode) Validate() error {
+	if e.Code == "" {
+		return fmt.Errorf("code is required")
+	}
+	return nil
+}
+
+type TwoFactorDisable struct {
+	Password string `json:"password,omitempty"`
+	Code     string `json:"code,omitempty"`
+}
+
+func (e *TwoFactorDisable) Validate() error {
+	if e.Password == "" {
+		return fmt.Errorf("password is required")
+	}
+	if e.Code == "" {
+		return fmt.Errorf("code is required")
+	}
+	return nil
+}
@@ -7,15 +7,23 @@ import (
 )
 
 type User struct {
-	ID           uuid.UUID  `json:"id,omitempty"`
-	FullName     string     `json:"full_name,omitempty"`
-	Phone        string     `json:"phone,omitempty"`
-	Email        string     `json:"email,omitempty"`
-	PasswordHash string     `json:"password_hash,omitempty"`
-	IsAdmin      bool       `json:"is_admin,omitempty"`
-	CreatedAt    *time.Time `json:"created_at,omitempty"`
-	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
-	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+	ID              uuid.UUID  `json:"id,omitempty"`
+	FullName        string     `json:"full_name,omitempty"`
+	Phone           string     `json:"phone,omitempty"`
+	Email           string     `json:"email,omitempty"`
+	PasswordHash    string     `json:"password_hash,omitempty"`
+	Role            Role       `json:"role,omitempty"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at,omitempty"`
+	TOTPSecret      string     `json:"-"`
+	TOTPEnabledAt   *time.Time `json:"totp_enabled_at,omitempty"`
+	// Сколько раз клиент не пришел на запись с последнего сброса
+	NoShowCount int `json:"no_show_count"`
+	// Филиал сотрудника: его права действуют только там. Пустой — во всех филиалах
+	LocationID *uuid.UUID `json:"location_id,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
 }
 
 func (e *User) CheckPasswordHash(password string) bool {
@@ -25,3 +33,21 @@ func (e *User) CheckPasswordHash(password string) bool {
 	}
 	return true
 }
+
+// HasVerifiedContact сообщает, подтвержден ли хотя бы один канал связи.
+func (e *User) HasVerifiedContact() bool {
+	return e.EmailVerifiedAt != nil || e.PhoneVerifiedAt != nil
+}
+
+// TwoFactorEnabled сообщает, подтверждено ли подключение TOTP.
+func (e *User) TwoFactorEnabled() bool {
+	return e.TOTPEnabledAt != nil
+}
+
+func HashPassword(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
@@ -8,6 +8,9 @@ import (
 	"time"
 )
 
+// Регулярное выражение для проверки формата email
+var emailRegex = regexp.MustCompile(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}$`)
+
 type UserRegister struct {
 	FullName string `json:"full_name,omitempty"`
 	Phone    string `json:"phone,omitempty"`
@@ -28,8 +31,6 @@ func (e *UserRegister) Validate() error {
 		return fmt.Errorf("email is required")
 	}
 
-	// Регулярное выражение для проверки формата email
-	emailRegex := regexp.MustCompile(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}$`)
 	if !emailRegex.MatchString(e.Email) {
 		return fmt.Errorf("invalid email format")
 	}
@@ -51,12 +52,15 @@ func (e *UserRegister) UserRegisterToUser() *User {
 		Phone:        e.Phone,
 		Email:        e.Email,
 		PasswordHash: string(bytes),
+		Role:         RoleClient,
 	}
 }
 
 type UserLogin struct {
 	Email    string `json:"email,omitempty"`
 	Password string `json:"password,omitempty"`
+	// IP клиента, заполняется обработчиком для защиты от перебора
+	IP string `json:"-"`
 }
 
 func (e *UserLogin) Validate() error {
@@ -81,21 +85,105 @@ func (e *UserLogin) UserLoginToUser() *User {
 }
 
 type UserProfileResponse struct {
-	ID        uuid.UUID  `json:"id"`
-	FullName  string     `json:"full_name"`
-	Phone     string     `json:"phone"`
-	Email     string     `json:"email"`
-	IsAdmin   bool       `json:"is_admin"`
-	CreatedAt *time.Time `json:"created_at,omitempty"`
+	ID              uuid.UUID  `json:"id"`
+	FullName        string     `json:"full_name"`
+	Phone           string     `json:"phone"`
+	Email           string     `json:"email"`
+	Role            Role       `json:"role"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at"`
+	TOTPEnabledAt   *time.Time `json:"totp_enabled_at"`
+	CreatedAt       *time.Time `json:"created_at,omitempty"`
 }
 
 func (u *User) ToProfileResponse() *UserProfileResponse {
 	return &UserProfileResponse{
-		ID:        u.ID,
-		FullName:  u.FullName,
-		Phone:     u.Phone,
-		Email:     u.Email,
-		IsAdmin:   u.IsAdmin,
-		CreatedAt: u.CreatedAt,
+		ID:              u.ID,
+		FullName:        u.FullName,
+		Phone:           u.Phone,
+		Email:           u.Email,
+		Role:            u.Role,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		PhoneVerifiedAt: u.PhoneVerifiedAt,
+		TOTPEnabledAt:   u.TOTPEnabledAt,
+		CreatedAt:       u.CreatedAt,
+	}
+}
+
+type PhoneLoginRequest struct {
+	Phone string `json:"phone,omitempty"`
+}
+
+func (e *PhoneLoginRequest) Validate() error {
+	if e.Phone == "" {
+		return fmt.Errorf("phone is required")
+	}
+	return nil
+}
+
+type PhoneLogin struct {
+	Phone string `json:"phone,omitempty"`
+	Code  string `json:"code,omitempty"`
+}
+
+func (e *PhoneLogin) Validate() error {
+	if e.Phone == "" {
+		return fmt.Errorf("phone is required")
+	}
+	if e.Code == "" {
+		return fmt.Errorf("code is required")
+	}
+	return nil
+}
+
+type UserProfileUpdate struct {
+	FullName *string `json:"full_name,omitempty"`
+	Phone    *string `json:"phone,omitempty"`
+	Email    *string `json:"email,omitempty"`
+}
+
+func (e *UserProfileUpdate) Validate() error {
+	if e.FullName == nil && e.Phone == nil && e.Email == nil {
+		return fmt.Errorf("nothing to update")
+	}
+
+	if e.FullName != nil && *e.FullName == "" {
+		return fmt.Errorf("full name cannot be empty")
+	}
+
+	if e.Phone != nil && *e.Phone == "" {
+		return fmt.Errorf("phone cannot be empty")
+	}
+
+	if e.Email != nil && !emailRegex.MatchString(*e.Email) {
+		return fmt.Errorf("invalid email format")
+	}
+
+	return nil
+}
+
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password,omitempty"`
+	NewPassword     string `json:"new_password,omitempty"`
+}
+
+func (e *PasswordChange) Validate() error {
+	if e.CurrentPassword == "" {
+		return fmt.Errorf("current password is required")
+	}
+	if e.NewPassword == "" {
+		return fmt.Errorf("new password is required")
+	}
+	return nil
+}
+
+type AccountDelete struct {
+	Password string `json:"password,omitempty"`
+}
+
+func (e *AccountDelete) Validate() error {
+	if e.Password == "" {
+		return fmt.Errorf("password is required")
 	}
+	return nil
 }
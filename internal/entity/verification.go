@@ -0,0 +1,30 @@
+package entity
+
+import "fmt"
+
+type VerificationChannel string
+
+const (
+	VerificationChannelEmail VerificationChannel = "email"
+	VerificationChannelPhone VerificationChannel = "phone"
+)
+
+func (c VerificationChannel) Validate() error {
+	switch c {
+	case VerificationChannelEmail, VerificationChannelPhone:
+		return nil
+	default:
+		return fmt.Errorf("invalid channel: must be one of email or phone")
+	}
+}
+
+type VerificationConfirm struct {
+	Code string `json:"code,omitempty"`
+}
+
+func (e *VerificationConfirm) Validate() error {
+	if e.Code == "" {
+		return fmt.Errorf("code is required")
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package entity
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+type WaitlistStatus string
+
+const (
+	// Клиент ждет освободившегося времени
+	WaitlistStatusWaiting WaitlistStatus = "waiting"
+	// Клиенту предложено время, оно зарезервировано до ответа
+	WaitlistStatusOffered WaitlistStatus = "offered"
+	// Клиент принял предложение и записан
+	WaitlistStatusBooked WaitlistStatus = "booked"
+	// Клиент не ответил на предложение вовремя и выбыл из очереди
+	WaitlistStatusExpired WaitlistStatus = "expired"
+	// Клиент сам покинул очередь
+	WaitlistStatusCancelled WaitlistStatus = "cancelled"
+)
+
+// WaitlistEntry — заявка клиента в лист ожидания: нужные услуги и даты,
+// в которые ему подходит любое время. Даты в формате YYYY-MM-DD включительно.
+type WaitlistEntry struct {
+	ID         uuid.UUID      `json:"id"`
+	UserID     uuid.UUID      `json:"user_id"`
+	LocationID uuid.UUID      `json:"location_id"`
+	VehicleID  uuid.UUID      `json:"vehicle_id"`
+	ServiceIDs []uuid.UUID    `json:"service_ids"`
+	DateFrom   string         `json:"date_from"`
+	DateTo     string         `json:"date_to"`
+	Status     WaitlistStatus `json:"status"`
+	Offer      *WaitlistOffer `json:"offer,omitempty"`
+	CreatedAt  *time.Time     `json:"created_at,omitempty"`
+}
+
+type WaitlistEntryCreate struct {
+	LocationID uuid.UUID   `json:"location_id"`
+	VehicleID  uuid.UUID   `json:"vehicle_id"`
+	ServiceIDs []uuid.UUID `json:"service_ids"`
+	DateFrom   string      `json:"date_from"`
+	DateTo     string      `json:"date_to"`
+}
+
+func (e *WaitlistEntryCreate) Validate() error {
+	if e.LocationID == uuid.Nil {
+		return fmt.Errorf("location_id is required")
+	}
+
+	if e.VehicleID == uuid.Nil {
+		return fmt.Errorf("vehicle_id is required")
+	}
+
+	if len(e.ServiceIDs) == 0 {
+		return fmt.Errorf("at least one service must be selected")
+	}
+
+	from, err := time.Parse("2006-01-02", e.DateFrom)
+	if err != nil {
+		return fmt.Errorf("invalid date_from, expected YYYY-MM-DD")
+	}
+	if e.DateTo == "" {
+		e.DateTo = e.DateFrom
+	}
+	to, err := time.Parse("2006-01-02", e.DateTo)
+	if err != nil {
+		return fmt.Errorf("invalid date_to, expected YYYY-MM-DD")
+	}
+
+	if to.Before(from) {
+		return fmt.Errorf("date_to must not be before date_from")
+	}
+	if to.Sub(from) >= MaxAvailabilityRange {
+		return fmt.Errorf("range must not exceed %d days", int(MaxAvailabilityRange.Hours()/24))
+	}
+	if to.Before(time.Now().AddDate(0, 0, -1)) {
+		return fmt.Errorf("date_to must not be in the past")
+	}
+
+	return nil
+}
+
+func (e *WaitlistEntryCreate) ToEntry(userID uuid.UUID) *WaitlistEntry {
+	return &WaitlistEntry{
+		UserID:     userID,
+		LocationID: e.LocationID,
+		VehicleID:  e.VehicleID,
+		ServiceIDs: e.ServiceIDs,
+		DateFrom:   e.DateFrom,
+		DateTo:     e.DateTo,
+		Status:     WaitlistStatusWaiting,
+	}
+}
+
+type WaitlistOfferStatus string
+
+const (
+	WaitlistOfferPending  WaitlistOfferStatus = "pending"
+	WaitlistOfferAccepted WaitlistOfferStatus = "accepted"
+	WaitlistOfferDeclined WaitlistOfferStatus = "declined"
+	WaitlistOfferExpired  WaitlistOfferStatus = "expired"
+)
+
+// WaitlistOffer — предложение освободившегося времени. На время ожидания
+// ответа под него создана запись в статусе reserved, которая держит посты
+// и механиков.
+type WaitlistOffer struct {
+	ID              uuid.UUID           `json:"id"`
+	EntryID         uuid.UUID           `json:"entry_id"`
+	AppointmentID   uuid.UUID           `json:"appointment_id"`
+	AppointmentTime time.Time           `json:"appointment_time"`
+	Status          WaitlistOfferStatus `json:"status"`
+	ExpiresAt       time.Time           `json:"expires_at"`
+	CreatedAt       *time.Time          `json:"created_at,omitempty"`
+}
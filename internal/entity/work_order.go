@@ -0,0 +1,148 @@
+package entity
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"math"
+	"time"
+)
+
+type WorkOrderStatus string
+
+const (
+	WorkOrderStatusOpen WorkOrderStatus = "open"
+	// Работы сданы, заказ-наряд больше не меняется
+	WorkOrderStatusClosed WorkOrderStatus = "closed"
+)
+
+type WorkOrderLineKind string
+
+const (
+	// Работа: Quantity — нормо-часы, UnitPrice — ставка за час. Работа по
+	// услуге записи — одна единица по цене на момент записи
+	WorkOrderLineLabor WorkOrderLineKind = "labor"
+	WorkOrderLinePart  WorkOrderLineKind = "part"
+	// Расходники: масла, жидкости, крепеж
+	WorkOrderLineConsumable WorkOrderLineKind = "consumable"
+)
+
+func (k WorkOrderLineKind) Validate() error {
+	switch k {
+	case WorkOrderLineLabor, WorkOrderLinePart, WorkOrderLineConsumable:
+		return nil
+	}
+	return fmt.Errorf("invalid kind %q, expected labor, part or consumable", k)
+}
+
+// WorkOrder — заказ-наряд записи: работы, запчасти и расходники с оценкой
+// и фактом. Итоги считаются по строкам.
+type WorkOrder struct {
+	ID             uuid.UUID        `json:"id"`
+	AppointmentID  uuid.UUID        `json:"appointment_id"`
+	Status         WorkOrderStatus  `json:"status"`
+	Lines          []*WorkOrderLine `json:"lines"`
+	EstimatedTotal float64          `json:"estimated_total"`
+	ActualTotal    float64          `json:"actual_total"`
+	ClosedAt       *time.Time       `json:"closed_at,omitempty"`
+	ClosedBy       *uuid.UUID       `json:"closed_by,omitempty"`
+	CreatedAt      *time.Time       `json:"created_at,omitempty"`
+	UpdatedAt      *time.Time       `json:"updated_at,omitempty"`
+}
+
+// CalculateTotals пересчитывает оценку и факт по строкам.
+func (w *WorkOrder) CalculateTotals() {
+	w.EstimatedTotal, w.ActualTotal = 0, 0
+	for _, line := range w.Lines {
+		line.EstimatedAmount = line.amount(line.Quantity)
+		line.ActualAmount = line.amount(line.ActualOrEstimated())
+		w.EstimatedTotal += line.EstimatedAmount
+		w.ActualTotal += line.ActualAmount
+	}
+	w.EstimatedTotal = math.Round(w.EstimatedTotal*100) / 100
+	w.ActualTotal = math.Round(w.ActualTotal*100) / 100
+}
+
+// WorkOrderLine — строка заказа-наряда. Работы по услугам записи ссылаются
+// на AppointmentServiceID, берут цену из снимка на момент записи и остаются
+// работами.
+type WorkOrderLine struct {
+	ID                   uuid.UUID         `json:"id"`
+	WorkOrderID          uuid.UUID         `json:"work_order_id"`
+	Kind                 WorkOrderLineKind `json:"kind"`
+	AppointmentServiceID *uuid.UUID        `json:"appointment_service_id,omitempty"`
+	Description          string            `json:"description"`
+	PartNumber           *string           `json:"part_number,omitempty"`
+	// Оценка: нормо-часы для работ, количество для запчастей и расходников
+	Quantity float64 `json:"quantity"`
+	// Факт, пока не внесен — совпадает с оценкой
+	ActualQuantity  *float64   `json:"actual_quantity,omitempty"`
+	UnitPrice       float64    `json:"unit_price"`
+	DiscountPercent float64    `json:"discount_percent"`
+	EstimatedAmount float64    `json:"estimated_amount"`
+	ActualAmount    float64    `json:"actual_amount"`
+	CreatedAt       *time.Time `json:"created_at,omitempty"`
+	UpdatedAt       *time.Time `json:"updated_at,omitempty"`
+}
+
+// ActualOrEstimated возвращает фактическое количество, а без него — оценку.
+func (l *WorkOrderLine) ActualOrEstimated() float64 {
+	if l.ActualQuantity != nil {
+		return *l.ActualQuantity
+	}
+	return l.Quantity
+}
+
+// amount — стоимость количества со скидкой строки, округленная до копеек.
+func (l *WorkOrderLine) amount(quantity float64) float64 {
+	return math.Round(quantity*l.UnitPrice*(100-l.DiscountPercent)) / 100
+}
+
+// WorkOrderLineInput — добавление или замена строки заказа-наряда.
+type WorkOrderLineInput struct {
+	Kind            WorkOrderLineKind `json:"kind"`
+	Description     string            `json:"description"`
+	PartNumber      *string           `json:"part_number,omitempty"`
+	Quantity        float64           `json:"quantity"`
+	ActualQuantity  *float64          `json:"actual_quantity,omitempty"`
+	UnitPrice       float64           `json:"unit_price"`
+	DiscountPercent float64           `json:"discount_percent"`
+}
+
+func (i *WorkOrderLineInput) Validate() error {
+	if err := i.Kind.Validate(); err != nil {
+		return err
+	}
+	if i.Description == "" {
+		return fmt.Errorf("description is required")
+	}
+	if i.PartNumber != nil && i.Kind == WorkOrderLineLabor {
+		return fmt.Errorf("part_number is only allowed for parts and consumables")
+	}
+	if i.Quantity < 0 {
+		return fmt.Errorf("quantity must not be negative")
+	}
+	if i.ActualQuantity != nil && *i.ActualQuantity < 0 {
+		return fmt.Errorf("actual_quantity must not be negative")
+	}
+	if i.UnitPrice < 0 {
+		return fmt.Errorf("unit_price must not be negative")
+	}
+	if i.DiscountPercent < 0 || i.DiscountPercent > 100 {
+		return fmt.Errorf("discount_percent must be between 0 and 100")
+	}
+	return nil
+}
+
+// ToLine создает строку заказа-наряда из запроса.
+func (i *WorkOrderLineInput) ToLine(workOrderID uuid.UUID) *WorkOrderLine {
+	return &WorkOrderLine{
+		WorkOrderID:     workOrderID,
+		Kind:            i.Kind,
+		Description:     i.Description,
+		PartNumber:      i.PartNumber,
+		Quantity:        i.Quantity,
+		ActualQuantity:  i.ActualQuantity,
+		UnitPrice:       i.UnitPrice,
+		DiscountPercent: i.DiscountPercent,
+	}
+}
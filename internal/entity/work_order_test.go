@@ -0,0 +1,52 @@
+package entity
+
+import "testing"
+
+func TestWorkOrderCalculateTotals(t *testing.T) {
+	actual := 3.0
+	order := &WorkOrder{
+		Lines: []*WorkOrderLine{
+			// Работа по услуге записи — одна единица по цене снимка
+			{Kind: WorkOrderLineLabor, Quantity: 1, UnitPrice: 4500},
+			{Kind: WorkOrderLinePart, Quantity: 2, ActualQuantity: &actual, UnitPrice: 1250.5, DiscountPercent: 10},
+			{Kind: WorkOrderLineConsumable, Quantity: 0.75, UnitPrice: 820},
+			{Kind: WorkOrderLineLabor, Quantity: 1, UnitPrice: 99.99, DiscountPercent: 33},
+		},
+	}
+
+	// Повторный расчет не накапливает итоги
+	order.CalculateTotals()
+	order.CalculateTotals()
+
+	wantLines := []struct {
+		estimated float64
+		actual    float64
+	}{
+		{4500, 4500},
+		{2250.9, 3376.35},
+		{615, 615},
+		{66.99, 66.99},
+	}
+	for i, want := range wantLines {
+		line := order.Lines[i]
+		if line.EstimatedAmount != want.estimated || line.ActualAmount != want.actual {
+			t.Errorf("line %d amounts = %v, %v, want %v, %v", i, line.EstimatedAmount, line.ActualAmount, want.estimated, want.actual)
+		}
+	}
+
+	if order.EstimatedTotal != 7432.89 {
+		t.Errorf("EstimatedTotal = %v, want %v", order.EstimatedTotal, 7432.89)
+	}
+	if order.ActualTotal != 8558.34 {
+		t.Errorf("ActualTotal = %v, want %v", order.ActualTotal, 8558.34)
+	}
+}
+
+func TestWorkOrderCalculateTotalsEmpty(t *testing.T) {
+	order := &WorkOrder{EstimatedTotal: 100, ActualTotal: 100}
+	order.CalculateTotals()
+
+	if order.EstimatedTotal != 0 || order.ActualTotal != 0 {
+		t.Errorf("totals = %v, %v, want 0, 0", order.EstimatedTotal, order.ActualTotal)
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/internal/services"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func (h *Handler) createAPIKey(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Locals("UID").(string))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	var input entity.APIKeyCreate
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	// Нельзя выдать ключу права, которых нет у самого администратора
+	for _, p := range input.Permissions {
+		if !hasPermission(c, p) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "cannot grant permission " + string(p),
+			})
+		}
+	}
+
+	key, rawKey, err := h.services.APIKeyService.Create(c.Context(), userID, input)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error creating api key")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "error creating api key",
+		})
+	}
+	// Сам ключ показывается только один раз
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": fiber.Map{
+			"key":     rawKey,
+			"api_key": key,
+		},
+	})
+}
+
+func (h *Handler) getAPIKeys(c *fiber.Ctx) error {
+	keys, err := h.services.APIKeyService.GetAll(c.Context())
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting api keys")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "error getting api keys",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": keys,
+	})
+}
+
+func (h *Handler) revokeAPIKey(c *fiber.Ctx) error {
+	keyID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing api key id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing api key id",
+		})
+	}
+
+	if err := h.services.APIKeyService.Revoke(c.Context(), keyID); err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		h.log.Error().Err(err).Msg("error revoking api key")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "error revoking api key",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
@@ -2,8 +2,12 @@ package handlers
 
 import (
 	"backend-service/internal/entity"
+	"backend-service/internal/services"
+	"errors"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"strings"
+	"time"
 )
 
 func (h *Handler) createAppointment(c *fiber.Ctx) error {
@@ -28,8 +32,23 @@ func (h *Handler) createAppointment(c *fiber.Ctx) error {
 		})
 	}
 
-	appointmentID, err := h.services.AppointmentService.Create(c.Context(), userID, &input)
+	appointmentID, err := h.services.AppointmentService.Create(c.Context(), userID, currentActor(c), &input)
 	if err != nil {
+		if errors.Is(err, services.ErrContactNotVerified) || errors.Is(err, services.ErrBookingBlocked) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		if errors.Is(err, services.ErrOutsideBusinessHours) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		if errors.Is(err, services.ErrTimeSlotUnavailable) || errors.Is(err, services.ErrNoMechanicAvailable) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
 		h.log.Error().Err(err).Msg("error creating appointment")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": err.Error(),
@@ -83,21 +102,13 @@ func (h *Handler) getAppointment(c *fiber.Ctx) error {
 			"message": err.Error(),
 		})
 	}
-	//
-	//// Check if the appointment belongs to the requesting user
-	//userID, err := uuid.Parse(c.Locals("UID").(string))
-	//if err != nil {
-	//	h.log.Error().Err(err).Msg("error parsing user id")
-	//	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-	//		"message": "error parsing user id",
-	//	})
-	//}
-	//
-	//if appointment.UserID != userID {
-	//	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-	//		"message": "forbidden",
-	//	})
-	//}
+
+	// Check if the appointment belongs to the requesting user
+	if !h.canAccessAt(c, appointment.UserID, appointment.LocationID, entity.PermissionAppointmentsReadAll) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "ok",
@@ -115,27 +126,19 @@ func (h *Handler) updateAppointment(c *fiber.Ctx) error {
 	}
 
 	// Check if the appointment exists and belongs to the user
-	//appointment, err := h.services.AppointmentService.GetById(c.Context(), appointmentID)
-	//if err != nil {
-	//	h.log.Error().Err(err).Msg("error getting appointment")
-	//	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-	//		"message": err.Error(),
-	//	})
-	//}
-
-	//userID, err := uuid.Parse(c.Locals("UID").(string))
-	//if err != nil {
-	//	h.log.Error().Err(err).Msg("error parsing user id")
-	//	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-	//		"message": "error parsing user id",
-	//	})
-	//}
-
-	//if appointment.UserID != userID {
-	//	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-	//		"message": "forbidden",
-	//	})
-	//}
+	appointment, err := h.services.AppointmentService.GetById(c.Context(), appointmentID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting appointment")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	if !h.canAccessAt(c, appointment.UserID, appointment.LocationID, entity.PermissionAppointmentsManageAll) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
 
 	var input entity.AppointmentUpdate
 	if err := c.BodyParser(&input); err != nil {
@@ -150,7 +153,26 @@ func (h *Handler) updateAppointment(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.services.AppointmentService.Update(c.Context(), appointmentID, &input); err != nil {
+	// Only staff can move an appointment between statuses
+	if input.Status != nil && !h.canManageAt(c, appointment.LocationID, entity.PermissionAppointmentsManageAll) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+
+	if err := h.services.AppointmentService.Update(c.Context(), appointmentID, currentActor(c), &input); err != nil {
+		if errors.Is(err, services.ErrOutsideBusinessHours) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		if errors.Is(err, services.ErrTimeSlotUnavailable) || errors.Is(err, services.ErrNoMechanicAvailable) ||
+			errors.Is(err, services.ErrInvalidStatusTransition) || errors.Is(err, services.ErrPrepaymentRequired) ||
+			errors.Is(err, services.ErrServicesNotEditable) || errors.Is(err, services.ErrChangeWithinFeeWindow) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
 		h.log.Error().Err(err).Msg("error updating appointment")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": err.Error(),
@@ -180,21 +202,44 @@ func (h *Handler) cancelAppointment(c *fiber.Ctx) error {
 		})
 	}
 
-	userID, err := uuid.Parse(c.Locals("UID").(string))
-	if err != nil {
-		h.log.Error().Err(err).Msg("error parsing user id")
+	if !h.canAccessAt(c, appointment.UserID, appointment.LocationID, entity.PermissionAppointmentsManageAll) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+
+	// Причина отмены необязательна, тело запроса может быть пустым.
+	// Без override действует политика отмены: поздняя отмена платная,
+	// а незадолго до начала клиент отменить запись не может
+	var input entity.AppointmentCancel
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "error parsing request body",
+			})
+		}
+	}
+
+	if err := input.Validate(); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "error parsing user id",
+			"message": err.Error(),
 		})
 	}
 
-	if appointment.UserID != userID {
+	// Отменить запись вопреки политике отмены может только сотрудник
+	if input.Override && !h.canManageAt(c, appointment.LocationID, entity.PermissionAppointmentsManageAll) {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"message": "forbidden",
 		})
 	}
 
-	if err := h.services.AppointmentService.Cancel(c.Context(), appointmentID); err != nil {
+	cancellation, err := h.services.AppointmentService.Cancel(c.Context(), appointmentID, currentActor(c), &input)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidStatusTransition) || errors.Is(err, services.ErrCancellationCutoff) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
 		h.log.Error().Err(err).Msg("error cancelling appointment")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": err.Error(),
@@ -203,5 +248,303 @@ func (h *Handler) cancelAppointment(c *fiber.Ctx) error {
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "ok",
+		"details": cancellation,
+	})
+}
+
+// rescheduleAppointment переносит запись на другое время. Перенести можно свою
+// запись или любую при праве appointments:manage_all.
+func (h *Handler) rescheduleAppointment(c *fiber.Ctx) error {
+	appointmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing appointment id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing appointment id",
+		})
+	}
+
+	appointment, err := h.services.AppointmentService.GetById(c.Context(), appointmentID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting appointment")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	if !h.canAccessAt(c, appointment.UserID, appointment.LocationID, entity.PermissionAppointmentsManageAll) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+
+	var input entity.AppointmentReschedule
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	appointment, err = h.services.AppointmentService.Reschedule(c.Context(), appointmentID, currentActor(c), &input)
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		if errors.Is(err, services.ErrOutsideBusinessHours) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		if errors.Is(err, services.ErrTimeSlotUnavailable) || errors.Is(err, services.ErrNoMechanicAvailable) ||
+			errors.Is(err, services.ErrNotReschedulable) || errors.Is(err, services.ErrRescheduleCutoff) ||
+			errors.Is(err, services.ErrChangeWithinFeeWindow) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		h.log.Error().Err(err).Msg("error rescheduling appointment")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": appointment,
+	})
+}
+
+// getAppointmentReschedules возвращает историю переносов записи с прежним временем.
+func (h *Handler) getAppointmentReschedules(c *fiber.Ctx) error {
+	appointmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing appointment id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing appointment id",
+		})
+	}
+
+	appointment, err := h.services.AppointmentService.GetById(c.Context(), appointmentID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting appointment")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	if !h.canAccessAt(c, appointment.UserID, appointment.LocationID, entity.PermissionAppointmentsReadAll) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+
+	history, err := h.services.AppointmentService.GetTimeHistory(c.Context(), appointmentID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting reschedule history")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": history,
+	})
+}
+
+// getAppointmentHistory возвращает историю статусов записи: кто, когда и почему ее менял.
+func (h *Handler) getAppointmentHistory(c *fiber.Ctx) error {
+	appointmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing appointment id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing appointment id",
+		})
+	}
+
+	appointment, err := h.services.AppointmentService.GetById(c.Context(), appointmentID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting appointment")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	if !h.canAccessAt(c, appointment.UserID, appointment.LocationID, entity.PermissionAppointmentsReadAll) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+
+	history, err := h.services.AppointmentService.GetStatusHistory(c.Context(), appointmentID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting appointment history")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": history,
+	})
+}
+
+func (h *Handler) getAvailability(c *fiber.Ctx) error {
+	var query entity.AvailabilityQuery
+	var err error
+	// Даты передаются в формате YYYY-MM-DD, to включительно
+	if from := c.Query("from"); from != "" {
+		if query.From, err = time.Parse("2006-01-02", from); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "invalid from date, expected YYYY-MM-DD",
+			})
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if query.To, err = time.Parse("2006-01-02", to); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "invalid to date, expected YYYY-MM-DD",
+			})
+		}
+	}
+	// Свободное время считается по часам, постам и механикам филиала
+	if value := c.Query("location_id"); value != "" {
+		if query.LocationID, err = uuid.Parse(value); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "error parsing location id",
+			})
+		}
+	}
+	// Услуги передаются списком через запятую
+	for _, id := range strings.Split(c.Query("service_ids"), ",") {
+		if id = strings.TrimSpace(id); id == "" {
+			continue
+		}
+		serviceID, err := uuid.Parse(id)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "error parsing service id",
+			})
+		}
+		query.ServiceIDs = append(query.ServiceIDs, serviceID)
+	}
+
+	if err := query.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	availability, err := h.services.AppointmentService.GetAvailability(c.Context(), &query)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting availability")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": availability,
+	})
+}
+
+// setAppointmentMechanics заменяет механиков, назначенных на запись.
+func (h *Handler) setAppointmentMechanics(c *fiber.Ctx) error {
+	appointmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing appointment id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing appointment id",
+		})
+	}
+
+	current, err := h.services.AppointmentService.GetById(c.Context(), appointmentID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting appointment")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	if !h.canManageAt(c, current.LocationID, entity.PermissionAppointmentsManageAll) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+
+	var input entity.AppointmentMechanicsUpdate
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	appointment, err := h.services.AppointmentService.SetMechanics(c.Context(), appointmentID, &input)
+	if err != nil {
+		if errors.Is(err, services.ErrMechanicUnavailable) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		h.log.Error().Err(err).Msg("error assigning mechanics")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": appointment.Mechanics,
+	})
+}
+
+// markAppointmentPrepaid отмечает, что клиент внес предоплату, которую потребовала политика неявок.
+func (h *Handler) markAppointmentPrepaid(c *fiber.Ctx) error {
+	appointmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing appointment id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing appointment id",
+		})
+	}
+
+	appointment, err := h.services.AppointmentService.GetById(c.Context(), appointmentID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting appointment")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	if !h.canManageAt(c, appointment.LocationID, entity.PermissionAppointmentsManageAll) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+
+	appointment, err = h.services.AppointmentService.MarkPrepaid(c.Context(), appointmentID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error marking appointment prepaid")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": appointment,
 	})
 }
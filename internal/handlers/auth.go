@@ -2,7 +2,11 @@ package handlers
 
 import (
 	"backend-service/internal/entity"
+	"backend-service/internal/services"
+	"backend-service/pkg/jwt"
+	"errors"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 func (h *Handler) register(c *fiber.Ctx) error {
@@ -28,57 +32,310 @@ func (h *Handler) register(c *fiber.Ctx) error {
 		})
 	}
 	// Создаем токены
-	accessToken, _, err := h.jwtService.GenerateTokenPair(userId.String())
+	return h.respondWithTokens(c, userId, entity.RoleClient, false)
+}
+
+func (h *Handler) login(c *fiber.Ctx) error {
+	var user entity.UserLogin
+	// Парсим тело запроса
+	if err := c.BodyParser(&user); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+	// Проверяем тело запроса
+	if err := user.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	// Попытки входа считаются по аккаунту и по IP
+	user.IP = c.IP()
+	result, err := h.services.AuthService.Login(c.Context(), user)
+	if err != nil {
+		if errors.Is(err, services.ErrTooManyRequests) {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		h.log.Error().Err(err).Msg("error logging in")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	// Создаем токены или просим второй фактор
+	return h.respondWithLoginResult(c, result)
+}
+
+// respondWithLoginResult отдает токены либо, если у пользователя включена
+// двухфакторная аутентификация, промежуточный токен для второго шага входа.
+func (h *Handler) respondWithLoginResult(c *fiber.Ctx, result *entity.LoginResult) error {
+	if result.TwoFactorToken != "" {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "ok",
+			"details": fiber.Map{
+				"two_factor_required": true,
+				"two_factor_token":    result.TwoFactorToken,
+			},
+		})
+	}
+
+	return h.respondWithTokens(c, result.User.ID, result.User.Role, false)
+}
+
+// respondWithTokens выпускает пару токенов для пользователя и отдает ее клиенту.
+// mfa — пройден ли при входе второй фактор.
+func (h *Handler) respondWithTokens(c *fiber.Ctx, userId uuid.UUID, role entity.Role, mfa bool) error {
+	tokens, err := h.issueTokens(c, userId, role, mfa)
 	if err != nil {
 		h.log.Error().Err(err).Msg("error generating tokens")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "error generating tokens",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": tokens,
+	})
+}
+
+// issueTokens открывает сессию для текущего устройства и выпускает
+// привязанную к ней пару токенов.
+func (h *Handler) issueTokens(c *fiber.Ctx, userId uuid.UUID, role entity.Role, mfa bool) (fiber.Map, error) {
+	session, err := h.services.SessionService.Start(c.Context(), userId, entity.SessionMeta{
+		DeviceName: c.Get("X-Device-Name"),
+		IP:         c.IP(),
+		UserAgent:  c.Get(fiber.HeaderUserAgent),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, refreshToken, err := h.jwtService.GenerateTokenPair(jwt.Subject{
+		UserId:    userId.String(),
+		Role:      string(role),
+		SessionId: session.ID.String(),
+		MFA:       mfa,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fiber.Map{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+	}, nil
+}
+
+func (h *Handler) refresh(c *fiber.Ctx) error {
+	var input entity.TokenRefresh
+	// Парсим тело запроса
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+	// Проверяем тело запроса
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"message": err.Error(),
 		})
 	}
+	// Обновляем пару, старый refresh-токен после этого недействителен
+	accessToken, refreshToken, err := h.jwtService.RefreshTokens(input.RefreshToken, input.AccessToken)
+	if err != nil {
+		h.log.Warn().Err(err).Msg("error refreshing tokens")
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "invalid refresh token",
+		})
+	}
+	// Отмечаем активность сессии и продлеваем ее вместе с refresh-токеном
+	if claims, err := h.jwtService.ValidateJWT(accessToken, "access"); err == nil && claims.SessionId != "" {
+		if sessionID, err := uuid.Parse(claims.SessionId); err == nil {
+			if err := h.services.SessionService.Touch(c.Context(), sessionID, c.IP()); err != nil {
+				h.log.Error().Err(err).Msg("error touching session")
+			}
+		}
+	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "ok",
 		"details": fiber.Map{
-			"access_token": accessToken,
+			"access_token":  accessToken,
+			"refresh_token": refreshToken,
 		},
 	})
 }
 
-func (h *Handler) login(c *fiber.Ctx) error {
-	var user entity.UserLogin
+func (h *Handler) logout(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Locals("UID").(string))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	// Отзываем текущий access-токен, вместе с ним перестает работать и его refresh-токен
+	if err := h.jwtService.Revoke(c.Locals("AccessToken").(string)); err != nil {
+		h.log.Error().Err(err).Msg("error revoking token")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "error revoking token",
+		})
+	}
+	// Закрываем сессию устройства, если токен к ней привязан
+	if sessionID, err := uuid.Parse(c.Locals("SessionID").(string)); err == nil {
+		if err := h.revokeSession(c, userID, sessionID); err != nil {
+			h.log.Error().Err(err).Msg("error revoking session")
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+func (h *Handler) logoutAll(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Locals("UID").(string))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	// Отзываем все токены и сессии пользователя на всех устройствах
+	if err := h.revokeAllSessions(c, userID); err != nil {
+		h.log.Error().Err(err).Msg("error revoking tokens")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "error revoking tokens",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+func (h *Handler) requestPasswordReset(c *fiber.Ctx) error {
+	var input entity.PasswordResetRequest
 	// Парсим тело запроса
-	if err := c.BodyParser(&user); err != nil {
+	if err := c.BodyParser(&input); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"message": "error parsing request body",
 		})
 	}
 	// Проверяем тело запроса
-	if err := user.Validate(); err != nil {
+	if err := input.Validate(); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"message": err.Error(),
 		})
 	}
-	//
-	userId, err := h.services.AuthService.Login(c.Context(), user)
-	if err != nil {
-		h.log.Error().Err(err).Msg("error logging in")
+	// Отправляем код, ответ не зависит от того, зарегистрирован ли email
+	if err := h.services.AuthService.RequestPasswordReset(c.Context(), input); err != nil {
+		if errors.Is(err, services.ErrTooManyRequests) {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		h.log.Error().Err(err).Msg("error requesting password reset")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "error requesting password reset",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+func (h *Handler) confirmPasswordReset(c *fiber.Ctx) error {
+	var input entity.PasswordResetConfirm
+	// Парсим тело запроса
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+	// Проверяем тело запроса
+	if err := input.Validate(); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"message": err.Error(),
 		})
 	}
-	// Создаем токены
-	accessToken, _, err := h.jwtService.GenerateTokenPair(userId.ID.String())
+	// Меняем пароль по коду
+	userId, err := h.services.AuthService.ConfirmPasswordReset(c.Context(), input)
 	if err != nil {
-		h.log.Error().Err(err).Msg("error generating tokens")
+		h.log.Error().Err(err).Msg("error confirming password reset")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	// Старые сессии после смены пароля недействительны
+	if err := h.revokeAllSessions(c, userId); err != nil {
+		h.log.Error().Err(err).Msg("error revoking tokens")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+func (h *Handler) requestLoginCode(c *fiber.Ctx) error {
+	var input entity.PhoneLoginRequest
+	// Парсим тело запроса
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+	// Проверяем тело запроса
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	// Отправляем код по SMS, ответ не зависит от того, зарегистрирован ли номер
+	if err := h.services.AuthService.RequestLoginCode(c.Context(), input); err != nil {
+		if errors.Is(err, services.ErrTooManyRequests) {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		h.log.Error().Err(err).Msg("error requesting login code")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "error generating tokens",
+			"message": "error requesting login code",
 		})
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "ok",
-		"details": fiber.Map{
-			"access_token": accessToken,
-		},
 	})
 }
+
+func (h *Handler) loginWithCode(c *fiber.Ctx) error {
+	var input entity.PhoneLogin
+	// Парсим тело запроса
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+	// Проверяем тело запроса
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	// Обмениваем код на пользователя
+	result, err := h.services.AuthService.LoginWithCode(c.Context(), input)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error logging in with code")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "invalid or expired code",
+		})
+	}
+	// Создаем токены или просим второй фактор
+	return h.respondWithLoginResult(c, result)
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"backend-service/internal/entity"
+	"bytes"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"strconv"
+	"time"
+)
+
+// getBusinessHours возвращает недельный график работы филиала.
+func (h *Handler) getBusinessHours(c *fiber.Ctx) error {
+	locationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing location id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing location id",
+		})
+	}
+
+	hours, err := h.services.CalendarService.GetBusinessHours(c.Context(), locationID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting business hours")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": hours,
+	})
+}
+
+// setBusinessHours заменяет недельный график филиала целиком. Дни, которых нет в списке, — выходные.
+func (h *Handler) setBusinessHours(c *fiber.Ctx) error {
+	locationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing location id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing location id",
+		})
+	}
+
+	if !h.canManageAt(c, locationID, entity.PermissionCalendarManage) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+
+	var input entity.WeeklyBusinessHours
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	if err := h.services.CalendarService.SetBusinessHours(c.Context(), locationID, &input); err != nil {
+		h.log.Error().Err(err).Msg("error setting business hours")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+// getCalendarDays возвращает праздники и перенесенные дни за год, по умолчанию — текущий.
+func (h *Handler) getCalendarDays(c *fiber.Ctx) error {
+	year, err := calendarYear(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	days, err := h.services.CalendarService.GetDays(c.Context(), year)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting calendar days")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": days,
+	})
+}
+
+func (h *Handler) setCalendarDay(c *fiber.Ctx) error {
+	var day entity.CalendarDay
+	if err := c.BodyParser(&day); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+	day.Date = c.Params("date")
+
+	if err := day.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	if err := h.services.CalendarService.SetDay(c.Context(), &day); err != nil {
+		h.log.Error().Err(err).Msg("error setting calendar day")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+func (h *Handler) deleteCalendarDay(c *fiber.Ctx) error {
+	date := c.Params("date")
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "invalid date, expected YYYY-MM-DD",
+		})
+	}
+
+	if err := h.services.CalendarService.DeleteDay(c.Context(), date); err != nil {
+		h.log.Error().Err(err).Msg("error deleting calendar day")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+// importCalendar загружает производственный календарь в формате CSV с data.gov.ru.
+// Файл передается телом запроса, год — параметром year.
+func (h *Handler) importCalendar(c *fiber.Ctx) error {
+	year, err := calendarYear(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	if len(c.Body()) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "calendar file is required",
+		})
+	}
+
+	imported, err := h.services.CalendarService.Import(c.Context(), year, bytes.NewReader(c.Body()))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error importing calendar")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": fiber.Map{
+			"imported": imported,
+		},
+	})
+}
+
+func calendarYear(c *fiber.Ctx) (int, error) {
+	value := c.Query("year")
+	if value == "" {
+		return time.Now().Year(), nil
+	}
+
+	year, err := strconv.Atoi(value)
+	if err != nil || year < 2000 || year > 2100 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "invalid year")
+	}
+	return year, nil
+}
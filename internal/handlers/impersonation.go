@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"backend-service/internal/services"
+	"backend-service/pkg/jwt"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func (h *Handler) impersonateUser(c *fiber.Ctx) error {
+	actorID, err := uuid.Parse(c.Locals("UID").(string))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	targetID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+	// Проверяем цель и записываем начало имперсонации в журнал
+	user, err := h.services.ImpersonationService.Start(c.Context(), actorID, targetID, c.IP())
+	if err != nil {
+		if errors.Is(err, services.ErrImpersonationNotAllowed) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		h.log.Error().Err(err).Msg("error starting impersonation")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	// Выдаем только короткоживущий access-токен, без refresh-токена и сессии
+	accessToken, err := h.jwtService.GenerateImpersonationToken(jwt.Subject{
+		UserId: user.ID.String(),
+		Role:   string(user.Role),
+	}, actorID.String())
+	if err != nil {
+		h.log.Error().Err(err).Msg("error generating impersonation token")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "error generating tokens",
+		})
+	}
+
+	h.log.Info().
+		Str("actor_id", actorID.String()).
+		Str("user_id", user.ID.String()).
+		Msg("impersonation started")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": fiber.Map{
+			"access_token": accessToken,
+		},
+	})
+}
+
+func (h *Handler) getImpersonationLog(c *fiber.Ctx) error {
+	targetID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	entries, err := h.services.ImpersonationService.GetLog(c.Context(), targetID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting impersonation log")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "error getting impersonation log",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": entries,
+	})
+}
@@ -0,0 +1,12 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// getJWKS отдает публичные ключи подписи, чтобы другие сервисы
+// проверяли токены без общего секрета.
+func (h *Handler) getJWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(fiber.StatusOK).JSON(h.jwtService.JWKS())
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/internal/services"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func (h *Handler) getLocations(c *fiber.Ctx) error {
+	locations, err := h.services.LocationService.GetAll(c.Context())
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting locations")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": locations,
+	})
+}
+
+func (h *Handler) getLocation(c *fiber.Ctx) error {
+	locationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing location id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing location id",
+		})
+	}
+
+	location, err := h.services.LocationService.GetById(c.Context(), locationID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting location")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": location,
+	})
+}
+
+// createLocation открывает филиал. Часы работы, посты и услуги задаются
+// для него отдельно, до этого записаться в филиал нельзя.
+func (h *Handler) createLocation(c *fiber.Ctx) error {
+	var location entity.Location
+	if err := c.BodyParser(&location); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := location.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	locationID, err := h.services.LocationService.Create(c.Context(), &location)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error creating location")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "ok",
+		"details": fiber.Map{
+			"id": locationID,
+		},
+	})
+}
+
+// updateLocation меняет название, адрес и часовой пояс филиала, например Europe/Moscow.
+func (h *Handler) updateLocation(c *fiber.Ctx) error {
+	locationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing location id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing location id",
+		})
+	}
+
+	var location entity.Location
+	if err := c.BodyParser(&location); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := location.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	location.ID = locationID
+
+	if err := h.services.LocationService.Update(c.Context(), &location); err != nil {
+		h.log.Error().Err(err).Msg("error updating location")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+// deleteLocation закрывает филиал, если в нем не осталось предстоящих записей.
+func (h *Handler) deleteLocation(c *fiber.Ctx) error {
+	locationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing location id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing location id",
+		})
+	}
+
+	if err := h.services.LocationService.Delete(c.Context(), locationID); err != nil {
+		if errors.Is(err, services.ErrLocationInUse) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		h.log.Error().Err(err).Msg("error deleting location")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"backend-service/internal/entity"
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 func (h *Handler) middlewareAuth(c *fiber.Ctx) error {
@@ -14,16 +18,271 @@ func (h *Handler) middlewareAuth(c *fiber.Ctx) error {
 		})
 	}
 	// Убираем префикс Bearer
-	accessToken = accessToken[7:]
-	// Валидируем accessToken
-	userId, err := h.jwtService.ValidateJWT(accessToken, "access")
+	accessToken = strings.TrimPrefix(accessToken, "Bearer ")
+	// Валидируем accessToken, включая проверку по списку отзыва
+	claims, err := h.jwtService.ValidateJWT(accessToken, "access")
 	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"message": "invalid access token",
 		})
 	}
-	// Сохраняем userId в контексте
-	c.Locals("UID", userId)
-	// Пропускаем запрос
+	// Сохраняем userId, роль, сессию и сам токен в контексте
+	c.Locals("UID", claims.UserId)
+	c.Locals("Role", entity.Role(claims.Role))
+	c.Locals("SessionID", claims.SessionId)
+	c.Locals("MFA", claims.MFA)
+	// Для ролей с обязательной 2FA привилегии действуют только после входа с TOTP
+	if !claims.MFA && h.services.TwoFactorService.IsRequired(entity.Role(claims.Role)) {
+		c.Locals("TwoFactorPending", true)
+	}
+	c.Locals("AccessToken", accessToken)
+	// Обычный токен: пропускаем запрос
+	if claims.Actor == nil {
+		return c.Next()
+	}
+	// Токен имперсонации: запоминаем администратора и журналируем его действия
+	c.Locals("ActorID", claims.Actor.UserId)
+	return h.auditImpersonation(c)
+}
+
+// auditImpersonation выполняет запрос и записывает в журнал каждое изменение,
+// сделанное администратором от имени пользователя, вместе с итоговым статусом.
+func (h *Handler) auditImpersonation(c *fiber.Ctx) error {
+	method := c.Method()
+	path := c.Path()
+
+	err := c.Next()
+
+	if method == fiber.MethodGet || method == fiber.MethodHead || method == fiber.MethodOptions {
+		return err
+	}
+
+	actorID, parseErr := uuid.Parse(c.Locals("ActorID").(string))
+	if parseErr != nil {
+		h.log.Error().Err(parseErr).Msg("error parsing actor id")
+		return err
+	}
+	userID, parseErr := uuid.Parse(c.Locals("UID").(string))
+	if parseErr != nil {
+		h.log.Error().Err(parseErr).Msg("error parsing user id")
+		return err
+	}
+
+	entry := &entity.ImpersonationAuditEntry{
+		ActorID: actorID,
+		UserID:  userID,
+		Action:  entity.ImpersonationActionRequest,
+		Method:  method,
+		Path:    path,
+		Status:  c.Response().StatusCode(),
+		IP:      c.IP(),
+	}
+	if auditErr := h.services.ImpersonationService.Record(c.Context(), entry); auditErr != nil {
+		h.log.Error().Err(auditErr).Msg("error recording impersonation audit")
+	}
+	h.log.Info().
+		Str("actor_id", actorID.String()).
+		Str("user_id", userID.String()).
+		Str("method", method).
+		Str("path", path).
+		Int("status", entry.Status).
+		Msg("impersonated request")
+
+	return err
+}
+
+// forbidImpersonation запрещает действие, если запрос выполнен по токену имперсонации.
+// Ставится после middlewareAuth на операции, которые может выполнить только сам владелец аккаунта.
+func (h *Handler) forbidImpersonation(c *fiber.Ctx) error {
+	if _, ok := c.Locals("ActorID").(string); ok {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "action is not allowed while impersonating",
+		})
+	}
 	return c.Next()
 }
+
+// middlewareAuthOrAPIKey принимает либо API-ключ из заголовка X-API-Key,
+// либо пользовательский access-токен. Ключ не принадлежит пользователю:
+// UID в контексте нулевой, а права ограничены разрешениями ключа.
+func (h *Handler) middlewareAuthOrAPIKey(c *fiber.Ctx) error {
+	rawKey := c.Get("X-API-Key")
+	if rawKey == "" {
+		return h.middlewareAuth(c)
+	}
+
+	key, err := h.services.APIKeyService.Authenticate(c.Context(), rawKey)
+	if err != nil {
+		h.log.Warn().Err(err).Msg("error authenticating api key")
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "invalid api key",
+		})
+	}
+
+	c.Locals("UID", uuid.Nil.String())
+	c.Locals("APIKey", key)
+	return c.Next()
+}
+
+// forbidAPIKey запрещает действие по API-ключу. Ставится после
+// middlewareAuthOrAPIKey на операции от имени владельца: у ключа нет
+// пользователя, от которого можно записаться или добавить автомобиль.
+func (h *Handler) forbidAPIKey(c *fiber.Ctx) error {
+	if isAPIKey(c) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "action requires a user account",
+		})
+	}
+	return c.Next()
+}
+
+// isAPIKey сообщает, выполнен ли запрос по API-ключу.
+func isAPIKey(c *fiber.Ctx) bool {
+	_, ok := c.Locals("APIKey").(*entity.APIKey)
+	return ok
+}
+
+// RequirePermission пропускает запрос, только если роль пользователя
+// дает указанное разрешение. Ставится после middlewareAuth.
+func (h *Handler) RequirePermission(permission entity.Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if pending, _ := c.Locals("TwoFactorPending").(bool); pending {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "two-factor authentication required",
+			})
+		}
+		if !hasPermission(c, permission) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "forbidden",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// hasPermission проверяет разрешение у роли текущего пользователя
+// или, при доступе по API-ключу, в списке разрешений ключа. Пока не пройден
+// обязательный для роли второй фактор, разрешений у нее нет.
+func hasPermission(c *fiber.Ctx, permission entity.Permission) bool {
+	if key, ok := c.Locals("APIKey").(*entity.APIKey); ok {
+		return key.HasPermission(permission)
+	}
+	if pending, _ := c.Locals("TwoFactorPending").(bool); pending {
+		return false
+	}
+	role, ok := c.Locals("Role").(entity.Role)
+	if !ok {
+		return false
+	}
+	return role.HasPermission(permission)
+}
+
+// canAccess разрешает доступ владельцу ресурса или роли с указанным разрешением.
+// API-ключ владельцем не бывает.
+func canAccess(c *fiber.Ctx, ownerID uuid.UUID, permission entity.Permission) bool {
+	if uid, ok := c.Locals("UID").(string); ok && !isAPIKey(c) && uid == ownerID.String() {
+		return true
+	}
+	return hasPermission(c, permission)
+}
+
+// currentActor возвращает автора действия для журналов: API-ключ, при
+// имперсонации — администратора, иначе текущего пользователя.
+func currentActor(c *fiber.Ctx) entity.Actor {
+	if key, ok := c.Locals("APIKey").(*entity.APIKey); ok {
+		return entity.Actor{APIKeyID: &key.ID}
+	}
+	uid, _ := c.Locals("UID").(string)
+	if actorID, ok := c.Locals("ActorID").(string); ok {
+		uid = actorID
+	}
+	userID, err := uuid.Parse(uid)
+	if err != nil {
+		return entity.Actor{}
+	}
+	return entity.Actor{UserID: &userID}
+}
+
+// staffLocation возвращает филиал, которым ограничены права текущего
+// пользователя, или nil, если он работает во всех филиалах. У API-ключа
+// ограничения по филиалу нет. Филиал читается из базы один раз за запрос.
+func (h *Handler) staffLocation(c *fiber.Ctx) (*uuid.UUID, error) {
+	if isAPIKey(c) {
+		return nil, nil
+	}
+	if locationID, ok := c.Locals("LocationID").(*uuid.UUID); ok {
+		return locationID, nil
+	}
+
+	userID, err := uuid.Parse(c.Locals("UID").(string))
+	if err != nil {
+		return nil, err
+	}
+	user, err := h.services.UserRoleService.GetById(c.Context(), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Locals("LocationID", user.LocationID)
+	return user.LocationID, nil
+}
+
+// canManageAt проверяет разрешение с учетом филиала: сотрудник с филиалом
+// пользуется им только в своем филиале.
+func (h *Handler) canManageAt(c *fiber.Ctx, locationID uuid.UUID, permission entity.Permission) bool {
+	if !hasPermission(c, permission) {
+		return false
+	}
+
+	scope, err := h.staffLocation(c)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting staff location")
+		return false
+	}
+	return scope == nil || *scope == locationID
+}
+
+// canAccessAt разрешает доступ владельцу ресурса в любом филиале, а остальным —
+// при разрешении, действующем в филиале ресурса.
+func (h *Handler) canAccessAt(c *fiber.Ctx, ownerID, locationID uuid.UUID, permission entity.Permission) bool {
+	if uid, ok := c.Locals("UID").(string); ok && !isAPIKey(c) && uid == ownerID.String() {
+		return true
+	}
+	return h.canManageAt(c, locationID, permission)
+}
+
+// locationFilter читает необязательный фильтр списка ?location_id. Сотруднику
+// с филиалом по умолчанию показывается его филиал, а чужой запрещен.
+func (h *Handler) locationFilter(c *fiber.Ctx) (*uuid.UUID, *fiber.Error) {
+	locationID, err := locationQuery(c)
+	if err != nil {
+		return nil, err
+	}
+
+	scope, scopeErr := h.staffLocation(c)
+	if scopeErr != nil {
+		h.log.Error().Err(scopeErr).Msg("error getting staff location")
+		return nil, fiber.NewError(fiber.StatusInternalServerError, scopeErr.Error())
+	}
+	if scope == nil {
+		return locationID, nil
+	}
+	if locationID != nil && *locationID != *scope {
+		return nil, fiber.NewError(fiber.StatusForbidden, "forbidden")
+	}
+	return scope, nil
+}
+
+// locationQuery читает необязательный параметр ?location_id.
+func locationQuery(c *fiber.Ctx) (*uuid.UUID, *fiber.Error) {
+	value := c.Query("location_id")
+	if value == "" {
+		return nil, nil
+	}
+
+	locationID, err := uuid.Parse(value)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "error parsing location id")
+	}
+	return &locationID, nil
+}
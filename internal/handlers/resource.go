@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/internal/services"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func (h *Handler) createResource(c *fiber.Ctx) error {
+	var resource entity.Resource
+	if err := c.BodyParser(&resource); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := resource.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	if !h.canManageAt(c, resource.LocationID, entity.PermissionResourcesManage) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+
+	resourceID, err := h.services.ResourceService.Create(c.Context(), &resource)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error creating resource")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "ok",
+		"details": fiber.Map{
+			"id": resourceID,
+		},
+	})
+}
+
+// getResources возвращает посты филиала из ?location_id, без него — всех.
+// Сотруднику с филиалом доступен только его филиал.
+func (h *Handler) getResources(c *fiber.Ctx) error {
+	locationID, ferr := h.locationFilter(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"message": ferr.Message,
+		})
+	}
+
+	resources, err := h.services.ResourceService.GetAll(c.Context(), locationID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting resources")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": resources,
+	})
+}
+
+func (h *Handler) updateResource(c *fiber.Ctx) error {
+	resourceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing resource id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing resource id",
+		})
+	}
+
+	var resource entity.Resource
+	if err := c.BodyParser(&resource); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := resource.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	resource.ID = resourceID
+
+	// Пост остается в области прав сотрудника и до, и после изменения
+	current, err := h.services.ResourceService.GetById(c.Context(), resourceID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	if !h.canManageAt(c, current.LocationID, entity.PermissionResourcesManage) ||
+		!h.canManageAt(c, resource.LocationID, entity.PermissionResourcesManage) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+
+	if err := h.services.ResourceService.Update(c.Context(), &resource); err != nil {
+		if errors.Is(err, services.ErrResourceInUse) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		h.log.Error().Err(err).Msg("error updating resource")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+func (h *Handler) deleteResource(c *fiber.Ctx) error {
+	resourceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing resource id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing resource id",
+		})
+	}
+
+	resource, err := h.services.ResourceService.GetById(c.Context(), resourceID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	if !h.canManageAt(c, resource.LocationID, entity.PermissionResourcesManage) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+
+	if err := h.services.ResourceService.Delete(c.Context(), resourceID); err != nil {
+		if errors.Is(err, services.ErrResourceInUse) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		h.log.Error().Err(err).Msg("error deleting resource")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+func (h *Handler) getServiceResourceTypes(c *fiber.Ctx) error {
+	serviceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing service id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing service id",
+		})
+	}
+
+	resourceTypes, err := h.services.ResourceService.GetServiceTypes(c.Context(), serviceID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting service resource types")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": entity.ServiceResourceTypes{ResourceTypes: resourceTypes},
+	})
+}
+
+// setServiceResourceTypes задает, какие посты и оборудование занимает услуга.
+// Пустой список означает, что услуга не занимает ресурсов.
+func (h *Handler) setServiceResourceTypes(c *fiber.Ctx) error {
+	serviceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing service id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing service id",
+		})
+	}
+
+	var input entity.ServiceResourceTypes
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	service, err := h.services.ServiceService.GetById(c.Context(), serviceID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	if !h.canManageAt(c, service.LocationID, entity.PermissionServicesManage) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+
+	if err := h.services.ResourceService.SetServiceTypes(c.Context(), serviceID, &input); err != nil {
+		h.log.Error().Err(err).Msg("error setting service resource types")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
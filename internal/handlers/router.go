@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"backend-service/internal/entity"
 	"backend-service/internal/services"
 	"backend-service/pkg/jwt"
 	"backend-service/pkg/s3"
@@ -42,6 +43,8 @@ func (h *Handler) InitRoutes(port string) {
 		Max:        10,
 	}))
 
+	app.Get("/.well-known/jwks.json", h.getJWKS)
+
 	api := app.Group("/tss/api/v1")
 	{
 		api.Get("/", func(ctx *fiber.Ctx) error {
@@ -60,29 +63,122 @@ func (h *Handler) InitRoutes(port string) {
 
 			auth.Post("/register", h.register)
 			auth.Post("/login", h.login)
+			auth.Post("/login/phone", h.requestLoginCode)
+			auth.Post("/login/phone/confirm", h.loginWithCode)
+			auth.Post("/login/2fa", h.completeTwoFactorLogin)
+			auth.Post("/refresh", h.refresh)
+			auth.Post("/logout", h.middlewareAuth, h.logout)
+			auth.Post("/logout-all", h.middlewareAuth, h.logoutAll)
+			auth.Post("/password/reset", h.requestPasswordReset)
+			auth.Post("/password/reset/confirm", h.confirmPasswordReset)
 		}
 
 		userProfile := api.Group("/profile")
 		{
 			userProfile.Get("/", h.middlewareAuth, h.getProfile)
+			userProfile.Patch("/", h.middlewareAuth, h.forbidImpersonation, h.updateProfile)
+			userProfile.Delete("/", h.middlewareAuth, h.forbidImpersonation, h.deleteAccount)
+			userProfile.Put("/password", h.middlewareAuth, h.forbidImpersonation, h.changePassword)
+			userProfile.Post("/2fa/totp", h.middlewareAuth, h.forbidImpersonation, h.enrollTOTP)
+			userProfile.Post("/2fa/totp/confirm", h.middlewareAuth, h.forbidImpersonation, h.confirmTOTP)
+			userProfile.Delete("/2fa/totp", h.middlewareAuth, h.forbidImpersonation, h.disableTOTP)
+			userProfile.Post("/2fa/recovery-codes", h.middlewareAuth, h.forbidImpersonation, h.regenerateRecoveryCodes)
+			userProfile.Get("/sessions", h.middlewareAuth, h.getSessions)
+			userProfile.Delete("/sessions/:id", h.middlewareAuth, h.deleteSession)
+			userProfile.Post("/verify/:channel", h.middlewareAuth, h.sendVerificationCode)
+			userProfile.Post("/verify/:channel/confirm", h.middlewareAuth, h.confirmVerificationCode)
+		}
+
+		users := api.Group("/users")
+		{
+			users.Use(h.middlewareAuth)
+
+			users.Put("/:id/role", h.RequirePermission(entity.PermissionUsersManage), h.setUserRole)
+			users.Put("/:id/location", h.RequirePermission(entity.PermissionUsersManage), h.setUserLocation)
+			users.Post("/:id/logout", h.RequirePermission(entity.PermissionUsersManage), h.revokeUserTokens)
+			users.Post("/:id/unlock", h.RequirePermission(entity.PermissionUsersManage), h.unlockUser)
+			users.Post("/:id/no-shows/reset", h.RequirePermission(entity.PermissionAppointmentsManageAll), h.resetUserNoShows)
+			users.Post("/:id/2fa/reset", h.RequirePermission(entity.PermissionUsersManage), h.resetUserTwoFactor)
+			users.Get("/:id/sessions", h.RequirePermission(entity.PermissionUsersManage), h.getUserSessions)
+			users.Delete("/:id/sessions/:sessionId", h.RequirePermission(entity.PermissionUsersManage), h.deleteUserSession)
+			users.Post("/:id/impersonate", h.RequirePermission(entity.PermissionUsersImpersonate), h.impersonateUser)
+			users.Get("/:id/impersonation-log", h.RequirePermission(entity.PermissionUsersImpersonate), h.getImpersonationLog)
+		}
+
+		apiKeys := api.Group("/api-keys")
+		{
+			apiKeys.Use(h.middlewareAuth, h.RequirePermission(entity.PermissionAPIKeysManage))
+
+			apiKeys.Post("/", h.createAPIKey)
+			apiKeys.Get("/", h.getAPIKeys)
+			apiKeys.Delete("/:id", h.revokeAPIKey)
+		}
+
+		staff := api.Group("/staff")
+		{
+			staff.Use(h.middlewareAuth)
+
+			staff.Get("/me/jobs", h.getMyJobs)
+			staff.Get("/", h.RequirePermission(entity.PermissionAppointmentsReadAll), h.getStaff)
+			staff.Get("/:id", h.RequirePermission(entity.PermissionAppointmentsReadAll), h.getStaffMember)
+			staff.Put("/:id", h.RequirePermission(entity.PermissionStaffManage), h.requireStaffInScope, h.updateStaffProfile)
+			staff.Put("/:id/schedule", h.RequirePermission(entity.PermissionStaffManage), h.requireStaffInScope, h.setStaffSchedule)
+			staff.Get("/:id/exceptions", h.RequirePermission(entity.PermissionAppointmentsReadAll), h.getStaffExceptions)
+			staff.Post("/:id/exceptions", h.RequirePermission(entity.PermissionStaffManage), h.requireStaffInScope, h.createStaffException)
+			staff.Delete("/:id/exceptions/:exceptionId", h.RequirePermission(entity.PermissionStaffManage), h.requireStaffInScope, h.deleteStaffException)
 		}
 
 		serv := api.Group("/services")
 		{
-			serv.Use(h.middlewareAuth)
+			serv.Use(h.middlewareAuthOrAPIKey)
 
 			serv.Get("/", h.getServices)
-			serv.Post("/", h.createService)
+			serv.Post("/", h.RequirePermission(entity.PermissionServicesManage), h.createService)
 			//serv.Get("/:id", h.getOne)
-			serv.Put("/:id", h.updateService)
-			serv.Delete("/:id", h.deleteService)
+			serv.Put("/:id", h.RequirePermission(entity.PermissionServicesManage), h.updateService)
+			serv.Delete("/:id", h.RequirePermission(entity.PermissionServicesManage), h.deleteService)
+			serv.Get("/:id/resource-types", h.getServiceResourceTypes)
+			serv.Put("/:id/resource-types", h.RequirePermission(entity.PermissionServicesManage), h.setServiceResourceTypes)
+		}
+
+		resources := api.Group("/resources")
+		{
+			resources.Use(h.middlewareAuth)
+
+			resources.Get("/", h.RequirePermission(entity.PermissionAppointmentsReadAll), h.getResources)
+			resources.Post("/", h.RequirePermission(entity.PermissionResourcesManage), h.createResource)
+			resources.Put("/:id", h.RequirePermission(entity.PermissionResourcesManage), h.updateResource)
+			resources.Delete("/:id", h.RequirePermission(entity.PermissionResourcesManage), h.deleteResource)
+		}
+
+		locations := api.Group("/locations")
+		{
+			locations.Use(h.middlewareAuthOrAPIKey)
+
+			locations.Get("/", h.getLocations)
+			locations.Post("/", h.RequirePermission(entity.PermissionLocationsManage), h.createLocation)
+			locations.Get("/:id", h.getLocation)
+			locations.Put("/:id", h.RequirePermission(entity.PermissionLocationsManage), h.updateLocation)
+			locations.Delete("/:id", h.RequirePermission(entity.PermissionLocationsManage), h.deleteLocation)
+			locations.Get("/:id/hours", h.getBusinessHours)
+			locations.Put("/:id/hours", h.RequirePermission(entity.PermissionCalendarManage), h.setBusinessHours)
+		}
+
+		calendar := api.Group("/calendar")
+		{
+			calendar.Use(h.middlewareAuthOrAPIKey)
+
+			calendar.Get("/days", h.getCalendarDays)
+			calendar.Put("/days/:date", h.RequirePermission(entity.PermissionCalendarManage), h.setCalendarDay)
+			calendar.Delete("/days/:date", h.RequirePermission(entity.PermissionCalendarManage), h.deleteCalendarDay)
+			calendar.Post("/import", h.RequirePermission(entity.PermissionCalendarManage), h.importCalendar)
 		}
 
 		vehicles := api.Group("/vehicles")
 		{
-			vehicles.Use(h.middlewareAuth)
+			vehicles.Use(h.middlewareAuthOrAPIKey)
 
-			vehicles.Post("/", h.createVehicle)
+			vehicles.Post("/", h.forbidAPIKey, h.createVehicle)
 			vehicles.Get("/", h.getVehicles)
 			vehicles.Get("/:id", h.getVehicle)
 			vehicles.Put("/:id", h.updateVehicle)
@@ -91,13 +187,36 @@ func (h *Handler) InitRoutes(port string) {
 
 		appointments := api.Group("/appointments")
 		{
-			appointments.Use(h.middlewareAuth)
+			appointments.Use(h.middlewareAuthOrAPIKey)
 
-			appointments.Post("/", h.createAppointment)
-			appointments.Get("/", h.getAppointments)
+			appointments.Post("/", h.forbidAPIKey, h.createAppointment)
+			appointments.Get("/", h.forbidAPIKey, h.getAppointments)
+			appointments.Get("/availability", h.getAvailability)
 			appointments.Get("/:id", h.getAppointment)
+			appointments.Get("/:id/history", h.getAppointmentHistory)
 			appointments.Put("/:id", h.updateAppointment)
 			appointments.Post("/:id/cancel", h.cancelAppointment)
+			appointments.Post("/:id/reschedule", h.rescheduleAppointment)
+			appointments.Get("/:id/reschedules", h.getAppointmentReschedules)
+			appointments.Put("/:id/mechanics", h.RequirePermission(entity.PermissionAppointmentsManageAll), h.setAppointmentMechanics)
+			appointments.Post("/:id/prepayment", h.RequirePermission(entity.PermissionAppointmentsManageAll), h.markAppointmentPrepaid)
+			appointments.Get("/:id/work-order", h.getWorkOrder)
+			appointments.Post("/:id/work-order", h.RequirePermission(entity.PermissionWorkOrdersManage), h.createWorkOrder)
+			appointments.Post("/:id/work-order/lines", h.RequirePermission(entity.PermissionWorkOrdersManage), h.addWorkOrderLine)
+			appointments.Put("/:id/work-order/lines/:lineId", h.RequirePermission(entity.PermissionWorkOrdersManage), h.updateWorkOrderLine)
+			appointments.Delete("/:id/work-order/lines/:lineId", h.RequirePermission(entity.PermissionWorkOrdersManage), h.deleteWorkOrderLine)
+			appointments.Post("/:id/work-order/close", h.RequirePermission(entity.PermissionWorkOrdersManage), h.closeWorkOrder)
+		}
+
+		waitlist := api.Group("/waitlist")
+		{
+			waitlist.Use(h.middlewareAuth)
+
+			waitlist.Post("/", h.joinWaitlist)
+			waitlist.Get("/", h.getWaitlist)
+			waitlist.Delete("/:id", h.leaveWaitlist)
+			waitlist.Post("/offers/:id/accept", h.acceptWaitlistOffer)
+			waitlist.Post("/offers/:id/decline", h.declineWaitlistOffer)
 		}
 
 		assets := api.Group("/assets")
@@ -109,10 +228,10 @@ func (h *Handler) InitRoutes(port string) {
 
 		clients := api.Group("/clients")
 		{
-			clients.Use(h.middlewareAuth)
+			clients.Use(h.middlewareAuthOrAPIKey)
 
 			// Добавляю endpoint для получения всех клиентов и их записей
-			clients.Get("/appointments", h.getAllClientsWithAppointments)
+			clients.Get("/appointments", h.RequirePermission(entity.PermissionClientsRead), h.getAllClientsWithAppointments)
 		}
 	}
 
@@ -20,23 +20,8 @@ func (h *Handler) createService(c *fiber.Ctx) error {
 			"message": err.Error(),
 		})
 	}
-	uidRaw := c.Locals("UID").(string)
-	userID, err := uuid.Parse(uidRaw)
-	if err != nil {
-		h.log.Error().Err(err).Msg("error parsing user id")
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "error parsing user id",
-		})
-	}
-	// Проверяем что admin
-	isAdmin, err := h.services.UserRoleService.IsAdmin(c.Context(), userID)
-	if err != nil {
-		h.log.Error().Err(err).Msg("error checking admin")
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"message": "forbidden",
-		})
-	}
-	if !isAdmin {
+	// Услуги филиала заводит сотрудник этого филиала
+	if !h.canManageAt(c, service.LocationID, entity.PermissionServicesManage) {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"message": "forbidden",
 		})
@@ -58,9 +43,16 @@ func (h *Handler) createService(c *fiber.Ctx) error {
 	})
 }
 
+// getServices возвращает услуги с ценами филиала из ?location_id, без него — всех филиалов.
 func (h *Handler) getServices(c *fiber.Ctx) error {
-	// Создаем услугу
-	services, err := h.services.ServiceService.GetAll(c.Context())
+	locationID, ferr := locationQuery(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"message": ferr.Message,
+		})
+	}
+	// Получаем услуги
+	services, err := h.services.ServiceService.GetAll(c.Context(), locationID)
 	if err != nil {
 		h.log.Error().Err(err).Msg("error getting service")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -97,28 +89,20 @@ func (h *Handler) updateService(c *fiber.Ctx) error {
 			"message": err.Error(),
 		})
 	}
-	uidRaw := c.Locals("UID").(string)
-	userID, err := uuid.Parse(uidRaw)
-	if err != nil {
-		h.log.Error().Err(err).Msg("error parsing user id")
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "error parsing user id",
-		})
-	}
-	// Проверяем что admin
-	isAdmin, err := h.services.UserRoleService.IsAdmin(c.Context(), userID)
+	service.ID = serviceId
+	// Услуга остается в области прав сотрудника и до, и после изменения
+	current, err := h.services.ServiceService.GetById(c.Context(), serviceId)
 	if err != nil {
-		h.log.Error().Err(err).Msg("error checking admin")
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"message": "forbidden",
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
 		})
 	}
-	if !isAdmin {
+	if !h.canManageAt(c, current.LocationID, entity.PermissionServicesManage) ||
+		!h.canManageAt(c, service.LocationID, entity.PermissionServicesManage) {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"message": "forbidden",
 		})
 	}
-	service.ID = serviceId
 	// Редактируем услугу
 	_, err = h.services.ServiceService.Update(c.Context(), &service)
 	if err != nil {
@@ -143,23 +127,13 @@ func (h *Handler) deleteService(c *fiber.Ctx) error {
 		})
 	}
 
-	uidRaw := c.Locals("UID").(string)
-	userID, err := uuid.Parse(uidRaw)
+	service, err := h.services.ServiceService.GetById(c.Context(), serviceId)
 	if err != nil {
-		h.log.Error().Err(err).Msg("error parsing user id")
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "error parsing user id",
-		})
-	}
-	// Проверяем что admin
-	isAdmin, err := h.services.UserRoleService.IsAdmin(c.Context(), userID)
-	if err != nil {
-		h.log.Error().Err(err).Msg("error checking admin")
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"message": "forbidden",
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
 		})
 	}
-	if !isAdmin {
+	if !h.canManageAt(c, service.LocationID, entity.PermissionServicesManage) {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"message": "forbidden",
 		})
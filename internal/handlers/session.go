@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// revokeSession закрывает сессию пользователя и отзывает выданные в ней токены.
+func (h *Handler) revokeSession(c *fiber.Ctx, userID, sessionID uuid.UUID) error {
+	if err := h.services.SessionService.Revoke(c.Context(), userID, sessionID); err != nil {
+		return err
+	}
+	return h.jwtService.RevokeSession(sessionID.String())
+}
+
+// revokeAllSessions закрывает все сессии пользователя и отзывает все его токены.
+func (h *Handler) revokeAllSessions(c *fiber.Ctx, userID uuid.UUID) error {
+	if err := h.jwtService.RevokeAll(userID.String()); err != nil {
+		return err
+	}
+	return h.services.SessionService.RevokeAll(c.Context(), userID)
+}
+
+func (h *Handler) getSessions(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Locals("UID").(string))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	sessions, err := h.services.SessionService.GetByUserId(c.Context(), userID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting sessions")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "error getting sessions",
+		})
+	}
+	// Помечаем сессию, из которой пришел запрос
+	currentID, _ := c.Locals("SessionID").(string)
+	for _, session := range sessions {
+		session.Current = session.ID.String() == currentID
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": sessions,
+	})
+}
+
+func (h *Handler) deleteSession(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Locals("UID").(string))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing session id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing session id",
+		})
+	}
+
+	if err := h.revokeSession(c, userID, sessionID); err != nil {
+		h.log.Error().Err(err).Msg("error revoking session")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+func (h *Handler) getUserSessions(c *fiber.Ctx) error {
+	targetID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	sessions, err := h.services.SessionService.GetByUserId(c.Context(), targetID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting user sessions")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "error getting user sessions",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": sessions,
+	})
+}
+
+func (h *Handler) deleteUserSession(c *fiber.Ctx) error {
+	targetID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	sessionID, err := uuid.Parse(c.Params("sessionId"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing session id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing session id",
+		})
+	}
+
+	if err := h.revokeSession(c, targetID, sessionID); err != nil {
+		h.log.Error().Err(err).Msg("error revoking user session")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
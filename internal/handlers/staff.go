@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/internal/services"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"time"
+)
+
+// getStaff возвращает сотрудников филиала из ?location_id, без него — всех.
+// Сотруднику с филиалом доступен только его филиал.
+func (h *Handler) getStaff(c *fiber.Ctx) error {
+	locationID, ferr := h.locationFilter(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"message": ferr.Message,
+		})
+	}
+
+	staff, err := h.services.StaffService.GetAll(c.Context(), locationID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting staff")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": staff,
+	})
+}
+
+func (h *Handler) getStaffMember(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	profile, err := h.services.StaffService.GetById(c.Context(), userID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting staff profile")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": profile,
+	})
+}
+
+// updateStaffProfile создает или изменяет профиль сотрудника: навыки и активность.
+func (h *Handler) updateStaffProfile(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	var input entity.StaffProfileUpdate
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	profile, err := h.services.StaffService.Update(c.Context(), userID, &input)
+	if err != nil {
+		if errors.Is(err, services.ErrNotStaff) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		h.log.Error().Err(err).Msg("error updating staff profile")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": profile,
+	})
+}
+
+// setStaffSchedule заменяет недельный график сотрудника целиком.
+func (h *Handler) setStaffSchedule(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	var input entity.WeeklySchedule
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	if err := h.services.StaffService.SetSchedule(c.Context(), userID, &input); err != nil {
+		h.log.Error().Err(err).Msg("error setting staff schedule")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+func (h *Handler) getStaffExceptions(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	exceptions, err := h.services.StaffService.GetExceptions(c.Context(), userID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting schedule exceptions")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": exceptions,
+	})
+}
+
+// createStaffException добавляет отпуск, больничный или отгул.
+func (h *Handler) createStaffException(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	var exception entity.ScheduleException
+	if err := c.BodyParser(&exception); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+	exception.UserID = userID
+
+	if err := exception.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	exceptionID, err := h.services.StaffService.CreateException(c.Context(), &exception)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error creating schedule exception")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "ok",
+		"details": fiber.Map{
+			"id": exceptionID,
+		},
+	})
+}
+
+func (h *Handler) deleteStaffException(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	exceptionID, err := uuid.Parse(c.Params("exceptionId"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing exception id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing exception id",
+		})
+	}
+
+	if err := h.services.StaffService.DeleteException(c.Context(), userID, exceptionID); err != nil {
+		h.log.Error().Err(err).Msg("error deleting schedule exception")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+// getMyJobs возвращает записи, назначенные текущему механику, за день.
+// По умолчанию — сегодня, другой день передается как date=YYYY-MM-DD.
+func (h *Handler) getMyJobs(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Locals("UID").(string))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	var day time.Time
+	if date := c.Query("date"); date != "" {
+		if day, err = time.Parse("2006-01-02", date); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "invalid date, expected YYYY-MM-DD",
+			})
+		}
+	}
+
+	jobs, err := h.services.StaffService.GetJobs(c.Context(), userID, day)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting jobs")
+		if errors.Is(err, services.ErrNoLocation) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": jobs,
+	})
+}
+
+// requireStaffInScope пропускает запрос к сотруднику :id, только если он
+// работает в филиале текущего пользователя. Сотрудником без филиала управляет
+// лишь тот, чьи права действуют во всех филиалах. Ставится после RequirePermission.
+func (h *Handler) requireStaffInScope(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	scope, err := h.staffLocation(c)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting staff location")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	if scope == nil {
+		return c.Next()
+	}
+
+	user, err := h.services.UserRoleService.GetById(c.Context(), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	if user.LocationID == nil || *user.LocationID != *scope {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+
+	return c.Next()
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/internal/services"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// twoFactorErrorStatus сопоставляет ошибки двухфакторной аутентификации с HTTP-статусами.
+func twoFactorErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidTwoFactorCode):
+		return fiber.StatusUnauthorized
+	case errors.Is(err, services.ErrInvalidPassword), errors.Is(err, services.ErrTwoFactorRequired):
+		return fiber.StatusForbidden
+	case errors.Is(err, services.ErrTwoFactorAlreadyEnabled), errors.Is(err, services.ErrTwoFactorNotEnabled):
+		return fiber.StatusConflict
+	case errors.Is(err, services.ErrTwoFactorUnavailable):
+		return fiber.StatusServiceUnavailable
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
+
+func (h *Handler) completeTwoFactorLogin(c *fiber.Ctx) error {
+	var input entity.TwoFactorLogin
+	// Парсим тело запроса
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+	// Проверяем тело запроса
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	// Проверяем второй фактор для начатого входа
+	user, err := h.services.TwoFactorService.CompleteLogin(c.Context(), input)
+	if err != nil {
+		h.log.Warn().Err(err).Msg("error completing two factor login")
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "invalid or expired two-factor code",
+		})
+	}
+	// Создаем токены с отметкой о пройденном втором факторе
+	return h.respondWithTokens(c, user.ID, user.Role, true)
+}
+
+func (h *Handler) enrollTOTP(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Locals("UID").(string))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	enrollment, err := h.services.TwoFactorService.Enroll(c.Context(), userID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error enrolling totp")
+		return c.Status(twoFactorErrorStatus(err)).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": enrollment,
+	})
+}
+
+func (h *Handler) confirmTOTP(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Locals("UID").(string))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	var input entity.TwoFactorCode
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	recoveryCodes, err := h.services.TwoFactorService.Confirm(c.Context(), userID, input.Code)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error confirming totp")
+		return c.Status(twoFactorErrorStatus(err)).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	// Текущая сессия заменяется новой, уже с пройденным вторым фактором
+	if sessionID, err := uuid.Parse(c.Locals("SessionID").(string)); err == nil {
+		if err := h.revokeSession(c, userID, sessionID); err != nil {
+			h.log.Error().Err(err).Msg("error revoking session")
+		}
+	}
+
+	tokens, err := h.issueTokens(c, userID, c.Locals("Role").(entity.Role), true)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error generating tokens")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "error generating tokens",
+		})
+	}
+	tokens["recovery_codes"] = recoveryCodes
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": tokens,
+	})
+}
+
+func (h *Handler) disableTOTP(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Locals("UID").(string))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	var input entity.TwoFactorDisable
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	if err := h.services.TwoFactorService.Disable(c.Context(), userID, &input); err != nil {
+		h.log.Error().Err(err).Msg("error disabling totp")
+		return c.Status(twoFactorErrorStatus(err)).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+func (h *Handler) regenerateRecoveryCodes(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Locals("UID").(string))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	var input entity.TwoFactorCode
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	recoveryCodes, err := h.services.TwoFactorService.RegenerateRecoveryCodes(c.Context(), userID, input.Code)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error regenerating recovery codes")
+		return c.Status(twoFactorErrorStatus(err)).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": fiber.Map{
+			"recovery_codes": recoveryCodes,
+		},
+	})
+}
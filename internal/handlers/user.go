@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"backend-service/internal/entity"
+	"backend-service/internal/services"
+	"errors"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
@@ -54,3 +57,381 @@ func (h *Handler) getAllClientsWithAppointments(c *fiber.Ctx) error {
 		"details": result,
 	})
 }
+
+func (h *Handler) revokeUserTokens(c *fiber.Ctx) error {
+	targetID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	// Отзываем все токены пользователя
+	if err := h.revokeAllSessions(c, targetID); err != nil {
+		h.log.Error().Err(err).Msg("error revoking user tokens")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "error revoking user tokens",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+func (h *Handler) setUserRole(c *fiber.Ctx) error {
+	targetID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	var input entity.RoleUpdate
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	if err := h.services.UserRoleService.SetRole(c.Context(), targetID, input.Role); err != nil {
+		h.log.Error().Err(err).Msg("error setting user role")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	// Роль вшита в токены, поэтому выданные ранее токены отзываем
+	if err := h.revokeAllSessions(c, targetID); err != nil {
+		h.log.Error().Err(err).Msg("error revoking user tokens")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+// setUserLocation ограничивает права сотрудника филиалом. Филиал читается
+// при каждом запросе, поэтому выданные токены остаются в силе.
+func (h *Handler) setUserLocation(c *fiber.Ctx) error {
+	targetID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	var input entity.UserLocationUpdate
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := h.services.UserRoleService.SetLocation(c.Context(), targetID, &input); err != nil {
+		h.log.Error().Err(err).Msg("error setting user location")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+func (h *Handler) unlockUser(c *fiber.Ctx) error {
+	targetID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+	// Снимаем блокировку входа и сбрасываем счетчик неудачных попыток
+	if err := h.services.AuthService.UnlockAccount(c.Context(), targetID); err != nil {
+		h.log.Error().Err(err).Msg("error unlocking user")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+// resetUserNoShows обнуляет счетчик неявок клиента и тем снимает ограничения на запись.
+func (h *Handler) resetUserNoShows(c *fiber.Ctx) error {
+	targetID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	scope, err := h.staffLocation(c)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting staff location")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	if err := h.services.UserRoleService.ResetNoShows(c.Context(), targetID, scope); err != nil {
+		h.log.Error().Err(err).Msg("error resetting user no-shows")
+		if errors.Is(err, services.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+func (h *Handler) sendVerificationCode(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Locals("UID").(string))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	channel := entity.VerificationChannel(c.Params("channel"))
+	if err := channel.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	if err := h.services.VerificationService.SendCode(c.Context(), userID, channel); err != nil {
+		if errors.Is(err, services.ErrTooManyRequests) {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		h.log.Error().Err(err).Msg("error sending verification code")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+func (h *Handler) confirmVerificationCode(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Locals("UID").(string))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	channel := entity.VerificationChannel(c.Params("channel"))
+	if err := channel.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	var input entity.VerificationConfirm
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	if err := h.services.VerificationService.Confirm(c.Context(), userID, channel, input.Code); err != nil {
+		h.log.Error().Err(err).Msg("error confirming verification code")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+func (h *Handler) updateProfile(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Locals("UID").(string))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	var input entity.UserProfileUpdate
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	user, err := h.services.ProfileService.Update(c.Context(), userID, &input)
+	if err != nil {
+		if errors.Is(err, services.ErrEmailTaken) || errors.Is(err, services.ErrPhoneTaken) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		h.log.Error().Err(err).Msg("error updating profile")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": user.ToProfileResponse(),
+	})
+}
+
+func (h *Handler) changePassword(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Locals("UID").(string))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	var input entity.PasswordChange
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	user, err := h.services.ProfileService.ChangePassword(c.Context(), userID, &input)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidPassword) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		h.log.Error().Err(err).Msg("error changing password")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	// Отзываем все сессии и выдаем текущему устройству новую пару токенов
+	if err := h.revokeAllSessions(c, userID); err != nil {
+		h.log.Error().Err(err).Msg("error revoking tokens")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "error revoking tokens",
+		})
+	}
+
+	mfa, _ := c.Locals("MFA").(bool)
+	return h.respondWithTokens(c, userID, user.Role, mfa)
+}
+
+func (h *Handler) deleteAccount(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Locals("UID").(string))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	var input entity.AccountDelete
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	if err := h.services.ProfileService.Delete(c.Context(), userID, &input); err != nil {
+		if errors.Is(err, services.ErrInvalidPassword) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		h.log.Error().Err(err).Msg("error deleting account")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	// Удаленный аккаунт не должен оставаться залогиненным
+	if err := h.revokeAllSessions(c, userID); err != nil {
+		h.log.Error().Err(err).Msg("error revoking tokens")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+func (h *Handler) resetUserTwoFactor(c *fiber.Ctx) error {
+	targetID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+	// Сбрасываем TOTP и коды восстановления, пользователь подключит 2FA заново
+	if err := h.services.TwoFactorService.Reset(c.Context(), targetID); err != nil {
+		h.log.Error().Err(err).Msg("error resetting two factor")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	// Сессии могли быть открыты тем, кто завладел устройством, — завершаем все
+	if err := h.revokeAllSessions(c, targetID); err != nil {
+		h.log.Error().Err(err).Msg("error revoking user tokens")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "error revoking user tokens",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
@@ -57,21 +57,15 @@ func (h *Handler) getVehicles(c *fiber.Ctx) error {
 	all := c.Query("all") == "true"
 	var vehicles []*entity.Vehicle
 
-	if all {
-		// Check if user is admin
-		isAdmin, err := h.services.UserRoleService.IsAdmin(c.Context(), userID)
-		if err != nil {
-			h.log.Error().Err(err).Msg("error checking admin status")
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"message": "error checking admin status",
-			})
-		}
-
-		if isAdmin {
-			vehicles, err = h.services.VehicleService.GetAll(c.Context())
-		} else {
-			vehicles, err = h.services.VehicleService.GetByUserId(c.Context(), userID)
-		}
+	// API-ключ своих автомобилей не имеет и видит только полный список
+	if isAPIKey(c) && !(all && hasPermission(c, entity.PermissionVehiclesReadAll)) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+
+	if all && hasPermission(c, entity.PermissionVehiclesReadAll) {
+		vehicles, err = h.services.VehicleService.GetAll(c.Context())
 	} else {
 		vehicles, err = h.services.VehicleService.GetByUserId(c.Context(), userID)
 	}
@@ -107,15 +101,7 @@ func (h *Handler) getVehicle(c *fiber.Ctx) error {
 	}
 
 	// Check if the vehicle belongs to the requesting user
-	userID, err := uuid.Parse(c.Locals("UID").(string))
-	if err != nil {
-		h.log.Error().Err(err).Msg("error parsing user id")
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "error parsing user id",
-		})
-	}
-
-	if vehicle.UserID != userID {
+	if !canAccess(c, vehicle.UserID, entity.PermissionVehiclesReadAll) {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"message": "forbidden",
 		})
@@ -145,15 +131,7 @@ func (h *Handler) updateVehicle(c *fiber.Ctx) error {
 		})
 	}
 
-	userID, err := uuid.Parse(c.Locals("UID").(string))
-	if err != nil {
-		h.log.Error().Err(err).Msg("error parsing user id")
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "error parsing user id",
-		})
-	}
-
-	if vehicle.UserID != userID {
+	if !canAccess(c, vehicle.UserID, entity.PermissionVehiclesManageAll) {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"message": "forbidden",
 		})
@@ -202,15 +180,7 @@ func (h *Handler) deleteVehicle(c *fiber.Ctx) error {
 		})
 	}
 
-	userID, err := uuid.Parse(c.Locals("UID").(string))
-	if err != nil {
-		h.log.Error().Err(err).Msg("error parsing user id")
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "error parsing user id",
-		})
-	}
-
-	if vehicle.UserID != userID {
+	if !canAccess(c, vehicle.UserID, entity.PermissionVehiclesManageAll) {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"message": "forbidden",
 		})
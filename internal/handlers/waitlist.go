@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/internal/services"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// joinWaitlist ставит клиента в лист ожидания, когда на нужные даты нет свободного времени.
+func (h *Handler) joinWaitlist(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Locals("UID").(string))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	var input entity.WaitlistEntryCreate
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	entryID, err := h.services.WaitlistService.Join(c.Context(), userID, &input)
+	if err != nil {
+		if errors.Is(err, services.ErrContactNotVerified) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		h.log.Error().Err(err).Msg("error joining waitlist")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "ok",
+		"details": fiber.Map{
+			"id": entryID,
+		},
+	})
+}
+
+// getWaitlist возвращает заявки клиента вместе с действующими предложениями.
+func (h *Handler) getWaitlist(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Locals("UID").(string))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	entries, err := h.services.WaitlistService.GetByUserId(c.Context(), userID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting waitlist")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": entries,
+	})
+}
+
+func (h *Handler) leaveWaitlist(c *fiber.Ctx) error {
+	entryID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing waitlist entry id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing waitlist entry id",
+		})
+	}
+
+	entry, err := h.services.WaitlistService.GetById(c.Context(), entryID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting waitlist entry")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	if !h.canAccessAt(c, entry.UserID, entry.LocationID, entity.PermissionAppointmentsManageAll) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+
+	if err := h.services.WaitlistService.Leave(c.Context(), entryID); err != nil {
+		if errors.Is(err, services.ErrWaitlistEntryNotWaiting) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		h.log.Error().Err(err).Msg("error leaving waitlist")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+// acceptWaitlistOffer записывает клиента на зарезервированное для него время.
+func (h *Handler) acceptWaitlistOffer(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Locals("UID").(string))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	offerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing offer id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing offer id",
+		})
+	}
+
+	appointment, err := h.services.AppointmentService.AcceptWaitlistOffer(c.Context(), userID, offerID, currentActor(c))
+	if err != nil {
+		return h.waitlistOfferError(c, err, "error accepting waitlist offer")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": appointment,
+	})
+}
+
+// declineWaitlistOffer отказывается от предложенного времени, заявка остается в очереди.
+func (h *Handler) declineWaitlistOffer(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Locals("UID").(string))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing user id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing user id",
+		})
+	}
+
+	offerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing offer id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing offer id",
+		})
+	}
+
+	if err := h.services.AppointmentService.DeclineWaitlistOffer(c.Context(), userID, offerID, currentActor(c)); err != nil {
+		return h.waitlistOfferError(c, err, "error declining waitlist offer")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+	})
+}
+
+func (h *Handler) waitlistOfferError(c *fiber.Ctx, err error, msg string) error {
+	switch {
+	case errors.Is(err, services.ErrNotOfferRecipient):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	case errors.Is(err, services.ErrContactNotVerified):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	case errors.Is(err, services.ErrWaitlistOfferClosed):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	case errors.Is(err, services.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	h.log.Error().Err(err).Msg(msg)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"message": msg,
+	})
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/internal/services"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// getWorkOrder возвращает заказ-наряд записи с оценкой и фактом. Клиент видит
+// заказ-наряд своей записи.
+func (h *Handler) getWorkOrder(c *fiber.Ctx) error {
+	appointmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing appointment id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing appointment id",
+		})
+	}
+
+	appointment, err := h.services.AppointmentService.GetById(c.Context(), appointmentID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting appointment")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	if !h.canAccessAt(c, appointment.UserID, appointment.LocationID, entity.PermissionAppointmentsReadAll) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+
+	order, err := h.services.WorkOrderService.GetByAppointmentId(c.Context(), appointmentID)
+	if err != nil {
+		return h.workOrderError(c, err, "error getting work order")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": order,
+	})
+}
+
+// createWorkOrder открывает заказ-наряд, когда работы по записи начались.
+// Услуги записи становятся работами по ценам на момент записи.
+func (h *Handler) createWorkOrder(c *fiber.Ctx) error {
+	appointmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing appointment id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing appointment id",
+		})
+	}
+
+	appointment, err := h.services.AppointmentService.GetById(c.Context(), appointmentID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting appointment")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	if !h.canManageAt(c, appointment.LocationID, entity.PermissionWorkOrdersManage) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+
+	order, err := h.services.WorkOrderService.Create(c.Context(), appointmentID)
+	if err != nil {
+		return h.workOrderError(c, err, "error creating work order")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "ok",
+		"details": order,
+	})
+}
+
+// addWorkOrderLine добавляет работу, запчасть или расходник.
+func (h *Handler) addWorkOrderLine(c *fiber.Ctx) error {
+	appointmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing appointment id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing appointment id",
+		})
+	}
+
+	appointment, err := h.services.AppointmentService.GetById(c.Context(), appointmentID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting appointment")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	if !h.canManageAt(c, appointment.LocationID, entity.PermissionWorkOrdersManage) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+
+	var input entity.WorkOrderLineInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	order, err := h.services.WorkOrderService.AddLine(c.Context(), appointmentID, &input)
+	if err != nil {
+		return h.workOrderError(c, err, "error adding work order line")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": order,
+	})
+}
+
+// updateWorkOrderLine заменяет строку: оценку, факт, цену или скидку.
+func (h *Handler) updateWorkOrderLine(c *fiber.Ctx) error {
+	appointmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing appointment id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing appointment id",
+		})
+	}
+
+	appointment, err := h.services.AppointmentService.GetById(c.Context(), appointmentID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting appointment")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	lineID, err := uuid.Parse(c.Params("lineId"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing work order line id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing work order line id",
+		})
+	}
+
+	if !h.canManageAt(c, appointment.LocationID, entity.PermissionWorkOrdersManage) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+
+	var input entity.WorkOrderLineInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing request body",
+		})
+	}
+
+	if err := input.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	order, err := h.services.WorkOrderService.UpdateLine(c.Context(), appointmentID, lineID, &input)
+	if err != nil {
+		return h.workOrderError(c, err, "error updating work order line")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": order,
+	})
+}
+
+func (h *Handler) deleteWorkOrderLine(c *fiber.Ctx) error {
+	appointmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing appointment id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing appointment id",
+		})
+	}
+
+	appointment, err := h.services.AppointmentService.GetById(c.Context(), appointmentID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting appointment")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	lineID, err := uuid.Parse(c.Params("lineId"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing work order line id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing work order line id",
+		})
+	}
+
+	if !h.canManageAt(c, appointment.LocationID, entity.PermissionWorkOrdersManage) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+
+	order, err := h.services.WorkOrderService.DeleteLine(c.Context(), appointmentID, lineID)
+	if err != nil {
+		return h.workOrderError(c, err, "error deleting work order line")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": order,
+	})
+}
+
+// closeWorkOrder закрывает заказ-наряд. После этого он не меняется.
+func (h *Handler) closeWorkOrder(c *fiber.Ctx) error {
+	appointmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		h.log.Error().Err(err).Msg("error parsing appointment id")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "error parsing appointment id",
+		})
+	}
+
+	appointment, err := h.services.AppointmentService.GetById(c.Context(), appointmentID)
+	if err != nil {
+		h.log.Error().Err(err).Msg("error getting appointment")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	if !h.canManageAt(c, appointment.LocationID, entity.PermissionWorkOrdersManage) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "forbidden",
+		})
+	}
+
+	order, err := h.services.WorkOrderService.Close(c.Context(), appointmentID, currentActor(c))
+	if err != nil {
+		return h.workOrderError(c, err, "error closing work order")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "ok",
+		"details": order,
+	})
+}
+
+// workOrderError отвечает на ошибку изменения заказа-наряда.
+func (h *Handler) workOrderError(c *fiber.Ctx, err error, msg string) error {
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	case errors.Is(err, services.ErrWorkOrderExists), errors.Is(err, services.ErrWorkOrderClosed),
+		errors.Is(err, services.ErrWorkOrderNotDue):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	case errors.Is(err, services.ErrLaborLineLinked):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	h.log.Error().Err(err).Msg(msg)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"message": err.Error(),
+	})
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/internal/storages"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"strings"
+)
+
+const (
+	// apiKeyPrefix marks keys issued by this service so leaked keys are easy to spot
+	apiKeyPrefix = "amp_"
+	apiKeyBytes  = 32
+	// apiKeyDisplayLength is how much of the key is stored in clear to tell keys apart
+	apiKeyDisplayLength = len(apiKeyPrefix) + 8
+)
+
+type APIKeyService interface {
+	Create(ctx context.Context, createdBy uuid.UUID, input entity.APIKeyCreate) (*entity.APIKey, string, error)
+	Authenticate(ctx context.Context, rawKey string) (*entity.APIKey, error)
+	GetAll(ctx context.Context) ([]*entity.APIKey, error)
+	Revoke(ctx context.Context, id uuid.UUID) error
+}
+
+type apiKeyService struct {
+	repo storages.APIKeyRepository
+	log  zerolog.Logger
+}
+
+func NewAPIKeyService(repo storages.APIKeyRepository, log zerolog.Logger) APIKeyService {
+	return &apiKeyService{
+		repo: repo,
+		log:  log,
+	}
+}
+
+// Create issues a new key. The raw key is returned only here; at rest it is kept as a SHA-256 hash.
+func (s *apiKeyService) Create(ctx context.Context, createdBy uuid.UUID, input entity.APIKeyCreate) (*entity.APIKey, string, error) {
+	secret := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	rawKey := apiKeyPrefix + hex.EncodeToString(secret)
+
+	key := &entity.APIKey{
+		Name:        input.Name,
+		Prefix:      rawKey[:apiKeyDisplayLength],
+		Permissions: input.Permissions,
+		CreatedBy:   &createdBy,
+		ExpiresAt:   input.ExpiresAt,
+	}
+
+	if _, err := s.repo.Create(ctx, key, hashAPIKey(rawKey)); err != nil {
+		return nil, "", err
+	}
+
+	return key, rawKey, nil
+}
+
+// Authenticate resolves an active, unexpired key and records its use.
+// Last-used tracking is best effort and never rejects a valid key.
+func (s *apiKeyService) Authenticate(ctx context.Context, rawKey string) (*entity.APIKey, error) {
+	key, err := s.repo.GetActiveByHash(ctx, hashAPIKey(strings.TrimSpace(rawKey)))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.TouchLastUsed(ctx, key.ID); err != nil {
+		s.log.Error().Err(err).Str("api_key_id", key.ID.String()).Msg("error updating api key last use")
+	}
+
+	return key, nil
+}
+
+func (s *apiKeyService) GetAll(ctx context.Context) ([]*entity.APIKey, error) {
+	return s.repo.GetAll(ctx)
+}
+
+func (s *apiKeyService) Revoke(ctx context.Context, id uuid.UUID) error {
+	return s.repo.Revoke(ctx, id)
+}
+
+// hashAPIKey uses a plain SHA-256: keys are long random strings, so unlike
+// passwords they need no slow hash, and the digest can be looked up directly.
+func hashAPIKey(rawKey string) string {
+	hash := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(hash[:])
+}
@@ -1,44 +1,112 @@
 package services
 
 import (
+	"backend-service/internal/config"
 	"backend-service/internal/entity"
 	"backend-service/internal/storages"
+	"backend-service/pkg/notify"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"math"
+	"time"
+)
+
+var (
+	ErrContactNotVerified      = errors.New("verify your email or phone before booking")
+	ErrTimeSlotUnavailable     = errors.New("time slot is not available")
+	ErrOutsideBusinessHours    = errors.New("appointment must fit within business hours")
+	ErrInvalidStatusTransition = errors.New("appointment cannot move to this status")
+	ErrNotReschedulable        = errors.New("only scheduled appointments can be rescheduled")
+	ErrRescheduleCutoff        = errors.New("appointment is too close to its start to be rescheduled")
+	ErrCancellationCutoff      = errors.New("appointment is too close to its start to be cancelled, please contact the workshop")
+	ErrBookingBlocked          = errors.New("online booking is blocked after missed appointments, please contact the workshop")
+	ErrPrepaymentRequired      = errors.New("appointment has to be prepaid before check-in")
+	ErrServicesNotEditable     = errors.New("services can only be changed before work on the appointment starts")
+	ErrChangeWithinFeeWindow   = errors.New("appointment is too close to its start to be changed online, please contact the workshop")
 )
 
 type AppointmentService interface {
-	Create(ctx context.Context, userID uuid.UUID, input *entity.AppointmentCreate) (uuid.UUID, error)
+	Create(ctx context.Context, userID uuid.UUID, actor entity.Actor, input *entity.AppointmentCreate) (uuid.UUID, error)
 	GetById(ctx context.Context, id uuid.UUID) (*entity.Appointment, error)
 	GetByUserId(ctx context.Context, userId uuid.UUID) ([]*entity.Appointment, error)
-	Update(ctx context.Context, id uuid.UUID, input *entity.AppointmentUpdate) error
-	Cancel(ctx context.Context, id uuid.UUID) error
+	Update(ctx context.Context, id uuid.UUID, actor entity.Actor, input *entity.AppointmentUpdate) error
+	Cancel(ctx context.Context, id uuid.UUID, actor entity.Actor, input *entity.AppointmentCancel) (*entity.AppointmentCancellation, error)
+	GetStatusHistory(ctx context.Context, id uuid.UUID) ([]*entity.AppointmentStatusChange, error)
+	Reschedule(ctx context.Context, id uuid.UUID, actor entity.Actor, input *entity.AppointmentReschedule) (*entity.Appointment, error)
+	GetTimeHistory(ctx context.Context, id uuid.UUID) ([]*entity.AppointmentTimeChange, error)
+	AcceptWaitlistOffer(ctx context.Context, userID, offerID uuid.UUID, actor entity.Actor) (*entity.Appointment, error)
+	DeclineWaitlistOffer(ctx context.Context, userID, offerID uuid.UUID, actor entity.Actor) error
+	ExpireWaitlistOffers(ctx context.Context) (int, error)
+	MarkNoShows(ctx context.Context) (int, error)
+	MarkPrepaid(ctx context.Context, id uuid.UUID) (*entity.Appointment, error)
+	GetAvailability(ctx context.Context, query *entity.AvailabilityQuery) (*entity.AvailabilityResponse, error)
+	SetMechanics(ctx context.Context, id uuid.UUID, input *entity.AppointmentMechanicsUpdate) (*entity.Appointment, error)
 }
 
 type appointmentService struct {
 	appointmentRepo storages.AppointmentRepository
 	vehicleRepo     storages.VehicleRepository
 	serviceRepo     storages.ServiceRepository
+	resourceRepo    storages.ResourceRepository
+	staffRepo       storages.StaffRepository
+	calendarRepo    storages.CalendarRepository
+	locationRepo    storages.LocationRepository
+	waitlistRepo    storages.WaitlistRepository
+	userRepo        storages.UserRepository
+	emailSender     notify.Sender
+	smsSender       notify.Sender
+	booking         config.Booking
+	log             zerolog.Logger
 }
 
 func NewAppointmentService(
 	appointmentRepo storages.AppointmentRepository,
 	vehicleRepo storages.VehicleRepository,
 	serviceRepo storages.ServiceRepository,
+	resourceRepo storages.ResourceRepository,
+	staffRepo storages.StaffRepository,
+	calendarRepo storages.CalendarRepository,
+	locationRepo storages.LocationRepository,
+	waitlistRepo storages.WaitlistRepository,
+	userRepo storages.UserRepository,
+	emailSender notify.Sender,
+	smsSender notify.Sender,
+	booking config.Booking,
+	log zerolog.Logger,
 ) AppointmentService {
 	return &appointmentService{
 		appointmentRepo: appointmentRepo,
 		vehicleRepo:     vehicleRepo,
 		serviceRepo:     serviceRepo,
+		resourceRepo:    resourceRepo,
+		staffRepo:       staffRepo,
+		calendarRepo:    calendarRepo,
+		locationRepo:    locationRepo,
+		waitlistRepo:    waitlistRepo,
+		userRepo:        userRepo,
+		emailSender:     emailSender,
+		smsSender:       smsSender,
+		booking:         booking,
+		log:             log,
 	}
 }
 
-func (s *appointmentService) Create(ctx context.Context, userID uuid.UUID, input *entity.AppointmentCreate) (uuid.UUID, error) {
+func (s *appointmentService) Create(ctx context.Context, userID uuid.UUID, actor entity.Actor, input *entity.AppointmentCreate) (uuid.UUID, error) {
 	if err := input.Validate(); err != nil {
 		return uuid.Nil, fmt.Errorf("validation error: %w", err)
 	}
 
+	user, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if s.booking.RequireVerifiedContact && !user.HasVerifiedContact() {
+		return uuid.Nil, ErrContactNotVerified
+	}
+
 	// Check if the vehicle belongs to the user
 	vehicle, err := s.vehicleRepo.GetById(ctx, input.VehicleID)
 	if err != nil {
@@ -48,19 +116,154 @@ func (s *appointmentService) Create(ctx context.Context, userID uuid.UUID, input
 		return uuid.Nil, fmt.Errorf("vehicle does not belong to the user")
 	}
 
-	// Check if the time slot is available
-	available, err := s.appointmentRepo.CheckTimeSlotAvailable(ctx, input.AppointmentTime.Format("2006-01-02 15:04:05"))
+	// The appointment lasts as long as all of its services together
+	duration, categories, err := s.inspectServices(ctx, input.LocationID, input.ServiceIDs)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("failed to check time slot: %w", err)
-	}
-	if !available {
-		return uuid.Nil, fmt.Errorf("time slot is not available")
+		return uuid.Nil, err
 	}
 
-	// Create appointment
 	appointment := input.ToAppointment(userID)
+	appointment.EndsAt = appointment.AppointmentTime.Add(duration)
 	appointment.Attachments = input.Attachments
-	return s.appointmentRepo.Create(ctx, appointment, input.ServiceIDs)
+
+	if err := s.applyNoShowPolicy(user, actor, appointment); err != nil {
+		return uuid.Nil, err
+	}
+
+	calendar, err := loadBusinessCalendar(ctx, s.calendarRepo, s.locationRepo, appointment.LocationID, appointment.AppointmentTime, appointment.EndsAt)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to load business calendar: %w", err)
+	}
+	if !calendar.Covers(entity.TimeRange{Start: appointment.AppointmentTime, End: appointment.EndsAt}) {
+		return uuid.Nil, ErrOutsideBusinessHours
+	}
+
+	req, err := s.requirements(ctx, appointment, input.ServiceIDs, categories)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	// Create appointment and assign it a free bay of every required type
+	change := &entity.AppointmentStatusChange{ToStatus: appointment.Status, Actor: actor}
+	appointmentID, err := s.appointmentRepo.Create(ctx, appointment, input.ServiceIDs, req, change)
+	if err != nil {
+		if errors.Is(err, storages.ErrAppointmentOverlap) {
+			return uuid.Nil, ErrTimeSlotUnavailable
+		}
+		return uuid.Nil, err
+	}
+
+	return appointmentID, nil
+}
+
+// applyNoShowPolicy restricts the booking of a client who has missed
+// NoShowLimit appointments or more. Under the block policy only staff may
+// book for them; under the prepayment policy the appointment has to be paid
+// in advance.
+func (s *appointmentService) applyNoShowPolicy(user *entity.User, actor entity.Actor, appointment *entity.Appointment) error {
+	if user.NoShowCount < s.booking.NoShowLimit {
+		return nil
+	}
+
+	switch s.booking.NoShowPolicy {
+	case config.NoShowPolicyBlock:
+		if actor.UserID == nil || *actor.UserID == user.ID {
+			return ErrBookingBlocked
+		}
+	case config.NoShowPolicyPrepayment:
+		appointment.PrepaymentRequired = true
+	}
+	return nil
+}
+
+// inspectServices sums duration_min of the given services and collects their
+// distinct categories. Every service must be offered at the location.
+func (s *appointmentService) inspectServices(ctx context.Context, locationID uuid.UUID, serviceIDs []uuid.UUID) (time.Duration, []string, error) {
+	var total int
+	services := make([]*entity.Service, 0, len(serviceIDs))
+	for _, id := range serviceIDs {
+		service, err := s.serviceRepo.GetById(ctx, id)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to get service %s: %w", id, err)
+		}
+		if service.LocationID != locationID {
+			return 0, nil, fmt.Errorf("service %s is not offered at this location", id)
+		}
+		total += service.DurationMin
+		services = append(services, service)
+	}
+
+	if total <= 0 {
+		return 0, nil, fmt.Errorf("selected services have no duration")
+	}
+
+	return time.Duration(total) * time.Minute, serviceCategories(services), nil
+}
+
+func serviceCategories(services []*entity.Service) []string {
+	var categories []string
+	seen := make(map[string]bool)
+	for _, service := range services {
+		if service.Category != nil && !seen[*service.Category] {
+			seen[*service.Category] = true
+			categories = append(categories, *service.Category)
+		}
+	}
+	return categories
+}
+
+// requirements collects the resource types the services need and the
+// mechanics for the appointment. Those already assigned keep the job if they
+// still fit. When mechanics are assigned at booking, one is picked if none
+// fits; otherwise an assigned mechanic who no longer fits fails the change,
+// since nobody would replace them.
+func (s *appointmentService) requirements(
+	ctx context.Context,
+	appointment *entity.Appointment,
+	serviceIDs []uuid.UUID,
+	categories []string,
+) (*entity.AppointmentRequirements, error) {
+	resourceTypes, err := s.resourceRepo.GetTypesByServiceIds(ctx, serviceIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resource types: %w", err)
+	}
+
+	req := &entity.AppointmentRequirements{ResourceTypes: resourceTypes}
+	if !s.booking.AssignMechanics && len(appointment.Mechanics) == 0 {
+		return req, nil
+	}
+
+	loc, err := workshopLocation(ctx, s.locationRepo, appointment.LocationID)
+	if err != nil {
+		return nil, err
+	}
+
+	slot := entity.TimeRange{Start: appointment.AppointmentTime, End: appointment.EndsAt}
+	pool, err := loadMechanicPool(ctx, s.staffRepo, s.appointmentRepo, appointment.LocationID, loc, slot.Start, slot.End)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mechanic := range appointment.Mechanics {
+		profile := pool.profile(mechanic.UserID)
+		if profile != nil && pool.available(profile, categories, slot, appointment.ID) {
+			req.MechanicIDs = append(req.MechanicIDs, mechanic.UserID)
+			continue
+		}
+		if !s.booking.AssignMechanics {
+			return nil, ErrNoMechanicAvailable
+		}
+	}
+
+	if len(req.MechanicIDs) == 0 && s.booking.AssignMechanics {
+		mechanicID := pool.pick(categories, slot, appointment.ID)
+		if mechanicID == uuid.Nil {
+			return nil, ErrNoMechanicAvailable
+		}
+		req.MechanicIDs = append(req.MechanicIDs, mechanicID)
+	}
+
+	return req, nil
 }
 
 func (s *appointmentService) GetById(ctx context.Context, id uuid.UUID) (*entity.Appointment, error) {
@@ -71,7 +274,14 @@ func (s *appointmentService) GetByUserId(ctx context.Context, userId uuid.UUID)
 	return s.appointmentRepo.GetByUserId(ctx, userId)
 }
 
-func (s *appointmentService) Update(ctx context.Context, id uuid.UUID, input *entity.AppointmentUpdate) error {
+// Update changes the appointment. A new status must be reachable from the
+// current one and is recorded in the status history on behalf of actor.
+// Services can only be changed before the client arrives, while the
+// appointment is scheduled or reserved and has no work order and, for the client themselves, before
+// cancelling it would cost a fee. With them the appointment must still fit
+// within business hours. New services are saved together with the rest of the
+// changes.
+func (s *appointmentService) Update(ctx context.Context, id uuid.UUID, actor entity.Actor, input *entity.AppointmentUpdate) error {
 	if err := input.Validate(); err != nil {
 		return fmt.Errorf("validation error: %w", err)
 	}
@@ -81,19 +291,43 @@ func (s *appointmentService) Update(ctx context.Context, id uuid.UUID, input *en
 		return fmt.Errorf("failed to get appointment: %w", err)
 	}
 
-	//if input.AppointmentTime != nil {
-	//	// Check if the new time slot is available
-	//	available, err := s.appointmentRepo.CheckTimeSlotAvailable(ctx, input.AppointmentTime.Format("2006-01-02 15:04:05"))
-	//	if err != nil {
-	//		return fmt.Errorf("failed to check time slot: %w", err)
-	//	}
-	//	if !available {
-	//		return fmt.Errorf("time slot is not available")
-	//	}
-	//	appointment.AppointmentTime = *input.AppointmentTime
-	//}
+	// A different set of services changes how long the appointment lasts
+	var req *entity.AppointmentRequirements
+	if len(input.ServiceIDs) > 0 {
+		if appointment.Status != entity.AppointmentStatusScheduled && appointment.Status != entity.AppointmentStatusReserved {
+			return ErrServicesNotEditable
+		}
+		if s.inFeeWindow(appointment, actor) {
+			return ErrChangeWithinFeeWindow
+		}
+
+		duration, categories, err := s.inspectServices(ctx, appointment.LocationID, input.ServiceIDs)
+		if err != nil {
+			return err
+		}
+		appointment.EndsAt = appointment.AppointmentTime.Add(duration)
+
+		calendar, err := loadBusinessCalendar(ctx, s.calendarRepo, s.locationRepo, appointment.LocationID, appointment.AppointmentTime, appointment.EndsAt)
+		if err != nil {
+			return fmt.Errorf("failed to load business calendar: %w", err)
+		}
+		if !calendar.Covers(entity.TimeRange{Start: appointment.AppointmentTime, End: appointment.EndsAt}) {
+			return ErrOutsideBusinessHours
+		}
+
+		req, err = s.requirements(ctx, appointment, input.ServiceIDs, categories)
+		if err != nil {
+			return err
+		}
+	}
 
-	if input.Status != nil {
+	var change *entity.AppointmentStatusChange
+	previous := appointment.Status
+	if input.Status != nil && *input.Status != appointment.Status {
+		change, err = statusChange(appointment, *input.Status, actor, input.Reason)
+		if err != nil {
+			return err
+		}
 		appointment.Status = *input.Status
 	}
 
@@ -101,27 +335,516 @@ func (s *appointmentService) Update(ctx context.Context, id uuid.UUID, input *en
 		appointment.Attachments = input.Attachments
 	}
 
-	if err := s.appointmentRepo.Update(ctx, appointment); err != nil {
+	if len(input.ServiceIDs) > 0 {
+		err = s.appointmentRepo.UpdateServices(ctx, appointment, change, input.ServiceIDs, req)
+	} else {
+		err = s.appointmentRepo.Update(ctx, appointment, change)
+	}
+	if err != nil {
+		if errors.Is(err, storages.ErrAppointmentOverlap) {
+			return ErrTimeSlotUnavailable
+		}
+		if errors.Is(err, storages.ErrAppointmentStatusChanged) {
+			if change == nil {
+				return ErrServicesNotEditable
+			}
+			return ErrInvalidStatusTransition
+		}
+		if errors.Is(err, storages.ErrWorkOrderExists) {
+			return ErrServicesNotEditable
+		}
 		return fmt.Errorf("failed to update appointment: %w", err)
 	}
 
-	if len(input.ServiceIDs) > 0 {
-		if err := s.appointmentRepo.UpdateServices(ctx, id, input.ServiceIDs); err != nil {
-			return fmt.Errorf("failed to update services: %w", err)
-		}
+	if change != nil && appointment.Status.ReleasesSlot() {
+		s.slotFreed(ctx, appointment, previous)
 	}
 
 	return nil
 }
 
-func (s *appointmentService) Cancel(ctx context.Context, id uuid.UUID) error {
+func (s *appointmentService) Cancel(ctx context.Context, id uuid.UUID, actor entity.Actor, input *entity.AppointmentCancel) (*entity.AppointmentCancellation, error) {
+	if err := input.Validate(); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
 	appointment, err := s.appointmentRepo.GetById(ctx, id)
 	if err != nil {
-		return fmt.Errorf("failed to get appointment: %w", err)
+		return nil, fmt.Errorf("failed to get appointment: %w", err)
+	}
+
+	change, err := statusChange(appointment, entity.AppointmentStatusCancelled, actor, input.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	terms, fee, err := s.cancellationTerms(appointment, actor, input.Override)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := appointment.Status
+	appointment.Status = entity.AppointmentStatusCancelled
+	appointment.CancellationTerms = &terms
+	appointment.CancellationFee = &fee
+
+	if err := s.appointmentRepo.Update(ctx, appointment, change); err != nil {
+		if errors.Is(err, storages.ErrAppointmentStatusChanged) {
+			return nil, ErrInvalidStatusTransition
+		}
+		return nil, err
+	}
+
+	s.slotFreed(ctx, appointment, previous)
+	return &entity.AppointmentCancellation{
+		AppointmentID: appointment.ID,
+		Status:        appointment.Status,
+		Terms:         terms,
+		Reason:        input.Reason,
+		Fee:           fee,
+	}, nil
+}
+
+// cancellationTerms applies the cancellation policy to a client cancelling
+// their own appointment: it is cancelled for free until CancelFreeBefore its
+// start, for CancelFeePercent of the services price booked until CancelCutoff
+// and not at all after that. A reserved appointment was never confirmed by the
+// client and is always free, as is a cancellation by staff or an API key.
+// Staff may override the policy, which waives the fee.
+func (s *appointmentService) cancellationTerms(appointment *entity.Appointment, actor entity.Actor, override bool) (entity.CancellationTerms, float64, error) {
+	if override {
+		return entity.CancellationTermsOverridden, 0, nil
+	}
+
+	if appointment.Status == entity.AppointmentStatusReserved {
+		return entity.CancellationTermsFree, 0, nil
+	}
+
+	if actor.UserID == nil || *actor.UserID != appointment.UserID {
+		return entity.CancellationTermsFree, 0, nil
 	}
 
-	status := entity.AppointmentStatusCancelled
-	appointment.Status = status
+	left := time.Until(appointment.AppointmentTime)
+	if left >= s.booking.CancelFreeBefore {
+		return entity.CancellationTermsFree, 0, nil
+	}
+	if left < s.booking.CancelCutoff {
+		return "", 0, ErrCancellationCutoff
+	}
 
-	return s.appointmentRepo.Update(ctx, appointment)
+	fee := math.Round(appointment.ServicesTotal*float64(s.booking.CancelFeePercent)) / 100
+	return entity.CancellationTermsLate, fee, nil
+}
+
+// inFeeWindow reports whether the client cancelling the appointment themselves
+// would already pay a fee. Inside that window the client can no longer
+// reschedule it or change its services: moving it out of the window or
+// dropping services would let them cancel for free or for less.
+func (s *appointmentService) inFeeWindow(appointment *entity.Appointment, actor entity.Actor) bool {
+	if appointment.Status == entity.AppointmentStatusReserved || s.booking.CancelFeePercent == 0 {
+		return false
+	}
+	if actor.UserID == nil || *actor.UserID != appointment.UserID {
+		return false
+	}
+	return time.Until(appointment.AppointmentTime) < s.booking.CancelFreeBefore
+}
+
+// MarkNoShows moves scheduled appointments the client has not checked in for
+// within NoShowGrace of their start to no_show, which counts against the
+// client, and returns how many were marked. An appointment checked in or
+// cancelled meanwhile is left as is; one that fails is logged and retried on
+// the next run without holding up the rest.
+func (s *appointmentService) MarkNoShows(ctx context.Context) (int, error) {
+	appointments, err := s.appointmentRepo.GetOverdue(ctx, time.Now().Add(-s.booking.NoShowGrace))
+	if err != nil {
+		return 0, err
+	}
+
+	reason := "client did not arrive"
+	marked := 0
+	for _, appointment := range appointments {
+		change, err := statusChange(appointment, entity.AppointmentStatusNoShow, entity.Actor{}, &reason)
+		if err != nil {
+			s.log.Error().Err(err).Str("appointment_id", appointment.ID.String()).Msg("error marking no-show")
+			continue
+		}
+		appointment.Status = entity.AppointmentStatusNoShow
+
+		if err := s.appointmentRepo.Update(ctx, appointment, change); err != nil {
+			if !errors.Is(err, storages.ErrAppointmentStatusChanged) {
+				s.log.Error().Err(err).Str("appointment_id", appointment.ID.String()).Msg("error marking no-show")
+			}
+			continue
+		}
+		marked++
+	}
+
+	return marked, nil
+}
+
+// MarkPrepaid records the prepayment the no-show policy required for the appointment.
+func (s *appointmentService) MarkPrepaid(ctx context.Context, id uuid.UUID) (*entity.Appointment, error) {
+	if err := s.appointmentRepo.MarkPrepaid(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.appointmentRepo.GetById(ctx, id)
+}
+
+// Reschedule moves a scheduled appointment to another time. The new time must
+// fit into business hours and have free resources and mechanics, not counting
+// the appointment itself. Appointments starting within the reschedule cutoff
+// keep their time, and a client cannot move their own appointment once
+// cancelling it would cost a fee. The other party is notified: the client when staff moved
+// the appointment, the workshop when the client did.
+func (s *appointmentService) Reschedule(ctx context.Context, id uuid.UUID, actor entity.Actor, input *entity.AppointmentReschedule) (*entity.Appointment, error) {
+	if err := input.Validate(); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	appointment, err := s.appointmentRepo.GetById(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get appointment: %w", err)
+	}
+
+	if appointment.Status != entity.AppointmentStatusScheduled {
+		return nil, ErrNotReschedulable
+	}
+
+	if time.Until(appointment.AppointmentTime) < s.booking.RescheduleCutoff {
+		return nil, ErrRescheduleCutoff
+	}
+	if s.inFeeWindow(appointment, actor) {
+		return nil, ErrChangeWithinFeeWindow
+	}
+
+	change := &entity.AppointmentTimeChange{
+		AppointmentID: appointment.ID,
+		FromTime:      appointment.AppointmentTime,
+		ToTime:        input.AppointmentTime,
+		Actor:         actor,
+		Reason:        input.Reason,
+	}
+
+	duration := appointment.EndsAt.Sub(appointment.AppointmentTime)
+	appointment.AppointmentTime = input.AppointmentTime
+	appointment.EndsAt = input.AppointmentTime.Add(duration)
+
+	calendar, err := loadBusinessCalendar(ctx, s.calendarRepo, s.locationRepo, appointment.LocationID, appointment.AppointmentTime, appointment.EndsAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load business calendar: %w", err)
+	}
+	if !calendar.Covers(entity.TimeRange{Start: appointment.AppointmentTime, End: appointment.EndsAt}) {
+		return nil, ErrOutsideBusinessHours
+	}
+
+	serviceIDs := make([]uuid.UUID, 0, len(appointment.Services))
+	for _, service := range appointment.Services {
+		serviceIDs = append(serviceIDs, service.ID)
+	}
+
+	req, err := s.requirements(ctx, appointment, serviceIDs, serviceCategories(appointment.Services))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.appointmentRepo.Reschedule(ctx, appointment, req, change); err != nil {
+		if errors.Is(err, storages.ErrAppointmentOverlap) {
+			return nil, ErrTimeSlotUnavailable
+		}
+		return nil, fmt.Errorf("failed to reschedule appointment: %w", err)
+	}
+
+	s.notifyReschedule(ctx, appointment, change, calendar.Location)
+
+	return s.appointmentRepo.GetById(ctx, id)
+}
+
+// notifyReschedule tells the other party about the new time. Delivery
+// failures are logged, the appointment is already moved.
+func (s *appointmentService) notifyReschedule(ctx context.Context, appointment *entity.Appointment, change *entity.AppointmentTimeChange, loc *time.Location) {
+	body := fmt.Sprintf("Appointment on %s has been moved to %s.",
+		change.FromTime.In(loc).Format("02.01.2006 15:04"), change.ToTime.In(loc).Format("02.01.2006 15:04"))
+	if change.Reason != nil {
+		body += " Reason: " + *change.Reason
+	}
+
+	send := func(sender notify.Sender, msg notify.Message) {
+		if err := sender.Send(ctx, msg); err != nil {
+			s.log.Error().Err(err).Str("appointment_id", appointment.ID.String()).Msg("error sending reschedule notification")
+		}
+	}
+
+	// The client moved it: let the workshop know
+	if change.Actor.UserID != nil && *change.Actor.UserID == appointment.UserID {
+		if s.booking.StaffNotifyEmail != "" {
+			send(s.emailSender, notify.Message{To: s.booking.StaffNotifyEmail, Subject: "Appointment rescheduled", Body: body})
+		}
+		for _, mechanic := range appointment.Mechanics {
+			user, err := s.userRepo.GetById(ctx, mechanic.UserID)
+			if err != nil || user.Email == "" {
+				continue
+			}
+			send(s.emailSender, notify.Message{To: user.Email, Subject: "Appointment rescheduled", Body: body})
+		}
+		return
+	}
+
+	s.notifyClient(ctx, appointment.UserID, "Your appointment has been rescheduled", body)
+}
+
+// notifyClient sends the message by email or, if the client has none, by SMS.
+// Delivery failures are logged.
+func (s *appointmentService) notifyClient(ctx context.Context, userID uuid.UUID, subject, body string) {
+	client, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		s.log.Error().Err(err).Str("user_id", userID.String()).Msg("error getting client to notify")
+		return
+	}
+
+	switch {
+	case client.Email != "":
+		err = s.emailSender.Send(ctx, notify.Message{To: client.Email, Subject: subject, Body: body})
+	case client.Phone != "":
+		err = s.smsSender.Send(ctx, notify.Message{To: client.Phone, Body: body})
+	}
+	if err != nil {
+		s.log.Error().Err(err).Str("user_id", userID.String()).Msg("error sending notification")
+	}
+}
+
+func (s *appointmentService) GetTimeHistory(ctx context.Context, id uuid.UUID) ([]*entity.AppointmentTimeChange, error) {
+	return s.appointmentRepo.GetTimeHistory(ctx, id)
+}
+
+func (s *appointmentService) GetStatusHistory(ctx context.Context, id uuid.UUID) ([]*entity.AppointmentStatusChange, error) {
+	return s.appointmentRepo.GetStatusHistory(ctx, id)
+}
+
+// statusChange checks the transition against the status graph and describes
+// it for the history. A client who has to prepay is checked in only once the
+// prepayment is received.
+func statusChange(appointment *entity.Appointment, next entity.AppointmentStatus, actor entity.Actor, reason *string) (*entity.AppointmentStatusChange, error) {
+	if !appointment.Status.CanTransitionTo(next) {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidStatusTransition, appointment.Status, next)
+	}
+	if next == entity.AppointmentStatusCheckedIn && appointment.PrepaymentRequired && appointment.PrepaidAt == nil {
+		return nil, ErrPrepaymentRequired
+	}
+
+	from := appointment.Status
+	return &entity.AppointmentStatusChange{
+		AppointmentID: appointment.ID,
+		FromStatus:    &from,
+		ToStatus:      next,
+		Actor:         actor,
+		Reason:        reason,
+	}, nil
+}
+
+// SetMechanics replaces the mechanics of the appointment. Each of them must
+// have the skills for its services, be on shift and free for its whole time.
+func (s *appointmentService) SetMechanics(ctx context.Context, id uuid.UUID, input *entity.AppointmentMechanicsUpdate) (*entity.Appointment, error) {
+	if err := input.Validate(); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	appointment, err := s.appointmentRepo.GetById(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get appointment: %w", err)
+	}
+
+	if appointment.Status.ReleasesSlot() {
+		return nil, fmt.Errorf("appointment is %s", appointment.Status)
+	}
+
+	loc, err := workshopLocation(ctx, s.locationRepo, appointment.LocationID)
+	if err != nil {
+		return nil, err
+	}
+
+	slot := entity.TimeRange{Start: appointment.AppointmentTime, End: appointment.EndsAt}
+	pool, err := loadMechanicPool(ctx, s.staffRepo, s.appointmentRepo, appointment.LocationID, loc, slot.Start, slot.End)
+	if err != nil {
+		return nil, err
+	}
+
+	categories := serviceCategories(appointment.Services)
+	for _, mechanicID := range input.MechanicIDs {
+		profile := pool.profile(mechanicID)
+		if profile == nil {
+			return nil, fmt.Errorf("%w: %s has no active staff profile", ErrMechanicUnavailable, mechanicID)
+		}
+		if !pool.available(profile, categories, slot, appointment.ID) {
+			return nil, fmt.Errorf("%w: %s", ErrMechanicUnavailable, profile.FullName)
+		}
+	}
+
+	if err := s.appointmentRepo.SetMechanics(ctx, appointment, input.MechanicIDs); err != nil {
+		if errors.Is(err, storages.ErrAppointmentOverlap) {
+			return nil, ErrMechanicUnavailable
+		}
+		return nil, fmt.Errorf("failed to assign mechanics: %w", err)
+	}
+
+	return appointment, nil
+}
+
+// GetAvailability lists start times, grouped by workshop day, at which the
+// selected services fit into the working hours of the business calendar,
+// every resource type they need has a free unit for the whole appointment and, if mechanics are assigned at booking,
+// a mechanic with the right skills is on shift and free.
+func (s *appointmentService) GetAvailability(ctx context.Context, query *entity.AvailabilityQuery) (*entity.AvailabilityResponse, error) {
+	if err := query.Validate(); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	duration, categories, err := s.inspectServices(ctx, query.LocationID, query.ServiceIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := workshopLocation(ctx, s.locationRepo, query.LocationID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The query holds calendar dates, the day boundaries are the workshop's
+	from := time.Date(query.From.Year(), query.From.Month(), query.From.Day(), 0, 0, 0, 0, loc)
+	to := time.Date(query.To.Year(), query.To.Month(), query.To.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+
+	calendar, err := loadBusinessCalendar(ctx, s.calendarRepo, s.locationRepo, query.LocationID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load business calendar: %w", err)
+	}
+
+	pools, err := s.resourcePools(ctx, query.LocationID, query.ServiceIDs, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var mechanics *mechanicPool
+	if s.booking.AssignMechanics {
+		mechanics, err = loadMechanicPool(ctx, s.staffRepo, s.appointmentRepo, query.LocationID, loc, from, to)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	step := s.booking.SlotStep
+	if step <= 0 {
+		step = 30 * time.Minute
+	}
+
+	now := time.Now()
+	response := &entity.AvailabilityResponse{DurationMin: int(duration.Minutes())}
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		availability := &entity.DayAvailability{Date: day.Format("2006-01-02"), Slots: []time.Time{}}
+		response.Days = append(response.Days, availability)
+
+		for _, interval := range calendar.WorkingIntervals(day) {
+			for start := interval.Start; !start.Add(duration).After(interval.End); start = start.Add(step) {
+				if start.Before(now) {
+					continue
+				}
+				slot := entity.TimeRange{Start: start, End: start.Add(duration)}
+				if !pools.fit(slot) {
+					continue
+				}
+				if mechanics != nil && mechanics.pick(categories, slot, uuid.Nil) == uuid.Nil {
+					continue
+				}
+				availability.Slots = append(availability.Slots, start)
+			}
+		}
+	}
+
+	return response, nil
+}
+
+// resourceUnit is one place of a resource, e.g. the second car on a double bay.
+type resourceUnit struct {
+	resourceID uuid.UUID
+	unit       int
+}
+
+// resourcePools holds, for every required resource type, the intervals during
+// which each of its units is taken.
+type resourcePools map[string]map[resourceUnit][]entity.TimeRange
+
+// fit reports whether every type has at least one unit free during the slot.
+// Services get a resource type on creation, so an empty pool only comes from
+// services explicitly configured to take no resources, and those always fit.
+func (p resourcePools) fit(slot entity.TimeRange) bool {
+	for _, units := range p {
+		free := false
+		for _, busy := range units {
+			if !overlapsAny(busy, slot) {
+				free = true
+				break
+			}
+		}
+		if !free {
+			return false
+		}
+	}
+	return true
+}
+
+// resourcePools loads the units of the location's resources of the types
+// needed by the services together with their allocations in [from, to).
+func (s *appointmentService) resourcePools(ctx context.Context, locationID uuid.UUID, serviceIDs []uuid.UUID, from, to time.Time) (resourcePools, error) {
+	resourceTypes, err := s.resourceRepo.GetTypesByServiceIds(ctx, serviceIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resource types: %w", err)
+	}
+
+	pools := make(resourcePools, len(resourceTypes))
+	for _, resourceType := range resourceTypes {
+		// A type without resources leaves the pool empty, so nothing fits
+		pools[resourceType] = map[resourceUnit][]entity.TimeRange{}
+	}
+	if len(resourceTypes) == 0 {
+		return pools, nil
+	}
+
+	resources, err := s.resourceRepo.GetByTypes(ctx, locationID, resourceTypes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resources: %w", err)
+	}
+
+	resourceIDs := make([]uuid.UUID, 0, len(resources))
+	resourceTypeByID := make(map[uuid.UUID]string, len(resources))
+	for _, resource := range resources {
+		resourceIDs = append(resourceIDs, resource.ID)
+		resourceTypeByID[resource.ID] = resource.Type
+		for unit := 1; unit <= resource.Capacity; unit++ {
+			pools[resource.Type][resourceUnit{resourceID: resource.ID, unit: unit}] = nil
+		}
+	}
+
+	allocations, err := s.appointmentRepo.GetAllocations(ctx, resourceIDs, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resource allocations: %w", err)
+	}
+
+	for _, allocation := range allocations {
+		key := resourceUnit{resourceID: allocation.ResourceID, unit: allocation.Unit}
+		units := pools[resourceTypeByID[allocation.ResourceID]]
+		// Units above a reduced capacity are not offered any more
+		if _, ok := units[key]; ok {
+			units[key] = append(units[key], allocation.TimeRange)
+		}
+	}
+
+	return pools, nil
+}
+
+func overlapsAny(intervals []entity.TimeRange, slot entity.TimeRange) bool {
+	for _, interval := range intervals {
+		if interval.Overlaps(slot) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"backend-service/internal/config"
+	"backend-service/internal/entity"
+	"errors"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestStatusChange(t *testing.T) {
+	userID := uuid.New()
+	actor := entity.Actor{UserID: &userID}
+	reason := "client asked"
+	appointment := &entity.Appointment{ID: uuid.New(), Status: entity.AppointmentStatusScheduled}
+
+	change, err := statusChange(appointment, entity.AppointmentStatusCancelled, actor, &reason)
+	if err != nil {
+		t.Fatalf("statusChange() error = %v", err)
+	}
+	if change.AppointmentID != appointment.ID {
+		t.Errorf("AppointmentID = %v, want %v", change.AppointmentID, appointment.ID)
+	}
+	if change.FromStatus == nil || *change.FromStatus != entity.AppointmentStatusScheduled {
+		t.Errorf("FromStatus = %v, want %s", change.FromStatus, entity.AppointmentStatusScheduled)
+	}
+	if change.ToStatus != entity.AppointmentStatusCancelled {
+		t.Errorf("ToStatus = %s, want %s", change.ToStatus, entity.AppointmentStatusCancelled)
+	}
+	if change.Actor.UserID != &userID || change.Reason != &reason {
+		t.Error("statusChange() did not keep the actor and reason")
+	}
+
+	// The history keeps the status before the change even after the appointment moves on
+	appointment.Status = entity.AppointmentStatusCancelled
+	if *change.FromStatus != entity.AppointmentStatusScheduled {
+		t.Errorf("FromStatus follows the appointment: %s", *change.FromStatus)
+	}
+
+	if _, err := statusChange(appointment, entity.AppointmentStatusScheduled, actor, nil); !errors.Is(err, ErrInvalidStatusTransition) {
+		t.Errorf("statusChange() from a final status error = %v, want %v", err, ErrInvalidStatusTransition)
+	}
+}
+
+func TestStatusChangeRequiresPrepayment(t *testing.T) {
+	paid := time.Now()
+	tests := []struct {
+		name        string
+		appointment entity.Appointment
+		next        entity.AppointmentStatus
+		wantErr     error
+	}{
+		{
+			name:        "check-in without prepayment",
+			appointment: entity.Appointment{Status: entity.AppointmentStatusScheduled, PrepaymentRequired: true},
+			next:        entity.AppointmentStatusCheckedIn,
+			wantErr:     ErrPrepaymentRequired,
+		},
+		{
+			name:        "check-in after prepayment",
+			appointment: entity.Appointment{Status: entity.AppointmentStatusScheduled, PrepaymentRequired: true, PrepaidAt: &paid},
+			next:        entity.AppointmentStatusCheckedIn,
+		},
+		{
+			name:        "check-in without required prepayment",
+			appointment: entity.Appointment{Status: entity.AppointmentStatusScheduled},
+			next:        entity.AppointmentStatusCheckedIn,
+		},
+		{
+			name:        "unpaid appointment can still be cancelled",
+			appointment: entity.Appointment{Status: entity.AppointmentStatusScheduled, PrepaymentRequired: true},
+			next:        entity.AppointmentStatusCancelled,
+		},
+		{
+			name:        "unpaid appointment can be a no-show",
+			appointment: entity.Appointment{Status: entity.AppointmentStatusScheduled, PrepaymentRequired: true},
+			next:        entity.AppointmentStatusNoShow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := statusChange(&tt.appointment, tt.next, entity.Actor{}, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("statusChange() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCancellationTerms(t *testing.T) {
+	s := &appointmentService{booking: config.Booking{
+		CancelFreeBefore: 48 * time.Hour,
+		CancelCutoff:     2 * time.Hour,
+		CancelFeePercent: 15,
+	}}
+
+	clientID := uuid.New()
+	staffID := uuid.New()
+	apiKeyID := uuid.New()
+	client := entity.Actor{UserID: &clientID}
+
+	appointmentIn := func(status entity.AppointmentStatus, left time.Duration) *entity.Appointment {
+		return &entity.Appointment{
+			UserID:          clientID,
+			Status:          status,
+			AppointmentTime: time.Now().Add(left),
+			ServicesTotal:   1234.5,
+		}
+	}
+
+	tests := []struct {
+		name        string
+		appointment *entity.Appointment
+		actor       entity.Actor
+		override    bool
+		wantTerms   entity.CancellationTerms
+		wantFee     float64
+		wantErr     error
+	}{
+		{
+			name:        "client before the free window ends",
+			appointment: appointmentIn(entity.AppointmentStatusScheduled, 72*time.Hour),
+			actor:       client,
+			wantTerms:   entity.CancellationTermsFree,
+		},
+		{
+			name:        "client late",
+			appointment: appointmentIn(entity.AppointmentStatusScheduled, 24*time.Hour),
+			actor:       client,
+			wantTerms:   entity.CancellationTermsLate,
+			wantFee:     185.18,
+		},
+		{
+			name:        "client after the cutoff",
+			appointment: appointmentIn(entity.AppointmentStatusScheduled, time.Hour),
+			actor:       client,
+			wantErr:     ErrCancellationCutoff,
+		},
+		{
+			name:        "client releasing a waitlist reservation",
+			appointment: appointmentIn(entity.AppointmentStatusReserved, time.Hour),
+			actor:       client,
+			wantTerms:   entity.CancellationTermsFree,
+		},
+		{
+			name:        "staff cancelling a client's appointment",
+			appointment: appointmentIn(entity.AppointmentStatusScheduled, time.Hour),
+			actor:       entity.Actor{UserID: &staffID},
+			wantTerms:   entity.CancellationTermsFree,
+		},
+		{
+			name:        "api key",
+			appointment: appointmentIn(entity.AppointmentStatusScheduled, time.Hour),
+			actor:       entity.Actor{APIKeyID: &apiKeyID},
+			wantTerms:   entity.CancellationTermsFree,
+		},
+		{
+			name:        "override",
+			appointment: appointmentIn(entity.AppointmentStatusScheduled, time.Hour),
+			actor:       entity.Actor{UserID: &staffID},
+			override:    true,
+			wantTerms:   entity.CancellationTermsOverridden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			terms, fee, err := s.cancellationTerms(tt.appointment, tt.actor, tt.override)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("cancellationTerms() error = %v, want %v", err, tt.wantErr)
+			}
+			if terms != tt.wantTerms || fee != tt.wantFee {
+				t.Errorf("cancellationTerms() = %s, %v, want %s, %v", terms, fee, tt.wantTerms, tt.wantFee)
+			}
+		})
+	}
+}
+
+func TestInFeeWindow(t *testing.T) {
+	s := &appointmentService{booking: config.Booking{
+		CancelFreeBefore: 48 * time.Hour,
+		CancelCutoff:     2 * time.Hour,
+		CancelFeePercent: 15,
+	}}
+
+	clientID := uuid.New()
+	staffID := uuid.New()
+	client := entity.Actor{UserID: &clientID}
+
+	tests := []struct {
+		name   string
+		status entity.AppointmentStatus
+		left   time.Duration
+		actor  entity.Actor
+		want   bool
+	}{
+		{"client before the fee window", entity.AppointmentStatusScheduled, 72 * time.Hour, client, false},
+		{"client inside the fee window", entity.AppointmentStatusScheduled, 24 * time.Hour, client, true},
+		{"staff inside the fee window", entity.AppointmentStatusScheduled, 24 * time.Hour, entity.Actor{UserID: &staffID}, false},
+		{"api key inside the fee window", entity.AppointmentStatusScheduled, 24 * time.Hour, entity.Actor{}, false},
+		{"waitlist reservation", entity.AppointmentStatusReserved, 24 * time.Hour, client, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appointment := &entity.Appointment{UserID: clientID, Status: tt.status, AppointmentTime: time.Now().Add(tt.left)}
+			if got := s.inFeeWindow(appointment, tt.actor); got != tt.want {
+				t.Errorf("inFeeWindow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	free := &appointmentService{booking: config.Booking{CancelFreeBefore: 48 * time.Hour}}
+	appointment := &entity.Appointment{UserID: clientID, Status: entity.AppointmentStatusScheduled, AppointmentTime: time.Now().Add(time.Hour)}
+	if free.inFeeWindow(appointment, client) {
+		t.Error("inFeeWindow() = true without a cancellation fee")
+	}
+}
+
+func TestResourcePoolsFit(t *testing.T) {
+	start := time.Date(2026, time.October, 19, 10, 0, 0, 0, time.UTC)
+	slot := entity.TimeRange{Start: start, End: start.Add(time.Hour)}
+	before := entity.TimeRange{Start: start.Add(-time.Hour), End: start}
+	during := entity.TimeRange{Start: start.Add(30 * time.Minute), End: start.Add(90 * time.Minute)}
+
+	lift := uuid.New()
+	bay := uuid.New()
+
+	tests := []struct {
+		name  string
+		pools resourcePools
+		want  bool
+	}{
+		{
+			name:  "no resource types",
+			pools: resourcePools{},
+			want:  true,
+		},
+		{
+			name: "type without resources",
+			pools: resourcePools{
+				"lift": {},
+			},
+			want: false,
+		},
+		{
+			name: "free unit",
+			pools: resourcePools{
+				"lift": {{resourceID: lift, unit: 1}: nil},
+			},
+			want: true,
+		},
+		{
+			name: "unit taken right before the slot",
+			pools: resourcePools{
+				"lift": {{resourceID: lift, unit: 1}: {before}},
+			},
+			want: true,
+		},
+		{
+			name: "only unit taken",
+			pools: resourcePools{
+				"lift": {{resourceID: lift, unit: 1}: {during}},
+			},
+			want: false,
+		},
+		{
+			name: "second unit of a double bay free",
+			pools: resourcePools{
+				"bay": {
+					{resourceID: bay, unit: 1}: {during},
+					{resourceID: bay, unit: 2}: {before},
+				},
+			},
+			want: true,
+		},
+		{
+			name: "one of two types taken",
+			pools: resourcePools{
+				"lift": {{resourceID: lift, unit: 1}: nil},
+				"bay":  {{resourceID: bay, unit: 1}: {during}},
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pools.fit(slot); got != tt.want {
+				t.Errorf("fit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
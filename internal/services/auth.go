@@ -3,23 +3,88 @@ package services
 import (
 	"backend-service/internal/entity"
 	"backend-service/internal/storages"
+	"backend-service/pkg/notify"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"strings"
+	"time"
 )
 
+const (
+	passwordResetPurpose     = "password_reset"
+	passwordResetCodeLength  = 6
+	passwordResetCodeTTL     = 15 * time.Minute
+	passwordResetMaxAttempts = 5
+	passwordResetLimit       = 3
+	passwordResetWindow      = time.Hour
+
+	phoneLoginPurpose     = "phone_login"
+	phoneLoginCodeLength  = 6
+	phoneLoginCodeTTL     = 5 * time.Minute
+	phoneLoginMaxAttempts = 5
+	phoneLoginLimit       = 5
+	phoneLoginWindow      = time.Hour
+
+	// Brute-force protection: after loginDelayAfter failures every next attempt
+	// is delayed exponentially, after the max number of failures the subject is locked
+	loginFailureWindow      = 15 * time.Minute
+	loginDelayAfter         = 3
+	loginMaxDelay           = 30 * time.Second
+	loginAccountMaxFailures = 5
+	loginIPMaxFailures      = 20
+	loginLockoutDuration    = 15 * time.Minute
+)
+
+var ErrTooManyRequests = errors.New("too many requests, try again later")
+
+// dummyUser is checked against the password of an unknown email, so a login
+// takes as long as for an existing account and does not reveal which emails are registered.
+var dummyUser = func() *entity.User {
+	hash, _ := entity.HashPassword("dummy-password")
+	return &entity.User{PasswordHash: hash}
+}()
+
 type AuthService interface {
 	Register(ctx context.Context, input entity.UserRegister) (uuid.UUID, error)
-	Login(ctx context.Context, input entity.UserLogin) (*entity.User, error)
+	Login(ctx context.Context, input entity.UserLogin) (*entity.LoginResult, error)
+	RequestPasswordReset(ctx context.Context, input entity.PasswordResetRequest) error
+	ConfirmPasswordReset(ctx context.Context, input entity.PasswordResetConfirm) (uuid.UUID, error)
+	RequestLoginCode(ctx context.Context, input entity.PhoneLoginRequest) error
+	LoginWithCode(ctx context.Context, input entity.PhoneLogin) (*entity.LoginResult, error)
+	UnlockAccount(ctx context.Context, userID uuid.UUID) error
 }
 
 type authService struct {
-	userRepo storages.UserRepository
+	userRepo         storages.UserRepository
+	codeRepo         storages.OneTimeCodeRepository
+	loginAttemptRepo storages.LoginAttemptRepository
+	challengeRepo    storages.TwoFactorChallengeRepository
+	emailSender      notify.Sender
+	smsSender        notify.Sender
+	log              zerolog.Logger
 }
 
-func NewAuthService(userRepo storages.UserRepository) AuthService {
+func NewAuthService(
+	userRepo storages.UserRepository,
+	codeRepo storages.OneTimeCodeRepository,
+	loginAttemptRepo storages.LoginAttemptRepository,
+	challengeRepo storages.TwoFactorChallengeRepository,
+	emailSender notify.Sender,
+	smsSender notify.Sender,
+	log zerolog.Logger,
+) AuthService {
 	return &authService{
-		userRepo: userRepo,
+		userRepo:         userRepo,
+		codeRepo:         codeRepo,
+		loginAttemptRepo: loginAttemptRepo,
+		challengeRepo:    challengeRepo,
+		emailSender:      emailSender,
+		smsSender:        smsSender,
+		log:              log,
 	}
 }
 
@@ -35,15 +100,244 @@ func (s *authService) Register(ctx context.Context, input entity.UserRegister) (
 	return userID, nil
 }
 
-func (s *authService) Login(ctx context.Context, input entity.UserLogin) (*entity.User, error) {
+// Login checks the password. Users with TOTP enabled get a two-factor token
+// instead of the user, to be exchanged via TwoFactorService.CompleteLogin.
+func (s *authService) Login(ctx context.Context, input entity.UserLogin) (*entity.LoginResult, error) {
+	accountKey, ipKey := loginAccountKey(input.Email), loginIPKey(input.IP)
+
+	for _, key := range []string{accountKey, ipKey} {
+		blockedFor, err := s.loginAttemptRepo.BlockedFor(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if blockedFor > 0 {
+			return nil, fmt.Errorf("%w: retry in %d seconds", ErrTooManyRequests, int(blockedFor.Seconds())+1)
+		}
+	}
+
 	user, err := s.userRepo.GetByEmail(ctx, input.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	if user == nil {
+		dummyUser.CheckPasswordHash(input.Password)
+	}
+
+	if user == nil || !user.CheckPasswordHash(input.Password) {
+		if err := s.registerLoginFailure(ctx, user, accountKey, ipKey); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("invalid email or password")
+	}
+
+	if err := s.loginAttemptRepo.Reset(ctx, accountKey); err != nil {
+		return nil, err
+	}
+
+	return s.loginResult(ctx, user)
+}
+
+// loginResult finishes the first login step, starting a two-factor
+// challenge when the user has TOTP enabled.
+func (s *authService) loginResult(ctx context.Context, user *entity.User) (*entity.LoginResult, error) {
+	if !user.TwoFactorEnabled() {
+		return &entity.LoginResult{User: user}, nil
+	}
+
+	token, err := startTwoFactorChallenge(ctx, s.challengeRepo, user.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	if !user.CheckPasswordHash(input.Password) {
-		return nil, fmt.Errorf("invalid password")
+	return &entity.LoginResult{TwoFactorToken: token}, nil
+}
+
+// registerLoginFailure counts a failed login for the account and the IP and
+// applies a progressive delay or a lockout once the thresholds are reached.
+func (s *authService) registerLoginFailure(ctx context.Context, user *entity.User, accountKey, ipKey string) error {
+	accountFailures, err := s.loginAttemptRepo.AddFailure(ctx, accountKey, loginFailureWindow)
+	if err != nil {
+		return err
+	}
+
+	ipFailures, err := s.loginAttemptRepo.AddFailure(ctx, ipKey, loginFailureWindow)
+	if err != nil {
+		return err
+	}
+
+	if ipFailures >= loginIPMaxFailures {
+		if err := s.loginAttemptRepo.Block(ctx, ipKey, loginLockoutDuration); err != nil {
+			return err
+		}
+	}
+
+	if accountFailures >= loginAccountMaxFailures {
+		if err := s.loginAttemptRepo.Block(ctx, accountKey, loginLockoutDuration); err != nil {
+			return err
+		}
+		// Notify only once, when the lockout starts. A failed notification
+		// must not replace the login error
+		if accountFailures == loginAccountMaxFailures && user != nil {
+			if err := s.notifyLockout(ctx, user); err != nil {
+				s.log.Error().Err(err).Str("user_id", user.ID.String()).Msg("error sending lockout notification")
+			}
+		}
+		return nil
+	}
+
+	if accountFailures >= loginDelayAfter {
+		if err := s.loginAttemptRepo.Block(ctx, accountKey, loginDelay(accountFailures)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *authService) notifyLockout(ctx context.Context, user *entity.User) error {
+	return s.emailSender.Send(ctx, notify.Message{
+		To:      user.Email,
+		Subject: "Account temporarily locked",
+		Body: fmt.Sprintf(
+			"We detected %d failed login attempts to your account. Login is locked for %d minutes. "+
+				"If it wasn't you, reset your password.",
+			loginAccountMaxFailures, int(loginLockoutDuration.Minutes()),
+		),
+	})
+}
+
+func (s *authService) UnlockAccount(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.loginAttemptRepo.Reset(ctx, loginAccountKey(user.Email))
+}
+
+// loginDelay doubles the delay with every failure after loginDelayAfter.
+func loginDelay(failures int64) time.Duration {
+	delay := time.Second << (failures - loginDelayAfter)
+	if delay > loginMaxDelay {
+		return loginMaxDelay
+	}
+	return delay
+}
+
+func loginAccountKey(email string) string {
+	return "account:" + strings.ToLower(email)
+}
+
+func loginIPKey(ip string) string {
+	return "ip:" + ip
+}
+
+func (s *authService) RequestPasswordReset(ctx context.Context, input entity.PasswordResetRequest) error {
+	allowed, err := s.codeRepo.Throttle(ctx, passwordResetPurpose, input.Email, passwordResetLimit, passwordResetWindow)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrTooManyRequests
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, input.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Do not reveal whether the email is registered
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	code, err := generateCode(passwordResetCodeLength)
+	if err != nil {
+		return fmt.Errorf("failed to generate code: %w", err)
+	}
+
+	if err := s.codeRepo.Save(ctx, passwordResetPurpose, user.Email, code, passwordResetCodeTTL); err != nil {
+		return err
+	}
+
+	return s.emailSender.Send(ctx, notify.Message{
+		To:      user.Email,
+		Subject: "Password reset",
+		Body:    fmt.Sprintf("Your password reset code: %s. It expires in %d minutes.", code, int(passwordResetCodeTTL.Minutes())),
+	})
+}
+
+func (s *authService) ConfirmPasswordReset(ctx context.Context, input entity.PasswordResetConfirm) (uuid.UUID, error) {
+	valid, err := s.codeRepo.Verify(ctx, passwordResetPurpose, input.Email, input.Code, passwordResetMaxAttempts)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !valid {
+		return uuid.Nil, fmt.Errorf("invalid or expired code")
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, input.Email)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	passwordHash, err := entity.HashPassword(input.NewPassword)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, user.ID, passwordHash); err != nil {
+		return uuid.Nil, err
+	}
+
+	return user.ID, nil
+}
+
+func (s *authService) RequestLoginCode(ctx context.Context, input entity.PhoneLoginRequest) error {
+	allowed, err := s.codeRepo.Throttle(ctx, phoneLoginPurpose, input.Phone, phoneLoginLimit, phoneLoginWindow)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrTooManyRequests
+	}
+
+	user, err := s.userRepo.GetByPhone(ctx, input.Phone)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Do not reveal whether the phone is registered
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	code, err := generateCode(phoneLoginCodeLength)
+	if err != nil {
+		return fmt.Errorf("failed to generate code: %w", err)
+	}
+
+	if err := s.codeRepo.Save(ctx, phoneLoginPurpose, user.Phone, code, phoneLoginCodeTTL); err != nil {
+		return err
+	}
+
+	return s.smsSender.Send(ctx, notify.Message{
+		To:   user.Phone,
+		Body: fmt.Sprintf("Your login code: %s", code),
+	})
+}
+
+func (s *authService) LoginWithCode(ctx context.Context, input entity.PhoneLogin) (*entity.LoginResult, error) {
+	valid, err := s.codeRepo.Verify(ctx, phoneLoginPurpose, input.Phone, input.Code, phoneLoginMaxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, fmt.Errorf("invalid or expired code")
+	}
+
+	user, err := s.userRepo.GetByPhone(ctx, input.Phone)
+	if err != nil {
+		return nil, err
 	}
 
-	return user, nil
+	return s.loginResult(ctx, user)
 }
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/internal/storages"
+	"backend-service/pkg/notify"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"testing"
+	"time"
+)
+
+type fakeLoginAttemptRepo struct {
+	failures map[string]int64
+	blocks   map[string]time.Duration
+}
+
+func newFakeLoginAttemptRepo() *fakeLoginAttemptRepo {
+	return &fakeLoginAttemptRepo{failures: map[string]int64{}, blocks: map[string]time.Duration{}}
+}
+
+func (r *fakeLoginAttemptRepo) AddFailure(_ context.Context, subject string, _ time.Duration) (int64, error) {
+	r.failures[subject]++
+	return r.failures[subject], nil
+}
+
+func (r *fakeLoginAttemptRepo) Block(_ context.Context, subject string, ttl time.Duration) error {
+	r.blocks[subject] = ttl
+	return nil
+}
+
+func (r *fakeLoginAttemptRepo) BlockedFor(_ context.Context, subject string) (time.Duration, error) {
+	return r.blocks[subject], nil
+}
+
+func (r *fakeLoginAttemptRepo) Reset(_ context.Context, subject string) error {
+	delete(r.failures, subject)
+	delete(r.blocks, subject)
+	return nil
+}
+
+type fakeUserRepo struct {
+	storages.UserRepository
+	users map[string]*entity.User
+}
+
+func (r *fakeUserRepo) GetByEmail(_ context.Context, email string) (*entity.User, error) {
+	user, ok := r.users[email]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+type fakeSender struct {
+	sent []notify.Message
+}
+
+func (s *fakeSender) Send(_ context.Context, msg notify.Message) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestLoginDelay(t *testing.T) {
+	tests := []struct {
+		failures int64
+		want     time.Duration
+	}{
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{7, 16 * time.Second},
+		{8, loginMaxDelay},
+		{20, loginMaxDelay},
+	}
+
+	for _, tt := range tests {
+		if got := loginDelay(tt.failures); got != tt.want {
+			t.Errorf("loginDelay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterLoginFailure(t *testing.T) {
+	const accountKey, ipKey = "account:client@example.com", "ip:10.0.0.1"
+	user := &entity.User{ID: uuid.New(), Email: "client@example.com"}
+
+	tests := []struct {
+		name            string
+		user            *entity.User
+		accountFailures int64
+		ipFailures      int64
+		wantAccount     time.Duration
+		wantIP          time.Duration
+		wantNotified    int
+	}{
+		{
+			name:            "first failure",
+			user:            user,
+			accountFailures: 1,
+			ipFailures:      1,
+		},
+		{
+			name:            "below the delay threshold",
+			user:            user,
+			accountFailures: 2,
+			ipFailures:      2,
+		},
+		{
+			name:            "delay starts",
+			user:            user,
+			accountFailures: 3,
+			ipFailures:      3,
+			wantAccount:     time.Second,
+		},
+		{
+			name:            "delay doubles",
+			user:            user,
+			accountFailures: 4,
+			ipFailures:      4,
+			wantAccount:     2 * time.Second,
+		},
+		{
+			name:            "lockout starts",
+			user:            user,
+			accountFailures: 5,
+			ipFailures:      5,
+			wantAccount:     loginLockoutDuration,
+			wantNotified:    1,
+		},
+		{
+			name:            "lockout is notified once",
+			user:            user,
+			accountFailures: 6,
+			ipFailures:      6,
+			wantAccount:     loginLockoutDuration,
+		},
+		{
+			name:            "unknown email",
+			accountFailures: 5,
+			ipFailures:      5,
+			wantAccount:     loginLockoutDuration,
+		},
+		{
+			name:            "ip lockout across accounts",
+			user:            user,
+			accountFailures: 1,
+			ipFailures:      loginIPMaxFailures,
+			wantIP:          loginLockoutDuration,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := newFakeLoginAttemptRepo()
+			attempts.failures[accountKey] = tt.accountFailures - 1
+			attempts.failures[ipKey] = tt.ipFailures - 1
+			sender := &fakeSender{}
+			s := &authService{loginAttemptRepo: attempts, emailSender: sender, log: zerolog.Nop()}
+
+			if err := s.registerLoginFailure(context.Background(), tt.user, accountKey, ipKey); err != nil {
+				t.Fatalf("registerLoginFailure() error = %v", err)
+			}
+
+			if attempts.failures[accountKey] != tt.accountFailures || attempts.failures[ipKey] != tt.ipFailures {
+				t.Errorf("failures = %d, %d, want %d, %d",
+					attempts.failures[accountKey], attempts.failures[ipKey], tt.accountFailures, tt.ipFailures)
+			}
+			if got := attempts.blocks[accountKey]; got != tt.wantAccount {
+				t.Errorf("account blocked for %v, want %v", got, tt.wantAccount)
+			}
+			if got := attempts.blocks[ipKey]; got != tt.wantIP {
+				t.Errorf("ip blocked for %v, want %v", got, tt.wantIP)
+			}
+			if len(sender.sent) != tt.wantNotified {
+				t.Errorf("notifications = %d, want %d", len(sender.sent), tt.wantNotified)
+			}
+		})
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	hash, err := entity.HashPassword("correct-password")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+	user := &entity.User{ID: uuid.New(), Email: "client@example.com", PasswordHash: hash}
+
+	attempts := newFakeLoginAttemptRepo()
+	s := &authService{
+		userRepo:         &fakeUserRepo{users: map[string]*entity.User{user.Email: user}},
+		loginAttemptRepo: attempts,
+		emailSender:      &fakeSender{},
+		log:              zerolog.Nop(),
+	}
+	ctx := context.Background()
+	accountKey := loginAccountKey(user.Email)
+	login := func(password string) error {
+		_, err := s.Login(ctx, entity.UserLogin{Email: user.Email, Password: password, IP: "10.0.0.1"})
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := login("wrong-password"); err == nil || errors.Is(err, ErrTooManyRequests) {
+			t.Fatalf("Login() with a wrong password error = %v", err)
+		}
+	}
+	if attempts.failures[accountKey] != 2 {
+		t.Fatalf("account failures = %d, want 2", attempts.failures[accountKey])
+	}
+
+	if err := login("correct-password"); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if _, ok := attempts.failures[accountKey]; ok {
+		t.Error("a successful login did not reset the account failures")
+	}
+
+	// A blocked account is refused even with the right password
+	attempts.blocks[accountKey] = loginLockoutDuration
+	if err := login("correct-password"); !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("Login() of a locked account error = %v, want %v", err, ErrTooManyRequests)
+	}
+	delete(attempts.blocks, accountKey)
+
+	attempts.blocks[loginIPKey("10.0.0.1")] = loginLockoutDuration
+	if err := login("correct-password"); !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("Login() from a locked IP error = %v, want %v", err, ErrTooManyRequests)
+	}
+}
+
+func TestLoginUnknownEmail(t *testing.T) {
+	attempts := newFakeLoginAttemptRepo()
+	sender := &fakeSender{}
+	s := &authService{
+		userRepo:         &fakeUserRepo{},
+		loginAttemptRepo: attempts,
+		emailSender:      sender,
+		log:              zerolog.Nop(),
+	}
+
+	_, err := s.Login(context.Background(), entity.UserLogin{Email: "nobody@example.com", Password: "dummy-password", IP: "10.0.0.1"})
+	if err == nil || errors.Is(err, ErrTooManyRequests) {
+		t.Fatalf("Login() of an unknown email error = %v", err)
+	}
+	if attempts.failures[loginAccountKey("nobody@example.com")] != 1 {
+		t.Error("a failed login to an unknown email was not counted")
+	}
+	if len(sender.sent) != 0 {
+		t.Errorf("notifications = %d, want 0", len(sender.sent))
+	}
+}
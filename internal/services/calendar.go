@@ -0,0 +1,137 @@
+package services
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/internal/storages"
+	"backend-service/pkg/prodcal"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"time"
+)
+
+type CalendarService interface {
+	GetBusinessHours(ctx context.Context, locationID uuid.UUID) ([]entity.BusinessHours, error)
+	SetBusinessHours(ctx context.Context, locationID uuid.UUID, input *entity.WeeklyBusinessHours) error
+	GetDays(ctx context.Context, year int) ([]*entity.CalendarDay, error)
+	SetDay(ctx context.Context, day *entity.CalendarDay) error
+	DeleteDay(ctx context.Context, date string) error
+	Import(ctx context.Context, year int, r io.Reader) (int, error)
+}
+
+type calendarService struct {
+	repo         storages.CalendarRepository
+	locationRepo storages.LocationRepository
+}
+
+func NewCalendarService(repo storages.CalendarRepository, locationRepo storages.LocationRepository) CalendarService {
+	return &calendarService{
+		repo:         repo,
+		locationRepo: locationRepo,
+	}
+}
+
+func (s *calendarService) GetBusinessHours(ctx context.Context, locationID uuid.UUID) ([]entity.BusinessHours, error) {
+	if _, err := s.locationRepo.GetById(ctx, locationID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetBusinessHours(ctx, locationID)
+}
+
+func (s *calendarService) SetBusinessHours(ctx context.Context, locationID uuid.UUID, input *entity.WeeklyBusinessHours) error {
+	if err := input.Validate(); err != nil {
+		return fmt.Errorf("validation error: %w", err)
+	}
+
+	if _, err := s.locationRepo.GetById(ctx, locationID); err != nil {
+		return err
+	}
+
+	return s.repo.SetBusinessHours(ctx, locationID, input.Days)
+}
+
+func (s *calendarService) GetDays(ctx context.Context, year int) ([]*entity.CalendarDay, error) {
+	return s.repo.GetDays(ctx, fmt.Sprintf("%04d-01-01", year), fmt.Sprintf("%04d-12-31", year))
+}
+
+// SetDay saves a manual entry, it replaces an imported one on the same date.
+func (s *calendarService) SetDay(ctx context.Context, day *entity.CalendarDay) error {
+	if err := day.Validate(); err != nil {
+		return fmt.Errorf("validation error: %w", err)
+	}
+
+	day.Source = entity.CalendarSourceManual
+	return s.repo.UpsertDay(ctx, day)
+}
+
+func (s *calendarService) DeleteDay(ctx context.Context, date string) error {
+	return s.repo.DeleteDay(ctx, date)
+}
+
+// Import loads the official production calendar for the year. Holidays,
+// short days and working weekends replace the previous import of that year.
+func (s *calendarService) Import(ctx context.Context, year int, r io.Reader) (int, error) {
+	parsed, err := prodcal.ParseCSV(r, year)
+	if err != nil {
+		return 0, fmt.Errorf("validation error: %w", err)
+	}
+
+	days := make([]*entity.CalendarDay, 0, len(parsed))
+	for _, day := range parsed {
+		days = append(days, &entity.CalendarDay{
+			Date:   day.Date.Format("2006-01-02"),
+			Kind:   entity.CalendarDayKind(day.Kind),
+			Source: entity.CalendarSourceImport,
+		})
+	}
+
+	return s.repo.ReplaceImported(ctx, year, days)
+}
+
+// workshopLocation returns the time zone of the workshop location.
+func workshopLocation(ctx context.Context, repo storages.LocationRepository, locationID uuid.UUID) (*time.Location, error) {
+	location, err := repo.GetById(ctx, locationID)
+	if err != nil {
+		return nil, err
+	}
+
+	return location.Zone()
+}
+
+// loadBusinessCalendar returns the calendar of the location with the days
+// that [from, to) touches.
+func loadBusinessCalendar(
+	ctx context.Context,
+	repo storages.CalendarRepository,
+	locationRepo storages.LocationRepository,
+	locationID uuid.UUID,
+	from, to time.Time,
+) (*entity.BusinessCalendar, error) {
+	loc, err := workshopLocation(ctx, locationRepo, locationID)
+	if err != nil {
+		return nil, err
+	}
+
+	hours, err := repo.GetBusinessHours(ctx, locationID)
+	if err != nil {
+		return nil, err
+	}
+
+	days, err := repo.GetDays(ctx, from.In(loc).Format("2006-01-02"), to.In(loc).Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+
+	calendar := &entity.BusinessCalendar{
+		Location: loc,
+		Hours:    hours,
+		Days:     make(map[string]*entity.CalendarDay, len(days)),
+	}
+	for _, day := range days {
+		calendar.Days[day.Date] = day
+	}
+
+	return calendar, nil
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/internal/storages"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+)
+
+var ErrImpersonationNotAllowed = errors.New("only client accounts can be impersonated")
+
+type ImpersonationService interface {
+	Start(ctx context.Context, actorID, userID uuid.UUID, ip string) (*entity.User, error)
+	Record(ctx context.Context, entry *entity.ImpersonationAuditEntry) error
+	GetLog(ctx context.Context, userID uuid.UUID) ([]*entity.ImpersonationAuditEntry, error)
+}
+
+type impersonationService struct {
+	userRepo  storages.UserRepository
+	auditRepo storages.ImpersonationAuditRepository
+}
+
+func NewImpersonationService(userRepo storages.UserRepository, auditRepo storages.ImpersonationAuditRepository) ImpersonationService {
+	return &impersonationService{
+		userRepo:  userRepo,
+		auditRepo: auditRepo,
+	}
+}
+
+// Start checks that userID may be impersonated and records who started it.
+// Only clients can be impersonated so that staff permissions are never borrowed.
+func (s *impersonationService) Start(ctx context.Context, actorID, userID uuid.UUID, ip string) (*entity.User, error) {
+	user, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Role != entity.RoleClient || user.ID == actorID {
+		return nil, ErrImpersonationNotAllowed
+	}
+
+	if err := s.auditRepo.Create(ctx, &entity.ImpersonationAuditEntry{
+		ActorID: actorID,
+		UserID:  userID,
+		Action:  entity.ImpersonationActionStarted,
+		IP:      ip,
+	}); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *impersonationService) Record(ctx context.Context, entry *entity.ImpersonationAuditEntry) error {
+	return s.auditRepo.Create(ctx, entry)
+}
+
+func (s *impersonationService) GetLog(ctx context.Context, userID uuid.UUID) ([]*entity.ImpersonationAuditEntry, error) {
+	return s.auditRepo.GetByUserId(ctx, userID)
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/internal/storages"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+var ErrLocationInUse = errors.New("location has upcoming appointments")
+
+type LocationService interface {
+	Create(ctx context.Context, location *entity.Location) (uuid.UUID, error)
+	GetById(ctx context.Context, id uuid.UUID) (*entity.Location, error)
+	GetAll(ctx context.Context) ([]*entity.Location, error)
+	Update(ctx context.Context, location *entity.Location) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type locationService struct {
+	repo storages.LocationRepository
+}
+
+func NewLocationService(repo storages.LocationRepository) LocationService {
+	return &locationService{
+		repo: repo,
+	}
+}
+
+func (s *locationService) Create(ctx context.Context, location *entity.Location) (uuid.UUID, error) {
+	if err := location.Validate(); err != nil {
+		return uuid.Nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	location.ID = uuid.New()
+	return s.repo.Create(ctx, location)
+}
+
+func (s *locationService) GetById(ctx context.Context, id uuid.UUID) (*entity.Location, error) {
+	return s.repo.GetById(ctx, id)
+}
+
+func (s *locationService) GetAll(ctx context.Context) ([]*entity.Location, error) {
+	return s.repo.GetAll(ctx)
+}
+
+// Update changes the location. Stored appointments keep their absolute time
+// when the time zone changes, only hours and dates are read in the new zone.
+func (s *locationService) Update(ctx context.Context, location *entity.Location) error {
+	if err := location.Validate(); err != nil {
+		return fmt.Errorf("validation error: %w", err)
+	}
+
+	return s.repo.Update(ctx, location)
+}
+
+// Delete closes the location. Its upcoming appointments have to be moved or
+// cancelled first.
+func (s *locationService) Delete(ctx context.Context, id uuid.UUID) error {
+	busy, err := s.repo.HasUpcomingAppointments(ctx, id)
+	if err != nil {
+		return err
+	}
+	if busy {
+		return ErrLocationInUse
+	}
+
+	return s.repo.Delete(ctx, id)
+}
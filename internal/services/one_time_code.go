@@ -0,0 +1,19 @@
+package services
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// generateCode returns a random numeric code of the given length.
+func generateCode(digits int) (string, error) {
+	code := make([]byte, digits)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		code[i] = byte('0' + n.Int64())
+	}
+	return string(code), nil
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/internal/storages"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrEmailTaken      = errors.New("email is already in use")
+	ErrPhoneTaken      = errors.New("phone is already in use")
+	ErrInvalidPassword = errors.New("invalid password")
+)
+
+type ProfileService interface {
+	Update(ctx context.Context, userID uuid.UUID, input *entity.UserProfileUpdate) (*entity.User, error)
+	ChangePassword(ctx context.Context, userID uuid.UUID, input *entity.PasswordChange) (*entity.User, error)
+	Delete(ctx context.Context, userID uuid.UUID, input *entity.AccountDelete) error
+}
+
+type profileService struct {
+	userRepo storages.UserRepository
+}
+
+func NewProfileService(userRepo storages.UserRepository) ProfileService {
+	return &profileService{
+		userRepo: userRepo,
+	}
+}
+
+func (s *profileService) Update(ctx context.Context, userID uuid.UUID, input *entity.UserProfileUpdate) (*entity.User, error) {
+	if err := input.Validate(); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	user, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.FullName != nil {
+		user.FullName = *input.FullName
+	}
+
+	// A changed contact has to be verified again
+	if input.Email != nil && *input.Email != user.Email {
+		other, err := s.userRepo.GetByEmail(ctx, *input.Email)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to check email: %w", err)
+		}
+		if other != nil {
+			return nil, ErrEmailTaken
+		}
+		user.Email = *input.Email
+		user.EmailVerifiedAt = nil
+	}
+
+	if input.Phone != nil && *input.Phone != user.Phone {
+		other, err := s.userRepo.GetByPhone(ctx, *input.Phone)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to check phone: %w", err)
+		}
+		if other != nil {
+			return nil, ErrPhoneTaken
+		}
+		user.Phone = *input.Phone
+		user.PhoneVerifiedAt = nil
+	}
+
+	// The checks above race with concurrent updates, the unique constraints decide
+	if err := s.userRepo.UpdateProfile(ctx, user); err != nil {
+		switch {
+		case errors.Is(err, storages.ErrUserEmailTaken):
+			return nil, ErrEmailTaken
+		case errors.Is(err, storages.ErrUserPhoneTaken):
+			return nil, ErrPhoneTaken
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *profileService) ChangePassword(ctx context.Context, userID uuid.UUID, input *entity.PasswordChange) (*entity.User, error) {
+	if err := input.Validate(); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	user, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.CheckPasswordHash(input.CurrentPassword) {
+		return nil, ErrInvalidPassword
+	}
+
+	passwordHash, err := entity.HashPassword(input.NewPassword)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, userID, passwordHash); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *profileService) Delete(ctx context.Context, userID uuid.UUID, input *entity.AccountDelete) error {
+	if err := input.Validate(); err != nil {
+		return fmt.Errorf("validation error: %w", err)
+	}
+
+	user, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !user.CheckPasswordHash(input.Password) {
+		return ErrInvalidPassword
+	}
+
+	return s.userRepo.Anonymize(ctx, userID)
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/internal/storages"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+var ErrResourceInUse = errors.New("resource is assigned to upcoming appointments")
+
+type ResourceService interface {
+	Create(ctx context.Context, resource *entity.Resource) (uuid.UUID, error)
+	GetById(ctx context.Context, id uuid.UUID) (*entity.Resource, error)
+	GetAll(ctx context.Context, locationID *uuid.UUID) ([]*entity.Resource, error)
+	Update(ctx context.Context, resource *entity.Resource) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	GetServiceTypes(ctx context.Context, serviceID uuid.UUID) ([]string, error)
+	SetServiceTypes(ctx context.Context, serviceID uuid.UUID, input *entity.ServiceResourceTypes) error
+}
+
+type resourceService struct {
+	repo        storages.ResourceRepository
+	serviceRepo storages.ServiceRepository
+}
+
+func NewResourceService(repo storages.ResourceRepository, serviceRepo storages.ServiceRepository) ResourceService {
+	return &resourceService{
+		repo:        repo,
+		serviceRepo: serviceRepo,
+	}
+}
+
+func (s *resourceService) Create(ctx context.Context, resource *entity.Resource) (uuid.UUID, error) {
+	resource.ID = uuid.New()
+	return s.repo.Create(ctx, resource)
+}
+
+func (s *resourceService) GetById(ctx context.Context, id uuid.UUID) (*entity.Resource, error) {
+	return s.repo.GetById(ctx, id)
+}
+
+func (s *resourceService) GetAll(ctx context.Context, locationID *uuid.UUID) ([]*entity.Resource, error) {
+	return s.repo.GetAll(ctx, locationID)
+}
+
+// Update refuses to change the type or drop capacity while upcoming
+// appointments hold the units that would disappear.
+func (s *resourceService) Update(ctx context.Context, resource *entity.Resource) error {
+	current, err := s.repo.GetById(ctx, resource.ID)
+	if err != nil {
+		return err
+	}
+
+	minUnit := 0
+	switch {
+	case current.Type != resource.Type:
+		minUnit = 1
+	case resource.Capacity < current.Capacity:
+		minUnit = resource.Capacity + 1
+	}
+
+	if minUnit > 0 {
+		inUse, err := s.repo.HasUpcomingAllocations(ctx, resource.ID, minUnit)
+		if err != nil {
+			return err
+		}
+		if inUse {
+			return ErrResourceInUse
+		}
+	}
+
+	return s.repo.Update(ctx, resource)
+}
+
+func (s *resourceService) Delete(ctx context.Context, id uuid.UUID) error {
+	inUse, err := s.repo.HasUpcomingAllocations(ctx, id, 1)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return ErrResourceInUse
+	}
+
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *resourceService) GetServiceTypes(ctx context.Context, serviceID uuid.UUID) ([]string, error) {
+	if _, err := s.serviceRepo.GetById(ctx, serviceID); err != nil {
+		return nil, fmt.Errorf("failed to get service: %w", err)
+	}
+
+	return s.repo.GetServiceTypes(ctx, serviceID)
+}
+
+// SetServiceTypes replaces the resource types a service needs. It only affects
+// new bookings and services changed later, existing assignments are kept.
+func (s *resourceService) SetServiceTypes(ctx context.Context, serviceID uuid.UUID, input *entity.ServiceResourceTypes) error {
+	if err := input.Validate(); err != nil {
+		return fmt.Errorf("validation error: %w", err)
+	}
+
+	if _, err := s.serviceRepo.GetById(ctx, serviceID); err != nil {
+		return fmt.Errorf("failed to get service: %w", err)
+	}
+
+	return s.repo.SetServiceTypes(ctx, serviceID, input.ResourceTypes)
+}
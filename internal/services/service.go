@@ -1,33 +1,113 @@
 package services
 
 import (
+	"backend-service/internal/config"
 	"backend-service/internal/storages"
+	"backend-service/pkg/notify"
+	"backend-service/pkg/totp"
 	"github.com/rs/zerolog"
+	"time"
 )
 
+// ErrNotFound is returned, wrapped, when the requested row does not exist.
+var ErrNotFound = storages.ErrNotFound
+
 type Service struct {
-	AuthService        AuthService
-	UserRoleService    UserRoleService
-	ServiceService     ServiceService
-	VehicleService     VehicleService
-	AppointmentService AppointmentService
+	AuthService          AuthService
+	SessionService       SessionService
+	TwoFactorService     TwoFactorService
+	ImpersonationService ImpersonationService
+	APIKeyService        APIKeyService
+	VerificationService  VerificationService
+	ProfileService       ProfileService
+	UserRoleService      UserRoleService
+	LocationService      LocationService
+	ServiceService       ServiceService
+	ResourceService      ResourceService
+	StaffService         StaffService
+	CalendarService      CalendarService
+	WaitlistService      WaitlistService
+	VehicleService       VehicleService
+	AppointmentService   AppointmentService
+	WorkOrderService     WorkOrderService
 }
 
 type ServiceDeps struct {
-	Log     zerolog.Logger
-	Storage *storages.Storage
+	Log         zerolog.Logger
+	Storage     *storages.Storage
+	EmailSender notify.Sender
+	SMSSender   notify.Sender
+	Booking     config.Booking
+	TwoFactor   config.TwoFactor
+	TOTPCipher  *totp.SecretCipher
+	SessionTTL  time.Duration
 }
 
 func NewService(deps ServiceDeps) *Service {
 	return &Service{
-		AuthService:     NewAuthService(deps.Storage.UserRepository),
-		UserRoleService: NewUserRoleService(deps.Storage.UserRepository),
+		AuthService: NewAuthService(
+			deps.Storage.UserRepository,
+			deps.Storage.OneTimeCodeRepository,
+			deps.Storage.LoginAttemptRepository,
+			deps.Storage.TwoFactorChallengeRepository,
+			deps.EmailSender,
+			deps.SMSSender,
+			deps.Log,
+		),
+		SessionService: NewSessionService(deps.Storage.SessionRepository, deps.SessionTTL),
+		TwoFactorService: NewTwoFactorService(
+			deps.Storage.UserRepository,
+			deps.Storage.RecoveryCodeRepository,
+			deps.Storage.TwoFactorChallengeRepository,
+			deps.TOTPCipher,
+			deps.TwoFactor,
+		),
+		ImpersonationService: NewImpersonationService(
+			deps.Storage.UserRepository,
+			deps.Storage.ImpersonationAuditRepository,
+		),
+		VerificationService: NewVerificationService(
+			deps.Storage.UserRepository,
+			deps.Storage.OneTimeCodeRepository,
+			deps.EmailSender,
+			deps.SMSSender,
+		),
+		APIKeyService:   NewAPIKeyService(deps.Storage.APIKeyRepository, deps.Log),
+		ProfileService:  NewProfileService(deps.Storage.UserRepository),
+		UserRoleService: NewUserRoleService(deps.Storage.UserRepository, deps.Storage.LocationRepository),
+		LocationService: NewLocationService(deps.Storage.LocationRepository),
 		ServiceService:  NewServiceService(deps.Storage.ServiceRepository),
-		VehicleService:  NewVehicleService(deps.Storage.VehicleRepository),
+		ResourceService: NewResourceService(deps.Storage.ResourceRepository, deps.Storage.ServiceRepository),
+		CalendarService: NewCalendarService(deps.Storage.CalendarRepository, deps.Storage.LocationRepository),
+		StaffService: NewStaffService(
+			deps.Storage.StaffRepository,
+			deps.Storage.UserRepository,
+			deps.Storage.AppointmentRepository,
+			deps.Storage.LocationRepository,
+		),
+		VehicleService: NewVehicleService(deps.Storage.VehicleRepository),
+		WaitlistService: NewWaitlistService(
+			deps.Storage.WaitlistRepository,
+			deps.Storage.VehicleRepository,
+			deps.Storage.ServiceRepository,
+			deps.Storage.UserRepository,
+			deps.Booking,
+		),
 		AppointmentService: NewAppointmentService(
 			deps.Storage.AppointmentRepository,
 			deps.Storage.VehicleRepository,
 			deps.Storage.ServiceRepository,
+			deps.Storage.ResourceRepository,
+			deps.Storage.StaffRepository,
+			deps.Storage.CalendarRepository,
+			deps.Storage.LocationRepository,
+			deps.Storage.WaitlistRepository,
+			deps.Storage.UserRepository,
+			deps.EmailSender,
+			deps.SMSSender,
+			deps.Booking,
+			deps.Log,
 		),
+		WorkOrderService: NewWorkOrderService(deps.Storage.WorkOrderRepository, deps.Storage.AppointmentRepository),
 	}
 }
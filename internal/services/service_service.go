@@ -10,7 +10,7 @@ import (
 type ServiceService interface {
 	Create(ctx context.Context, service *entity.Service) (uuid.UUID, error)
 	GetById(ctx context.Context, id uuid.UUID) (*entity.Service, error)
-	GetAll(ctx context.Context) ([]*entity.Service, error)
+	GetAll(ctx context.Context, locationID *uuid.UUID) ([]*entity.Service, error)
 	Update(ctx context.Context, service *entity.Service) (uuid.UUID, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 }
@@ -39,8 +39,8 @@ func (s *serviceService) GetById(ctx context.Context, id uuid.UUID) (*entity.Ser
 	return s.repo.GetById(ctx, id)
 }
 
-func (s *serviceService) GetAll(ctx context.Context) ([]*entity.Service, error) {
-	return s.repo.GetAll(ctx)
+func (s *serviceService) GetAll(ctx context.Context, locationID *uuid.UUID) ([]*entity.Service, error) {
+	return s.repo.GetAll(ctx, locationID)
 }
 
 func (s *serviceService) Update(ctx context.Context, service *entity.Service) (uuid.UUID, error) {
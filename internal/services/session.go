@@ -0,0 +1,65 @@
+package services
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/internal/storages"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+type SessionService interface {
+	Start(ctx context.Context, userID uuid.UUID, meta entity.SessionMeta) (*entity.Session, error)
+	GetByUserId(ctx context.Context, userID uuid.UUID) ([]*entity.Session, error)
+	Touch(ctx context.Context, id uuid.UUID, ip string) error
+	Revoke(ctx context.Context, userID, id uuid.UUID) error
+	RevokeAll(ctx context.Context, userID uuid.UUID) error
+}
+
+type sessionService struct {
+	repo storages.SessionRepository
+	ttl  time.Duration
+}
+
+// NewSessionService creates a session service. ttl matches the refresh token
+// lifetime: a session lives as long as its refresh token can be used.
+func NewSessionService(repo storages.SessionRepository, ttl time.Duration) SessionService {
+	return &sessionService{
+		repo: repo,
+		ttl:  ttl,
+	}
+}
+
+func (s *sessionService) Start(ctx context.Context, userID uuid.UUID, meta entity.SessionMeta) (*entity.Session, error) {
+	session := &entity.Session{
+		ID:         uuid.New(),
+		UserID:     userID,
+		DeviceName: meta.DeviceName,
+		IP:         meta.IP,
+		UserAgent:  meta.UserAgent,
+		ExpiresAt:  time.Now().Add(s.ttl),
+	}
+
+	if _, err := s.repo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (s *sessionService) GetByUserId(ctx context.Context, userID uuid.UUID) ([]*entity.Session, error) {
+	return s.repo.GetActiveByUserId(ctx, userID)
+}
+
+// Touch records session activity and slides its expiry with the refreshed token.
+func (s *sessionService) Touch(ctx context.Context, id uuid.UUID, ip string) error {
+	return s.repo.Touch(ctx, id, ip, time.Now().Add(s.ttl))
+}
+
+func (s *sessionService) Revoke(ctx context.Context, userID, id uuid.UUID) error {
+	return s.repo.Revoke(ctx, id, userID)
+}
+
+func (s *sessionService) RevokeAll(ctx context.Context, userID uuid.UUID) error {
+	return s.repo.RevokeAllByUserId(ctx, userID)
+}
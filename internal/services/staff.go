@@ -0,0 +1,250 @@
+package services
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/internal/storages"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+var (
+	ErrNotStaff            = errors.New("clients cannot have a staff profile")
+	ErrNoMechanicAvailable = errors.New("no mechanic is available at this time")
+	ErrMechanicUnavailable = errors.New("mechanic is not available at this time")
+	ErrNoLocation          = errors.New("mechanic is not assigned to a location")
+)
+
+type StaffService interface {
+	GetAll(ctx context.Context, locationID *uuid.UUID) ([]*entity.StaffProfile, error)
+	GetById(ctx context.Context, userID uuid.UUID) (*entity.StaffProfile, error)
+	Update(ctx context.Context, userID uuid.UUID, input *entity.StaffProfileUpdate) (*entity.StaffProfile, error)
+	SetSchedule(ctx context.Context, userID uuid.UUID, input *entity.WeeklySchedule) error
+	GetExceptions(ctx context.Context, userID uuid.UUID) ([]*entity.ScheduleException, error)
+	CreateException(ctx context.Context, exception *entity.ScheduleException) (uuid.UUID, error)
+	DeleteException(ctx context.Context, userID, exceptionID uuid.UUID) error
+	GetJobs(ctx context.Context, userID uuid.UUID, day time.Time) ([]*entity.Appointment, error)
+}
+
+type staffService struct {
+	staffRepo       storages.StaffRepository
+	userRepo        storages.UserRepository
+	appointmentRepo storages.AppointmentRepository
+	locationRepo    storages.LocationRepository
+}
+
+func NewStaffService(
+	staffRepo storages.StaffRepository,
+	userRepo storages.UserRepository,
+	appointmentRepo storages.AppointmentRepository,
+	locationRepo storages.LocationRepository,
+) StaffService {
+	return &staffService{
+		staffRepo:       staffRepo,
+		userRepo:        userRepo,
+		appointmentRepo: appointmentRepo,
+		locationRepo:    locationRepo,
+	}
+}
+
+func (s *staffService) GetAll(ctx context.Context, locationID *uuid.UUID) ([]*entity.StaffProfile, error) {
+	return s.staffRepo.GetAll(ctx, locationID)
+}
+
+func (s *staffService) GetById(ctx context.Context, userID uuid.UUID) (*entity.StaffProfile, error) {
+	return s.staffRepo.GetById(ctx, userID)
+}
+
+// Update creates the profile on first use, so any staff member can be made a performer.
+func (s *staffService) Update(ctx context.Context, userID uuid.UUID, input *entity.StaffProfileUpdate) (*entity.StaffProfile, error) {
+	if err := input.Validate(); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	user, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == entity.RoleClient {
+		return nil, ErrNotStaff
+	}
+
+	if err := s.staffRepo.Upsert(ctx, userID, input); err != nil {
+		return nil, err
+	}
+
+	return s.staffRepo.GetById(ctx, userID)
+}
+
+// SetSchedule replaces the weekly schedule. Appointments already assigned
+// are kept even if they now fall outside working hours.
+func (s *staffService) SetSchedule(ctx context.Context, userID uuid.UUID, input *entity.WeeklySchedule) error {
+	if err := input.Validate(); err != nil {
+		return fmt.Errorf("validation error: %w", err)
+	}
+
+	if _, err := s.staffRepo.GetById(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.staffRepo.SetSchedule(ctx, userID, input.Days)
+}
+
+func (s *staffService) GetExceptions(ctx context.Context, userID uuid.UUID) ([]*entity.ScheduleException, error) {
+	return s.staffRepo.GetExceptions(ctx, userID)
+}
+
+func (s *staffService) CreateException(ctx context.Context, exception *entity.ScheduleException) (uuid.UUID, error) {
+	if err := exception.Validate(); err != nil {
+		return uuid.Nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	if _, err := s.staffRepo.GetById(ctx, exception.UserID); err != nil {
+		return uuid.Nil, err
+	}
+
+	exception.ID = uuid.New()
+	return s.staffRepo.CreateException(ctx, exception)
+}
+
+func (s *staffService) DeleteException(ctx context.Context, userID, exceptionID uuid.UUID) error {
+	return s.staffRepo.DeleteException(ctx, userID, exceptionID)
+}
+
+// GetJobs returns the appointments assigned to the mechanic that start on the
+// given calendar date in the time zone of the mechanic's location. A zero day
+// means today. Mechanics without a location are never assigned jobs, so they
+// get ErrNoLocation rather than an empty day.
+func (s *staffService) GetJobs(ctx context.Context, userID uuid.UUID, day time.Time) ([]*entity.Appointment, error) {
+	user, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.LocationID == nil {
+		return nil, ErrNoLocation
+	}
+
+	loc, err := workshopLocation(ctx, s.locationRepo, *user.LocationID)
+	if err != nil {
+		return nil, err
+	}
+	if day.IsZero() {
+		day = time.Now().In(loc)
+	}
+
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+	return s.appointmentRepo.GetByMechanicId(ctx, userID, from, from.AddDate(0, 0, 1))
+}
+
+// mechanicPool is a snapshot of who works, who is absent and who is busy
+// at a location over a period, used to pick mechanics for appointments in it.
+// Schedules are read by the clock of loc, the location time zone.
+type mechanicPool struct {
+	loc        *time.Location
+	profiles   []*entity.StaffProfile
+	exceptions map[uuid.UUID][]*entity.ScheduleException
+	busy       map[uuid.UUID][]entity.MechanicAllocation
+}
+
+func loadMechanicPool(
+	ctx context.Context,
+	staffRepo storages.StaffRepository,
+	appointmentRepo storages.AppointmentRepository,
+	locationID uuid.UUID,
+	loc *time.Location,
+	from, to time.Time,
+) (*mechanicPool, error) {
+	profiles, err := staffRepo.GetActive(ctx, locationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get staff: %w", err)
+	}
+
+	pool := &mechanicPool{
+		loc:        loc,
+		profiles:   profiles,
+		exceptions: make(map[uuid.UUID][]*entity.ScheduleException),
+		busy:       make(map[uuid.UUID][]entity.MechanicAllocation),
+	}
+	if len(profiles) == 0 {
+		return pool, nil
+	}
+
+	exceptions, err := staffRepo.GetExceptionsInRange(ctx, from.In(loc), to.In(loc))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule exceptions: %w", err)
+	}
+	for _, exception := range exceptions {
+		pool.exceptions[exception.UserID] = append(pool.exceptions[exception.UserID], exception)
+	}
+
+	userIDs := make([]uuid.UUID, 0, len(profiles))
+	for _, profile := range profiles {
+		userIDs = append(userIDs, profile.UserID)
+	}
+
+	allocations, err := appointmentRepo.GetMechanicAllocations(ctx, userIDs, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mechanic allocations: %w", err)
+	}
+	for _, allocation := range allocations {
+		pool.busy[allocation.UserID] = append(pool.busy[allocation.UserID], allocation)
+	}
+
+	return pool, nil
+}
+
+// available reports whether the mechanic can take services of the categories
+// during the slot. Work on excludeID does not count as busy.
+func (p *mechanicPool) available(profile *entity.StaffProfile, categories []string, slot entity.TimeRange, excludeID uuid.UUID) bool {
+	if !profile.HasSkills(categories) {
+		return false
+	}
+
+	slot = slot.In(p.loc)
+	hours := profile.WorkingHoursOn(slot.Start.Weekday())
+	if hours == nil || !hours.Covers(slot) {
+		return false
+	}
+
+	for _, exception := range p.exceptions[profile.UserID] {
+		if exception.Covers(slot.Start) {
+			return false
+		}
+	}
+
+	for _, allocation := range p.busy[profile.UserID] {
+		if allocation.AppointmentID != excludeID && allocation.Overlaps(slot) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// pick returns the available mechanic with the fewest jobs in the period,
+// or uuid.Nil if nobody fits.
+func (p *mechanicPool) pick(categories []string, slot entity.TimeRange, excludeID uuid.UUID) uuid.UUID {
+	picked := uuid.Nil
+	load := 0
+	for _, profile := range p.profiles {
+		if !p.available(profile, categories, slot, excludeID) {
+			continue
+		}
+		if picked == uuid.Nil || len(p.busy[profile.UserID]) < load {
+			picked = profile.UserID
+			load = len(p.busy[profile.UserID])
+		}
+	}
+	return picked
+}
+
+func (p *mechanicPool) profile(userID uuid.UUID) *entity.StaffProfile {
+	for _, profile := range p.profiles {
+		if profile.UserID == userID {
+			return profile
+		}
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package services
+
+import (
+	"backend-service/internal/config"
+	"backend-service/internal/entity"
+	"backend-service/internal/storages"
+	"backend-service/pkg/totp"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	twoFactorChallengeTTL         = 5 * time.Minute
+	twoFactorChallengeMaxAttempts = 5
+
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+	// Without 0/o and 1/l so codes survive being copied from paper
+	recoveryCodeAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorRequired       = errors.New("two-factor authentication is required for your role")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrTwoFactorUnavailable    = errors.New("two-factor authentication is not configured")
+)
+
+type TwoFactorService interface {
+	Enroll(ctx context.Context, userID uuid.UUID) (*entity.TOTPEnrollment, error)
+	Confirm(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+	Disable(ctx context.Context, userID uuid.UUID, input *entity.TwoFactorDisable) error
+	RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+	Reset(ctx context.Context, userID uuid.UUID) error
+	CompleteLogin(ctx context.Context, input entity.TwoFactorLogin) (*entity.User, error)
+	IsRequired(role entity.Role) bool
+}
+
+type twoFactorService struct {
+	userRepo      storages.UserRepository
+	recoveryRepo  storages.RecoveryCodeRepository
+	challengeRepo storages.TwoFactorChallengeRepository
+	cipher        *totp.SecretCipher
+	cfg           config.TwoFactor
+}
+
+func NewTwoFactorService(
+	userRepo storages.UserRepository,
+	recoveryRepo storages.RecoveryCodeRepository,
+	challengeRepo storages.TwoFactorChallengeRepository,
+	cipher *totp.SecretCipher,
+	cfg config.TwoFactor,
+) TwoFactorService {
+	return &twoFactorService{
+		userRepo:      userRepo,
+		recoveryRepo:  recoveryRepo,
+		challengeRepo: challengeRepo,
+		cipher:        cipher,
+		cfg:           cfg,
+	}
+}
+
+// Enroll generates a new pending secret. It replaces any previous pending
+// secret, but an already confirmed one has to be disabled first. The secret
+// is stored encrypted and bound to the user, so enrolling needs the secret key.
+func (s *twoFactorService) Enroll(ctx context.Context, userID uuid.UUID) (*entity.TOTPEnrollment, error) {
+	if s.cipher == nil {
+		return nil, ErrTwoFactorUnavailable
+	}
+
+	user, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TwoFactorEnabled() {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+
+	sealed, err := s.cipher.Seal(secret, userID[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+
+	if err := s.userRepo.SetTOTPSecret(ctx, userID, sealed); err != nil {
+		return nil, err
+	}
+
+	account := user.Email
+	if account == "" {
+		account = user.Phone
+	}
+
+	return &entity.TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(secret, s.cfg.Issuer, account),
+	}, nil
+}
+
+// Confirm enables TOTP once the user enters a valid code from the app and
+// returns the initial set of recovery codes.
+func (s *twoFactorService) Confirm(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	user, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TwoFactorEnabled() {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotEnabled
+	}
+
+	if err := s.verifyTOTP(ctx, user, code); err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.EnableTOTP(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return s.issueRecoveryCodes(ctx, userID)
+}
+
+func (s *twoFactorService) Disable(ctx context.Context, userID uuid.UUID, input *entity.TwoFactorDisable) error {
+	user, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !user.TwoFactorEnabled() {
+		return ErrTwoFactorNotEnabled
+	}
+	if s.IsRequired(user.Role) {
+		return ErrTwoFactorRequired
+	}
+
+	if !user.CheckPasswordHash(input.Password) {
+		return ErrInvalidPassword
+	}
+
+	if err := s.verify(ctx, user, input.Code); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.DisableTOTP(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.recoveryRepo.DeleteByUserId(ctx, userID)
+}
+
+// RegenerateRecoveryCodes invalidates all previous recovery codes.
+func (s *twoFactorService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	user, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.TwoFactorEnabled() {
+		return nil, ErrTwoFactorNotEnabled
+	}
+
+	if err := s.verifyTOTP(ctx, user, code); err != nil {
+		return nil, err
+	}
+
+	return s.issueRecoveryCodes(ctx, userID)
+}
+
+// Reset turns TOTP off without any code, for an admin helping a user who lost
+// both the device and the recovery codes.
+func (s *twoFactorService) Reset(ctx context.Context, userID uuid.UUID) error {
+	if err := s.userRepo.DisableTOTP(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.recoveryRepo.DeleteByUserId(ctx, userID)
+}
+
+// CompleteLogin finishes a login started by AuthService with the second factor.
+// The challenge survives a wrong code until its attempts are exhausted.
+func (s *twoFactorService) CompleteLogin(ctx context.Context, input entity.TwoFactorLogin) (*entity.User, error) {
+	userID, err := s.challengeRepo.Attempt(ctx, input.Token, twoFactorChallengeMaxAttempts)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.verify(ctx, user, input.Code); err != nil {
+		return nil, err
+	}
+
+	if err := s.challengeRepo.Delete(ctx, input.Token); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *twoFactorService) IsRequired(role entity.Role) bool {
+	for _, r := range s.cfg.RequiredRoles {
+		if entity.Role(r) == role {
+			return true
+		}
+	}
+	return false
+}
+
+// verify accepts either a TOTP code or an unused recovery code.
+func (s *twoFactorService) verify(ctx context.Context, user *entity.User, code string) error {
+	code = normalizeRecoveryCode(code)
+	if len(code) == totp.Digits {
+		return s.verifyTOTP(ctx, user, code)
+	}
+
+	used, err := s.recoveryRepo.Use(ctx, user.ID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+// verifyTOTP checks the code and burns its time step, so the same code
+// cannot be used twice within its validity window.
+func (s *twoFactorService) verifyTOTP(ctx context.Context, user *entity.User, code string) error {
+	if s.cipher == nil {
+		return ErrTwoFactorUnavailable
+	}
+
+	secret, err := s.cipher.Open(user.TOTPSecret, user.ID[:])
+	if err != nil {
+		return err
+	}
+
+	step, err := totp.Validate(secret, strings.TrimSpace(code), time.Now())
+	if err != nil {
+		return ErrInvalidTwoFactorCode
+	}
+
+	fresh, err := s.userRepo.UseTOTPStep(ctx, user.ID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+func (s *twoFactorService) issueRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		// Shown as xxxxx-xxxxx, the dash is ignored on input
+		codes = append(codes, code[:recoveryCodeLength/2]+"-"+code[recoveryCodeLength/2:])
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	if err := s.recoveryRepo.Replace(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// startTwoFactorChallenge creates the intermediate token returned by the
+// first login step to users with TOTP enabled.
+func startTwoFactorChallenge(ctx context.Context, repo storages.TwoFactorChallengeRepository, userID uuid.UUID) (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+
+	encoded := hex.EncodeToString(token)
+	if err := repo.Create(ctx, encoded, userID, twoFactorChallengeTTL); err != nil {
+		return "", err
+	}
+
+	return encoded, nil
+}
+
+func generateRecoveryCode() (string, error) {
+	code := make([]byte, recoveryCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(recoveryCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = recoveryCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func hashRecoveryCode(code string) string {
+	hash := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(hash[:])
+}
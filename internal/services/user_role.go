@@ -4,31 +4,30 @@ import (
 	"backend-service/internal/entity"
 	"backend-service/internal/storages"
 	"context"
+	"errors"
 	"github.com/google/uuid"
 )
 
+var ErrClientLocation = errors.New("clients are not bound to a location")
+
 type UserRoleService interface {
-	IsAdmin(ctx context.Context, userId uuid.UUID) (bool, error)
 	GetById(ctx context.Context, id uuid.UUID) (*entity.User, error)
 	GetAllClients(ctx context.Context) ([]*entity.User, error)
+	SetRole(ctx context.Context, id uuid.UUID, role entity.Role) error
+	ResetNoShows(ctx context.Context, id uuid.UUID, locationID *uuid.UUID) error
+	SetLocation(ctx context.Context, id uuid.UUID, input *entity.UserLocationUpdate) error
 }
 
 type userRoleService struct {
-	userService storages.UserRepository
+	userService  storages.UserRepository
+	locationRepo storages.LocationRepository
 }
 
-func NewUserRoleService(userService storages.UserRepository) UserRoleService {
+func NewUserRoleService(userService storages.UserRepository, locationRepo storages.LocationRepository) UserRoleService {
 	return &userRoleService{
-		userService: userService,
-	}
-}
-
-func (u *userRoleService) IsAdmin(ctx context.Context, userId uuid.UUID) (bool, error) {
-	user, err := u.userService.GetById(ctx, userId)
-	if err != nil {
-		return false, err
+		userService:  userService,
+		locationRepo: locationRepo,
 	}
-	return user.IsAdmin, nil
 }
 
 func (u *userRoleService) GetById(ctx context.Context, id uuid.UUID) (*entity.User, error) {
@@ -38,3 +37,38 @@ func (u *userRoleService) GetById(ctx context.Context, id uuid.UUID) (*entity.Us
 func (u *userRoleService) GetAllClients(ctx context.Context) ([]*entity.User, error) {
 	return u.userService.GetAllClients(ctx)
 }
+
+func (u *userRoleService) SetRole(ctx context.Context, id uuid.UUID, role entity.Role) error {
+	if err := role.Validate(); err != nil {
+		return err
+	}
+	return u.userService.UpdateRole(ctx, id, role)
+}
+
+// ResetNoShows forgives the client's missed appointments so the no-show
+// policy no longer restricts their bookings. Staff bound to a location may
+// only reset clients who missed an appointment there.
+func (u *userRoleService) ResetNoShows(ctx context.Context, id uuid.UUID, locationID *uuid.UUID) error {
+	return u.userService.ResetNoShows(ctx, id, locationID)
+}
+
+// SetLocation binds the staff member to a location: their permissions apply
+// only there and, for mechanics, jobs are assigned only there. A nil location
+// lifts the restriction.
+func (u *userRoleService) SetLocation(ctx context.Context, id uuid.UUID, input *entity.UserLocationUpdate) error {
+	user, err := u.userService.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user.Role == entity.RoleClient {
+		return ErrClientLocation
+	}
+
+	if input.LocationID != nil {
+		if _, err := u.locationRepo.GetById(ctx, *input.LocationID); err != nil {
+			return err
+		}
+	}
+
+	return u.userService.UpdateLocation(ctx, id, input.LocationID)
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/internal/storages"
+	"backend-service/pkg/notify"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	verificationPurpose     = "verification"
+	verificationCodeLength  = 6
+	verificationCodeTTL     = 15 * time.Minute
+	verificationMaxAttempts = 5
+	verificationLimit       = 5
+	verificationWindow      = time.Hour
+)
+
+type VerificationService interface {
+	SendCode(ctx context.Context, userID uuid.UUID, channel entity.VerificationChannel) error
+	Confirm(ctx context.Context, userID uuid.UUID, channel entity.VerificationChannel, code string) error
+}
+
+type verificationService struct {
+	userRepo    storages.UserRepository
+	codeRepo    storages.OneTimeCodeRepository
+	emailSender notify.Sender
+	smsSender   notify.Sender
+}
+
+func NewVerificationService(
+	userRepo storages.UserRepository,
+	codeRepo storages.OneTimeCodeRepository,
+	emailSender notify.Sender,
+	smsSender notify.Sender,
+) VerificationService {
+	return &verificationService{
+		userRepo:    userRepo,
+		codeRepo:    codeRepo,
+		emailSender: emailSender,
+		smsSender:   smsSender,
+	}
+}
+
+func (s *verificationService) SendCode(ctx context.Context, userID uuid.UUID, channel entity.VerificationChannel) error {
+	if err := channel.Validate(); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// Codes are bound to the contact, so changing it invalidates a pending code
+	contact, sender := user.Email, s.emailSender
+	if channel == entity.VerificationChannelPhone {
+		contact, sender = user.Phone, s.smsSender
+	}
+	if contact == "" {
+		return fmt.Errorf("%s is not set", channel)
+	}
+
+	allowed, err := s.codeRepo.Throttle(ctx, verificationPurpose, contact, verificationLimit, verificationWindow)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrTooManyRequests
+	}
+
+	code, err := generateCode(verificationCodeLength)
+	if err != nil {
+		return fmt.Errorf("failed to generate code: %w", err)
+	}
+
+	if err := s.codeRepo.Save(ctx, verificationPurpose, contact, code, verificationCodeTTL); err != nil {
+		return err
+	}
+
+	return sender.Send(ctx, notify.Message{
+		To:      contact,
+		Subject: "Contact verification",
+		Body:    fmt.Sprintf("Your verification code: %s", code),
+	})
+}
+
+func (s *verificationService) Confirm(ctx context.Context, userID uuid.UUID, channel entity.VerificationChannel, code string) error {
+	if err := channel.Validate(); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	contact := user.Email
+	if channel == entity.VerificationChannelPhone {
+		contact = user.Phone
+	}
+
+	valid, err := s.codeRepo.Verify(ctx, verificationPurpose, contact, code, verificationMaxAttempts)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return fmt.Errorf("invalid or expired code")
+	}
+
+	return s.userRepo.MarkVerified(ctx, userID, channel)
+}
@@ -0,0 +1,400 @@
+package services
+
+import (
+	"backend-service/internal/config"
+	"backend-service/internal/entity"
+	"backend-service/internal/storages"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+var (
+	ErrWaitlistEntryNotWaiting = errors.New("only a waiting entry can be left, decline the offer first")
+	ErrNotOfferRecipient       = errors.New("offer was made to another client")
+	ErrWaitlistOfferClosed     = errors.New("offer is no longer available")
+)
+
+type WaitlistService interface {
+	Join(ctx context.Context, userID uuid.UUID, input *entity.WaitlistEntryCreate) (uuid.UUID, error)
+	GetById(ctx context.Context, id uuid.UUID) (*entity.WaitlistEntry, error)
+	GetByUserId(ctx context.Context, userID uuid.UUID) ([]*entity.WaitlistEntry, error)
+	Leave(ctx context.Context, id uuid.UUID) error
+}
+
+type waitlistService struct {
+	waitlistRepo storages.WaitlistRepository
+	vehicleRepo  storages.VehicleRepository
+	serviceRepo  storages.ServiceRepository
+	userRepo     storages.UserRepository
+	booking      config.Booking
+}
+
+func NewWaitlistService(
+	waitlistRepo storages.WaitlistRepository,
+	vehicleRepo storages.VehicleRepository,
+	serviceRepo storages.ServiceRepository,
+	userRepo storages.UserRepository,
+	booking config.Booking,
+) WaitlistService {
+	return &waitlistService{
+		waitlistRepo: waitlistRepo,
+		vehicleRepo:  vehicleRepo,
+		serviceRepo:  serviceRepo,
+		userRepo:     userRepo,
+		booking:      booking,
+	}
+}
+
+// Join puts the client on the waitlist for the dates. Offers are made when
+// a cancellation frees time on one of them. Joining is booking, so the
+// client needs a verified contact when booking does.
+func (s *waitlistService) Join(ctx context.Context, userID uuid.UUID, input *entity.WaitlistEntryCreate) (uuid.UUID, error) {
+	if err := input.Validate(); err != nil {
+		return uuid.Nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	user, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if s.booking.RequireVerifiedContact && !user.HasVerifiedContact() {
+		return uuid.Nil, ErrContactNotVerified
+	}
+
+	vehicle, err := s.vehicleRepo.GetById(ctx, input.VehicleID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get vehicle: %w", err)
+	}
+	if vehicle.UserID != userID {
+		return uuid.Nil, fmt.Errorf("vehicle does not belong to the user")
+	}
+
+	for _, id := range input.ServiceIDs {
+		service, err := s.serviceRepo.GetById(ctx, id)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("failed to get service %s: %w", id, err)
+		}
+		if service.LocationID != input.LocationID {
+			return uuid.Nil, fmt.Errorf("service %s is not offered at this location", id)
+		}
+	}
+
+	return s.waitlistRepo.Create(ctx, input.ToEntry(userID))
+}
+
+func (s *waitlistService) GetById(ctx context.Context, id uuid.UUID) (*entity.WaitlistEntry, error) {
+	return s.waitlistRepo.GetById(ctx, id)
+}
+
+func (s *waitlistService) GetByUserId(ctx context.Context, userID uuid.UUID) ([]*entity.WaitlistEntry, error) {
+	return s.waitlistRepo.GetByUserId(ctx, userID)
+}
+
+func (s *waitlistService) Leave(ctx context.Context, id uuid.UUID) error {
+	if err := s.waitlistRepo.Cancel(ctx, id); err != nil {
+		if errors.Is(err, storages.ErrWaitlistEntryTaken) {
+			return ErrWaitlistEntryNotWaiting
+		}
+		return err
+	}
+	return nil
+}
+
+// slotFreed is called after the appointment stopped holding its slot. If it
+// was held for a waitlist offer, the offer counts as declined. The start time
+// then goes to the next client on the waitlist.
+func (s *appointmentService) slotFreed(ctx context.Context, appointment *entity.Appointment, previous entity.AppointmentStatus) {
+	skipEntryID := uuid.Nil
+	if previous == entity.AppointmentStatusReserved {
+		offer, err := s.waitlistRepo.GetOfferByAppointmentId(ctx, appointment.ID)
+		if err != nil {
+			s.log.Error().Err(err).Str("appointment_id", appointment.ID.String()).Msg("error getting waitlist offer")
+			return
+		}
+		if offer != nil {
+			err := s.waitlistRepo.ResolveOffer(ctx, offer, entity.WaitlistOfferDeclined, entity.WaitlistStatusWaiting)
+			if err != nil && !errors.Is(err, storages.ErrWaitlistOfferClosed) {
+				s.log.Error().Err(err).Str("offer_id", offer.ID.String()).Msg("error declining waitlist offer")
+			}
+			skipEntryID = offer.EntryID
+		}
+	}
+
+	s.offerFreedSlot(ctx, appointment, skipEntryID)
+}
+
+// offerFreedSlot offers the start time of the freed appointment to the first
+// client waitlisted at its location whose services fit there and reserves it
+// for them.
+// skipEntryID is passed over, so a declined offer is not repeated. Failures
+// are logged: the cancellation that freed the slot has already happened.
+func (s *appointmentService) offerFreedSlot(ctx context.Context, freed *entity.Appointment, skipEntryID uuid.UUID) {
+	if !freed.AppointmentTime.After(time.Now()) {
+		return
+	}
+
+	loc, err := workshopLocation(ctx, s.locationRepo, freed.LocationID)
+	if err != nil {
+		s.log.Error().Err(err).Msg("error getting workshop time zone")
+		return
+	}
+
+	entries, err := s.waitlistRepo.GetWaiting(ctx, freed.LocationID, freed.AppointmentTime.In(loc).Format("2006-01-02"))
+	if err != nil {
+		s.log.Error().Err(err).Msg("error getting waitlist")
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.ID == skipEntryID {
+			continue
+		}
+
+		offer, err := s.reserveForEntry(ctx, entry, freed.AppointmentTime)
+		if err != nil {
+			if errors.Is(err, ErrTimeSlotUnavailable) || errors.Is(err, ErrNoMechanicAvailable) ||
+				errors.Is(err, ErrOutsideBusinessHours) || errors.Is(err, ErrBookingBlocked) ||
+				errors.Is(err, ErrContactNotVerified) ||
+				errors.Is(err, storages.ErrWaitlistEntryTaken) {
+				continue
+			}
+			s.log.Error().Err(err).Str("entry_id", entry.ID.String()).Msg("error making waitlist offer")
+			return
+		}
+
+		s.notifyClient(ctx, entry.UserID, "A slot has opened up", fmt.Sprintf(
+			"A slot on %s is reserved for you until %s. Accept the offer in the app to keep it.",
+			offer.AppointmentTime.In(loc).Format("02.01.2006 15:04"), offer.ExpiresAt.In(loc).Format("15:04"),
+		))
+		return
+	}
+}
+
+// reserveForEntry books a reserved appointment for the entry at start and
+// makes the offer for it.
+func (s *appointmentService) reserveForEntry(ctx context.Context, entry *entity.WaitlistEntry, start time.Time) (*entity.WaitlistOffer, error) {
+	duration, categories, err := s.inspectServices(ctx, entry.LocationID, entry.ServiceIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	appointment := &entity.Appointment{
+		UserID:          entry.UserID,
+		VehicleID:       entry.VehicleID,
+		LocationID:      entry.LocationID,
+		AppointmentTime: start,
+		EndsAt:          start.Add(duration),
+		Status:          entity.AppointmentStatusReserved,
+		Attachments:     []string{},
+	}
+
+	// Answering an offer is booking online, so the contact and no-show rules apply as is
+	user, err := s.userRepo.GetById(ctx, entry.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if s.booking.RequireVerifiedContact && !user.HasVerifiedContact() {
+		return nil, ErrContactNotVerified
+	}
+	if err := s.applyNoShowPolicy(user, entity.Actor{UserID: &entry.UserID}, appointment); err != nil {
+		return nil, err
+	}
+
+	calendar, err := loadBusinessCalendar(ctx, s.calendarRepo, s.locationRepo, appointment.LocationID, appointment.AppointmentTime, appointment.EndsAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load business calendar: %w", err)
+	}
+	if !calendar.Covers(entity.TimeRange{Start: appointment.AppointmentTime, End: appointment.EndsAt}) {
+		return nil, ErrOutsideBusinessHours
+	}
+
+	req, err := s.requirements(ctx, appointment, entry.ServiceIDs, categories)
+	if err != nil {
+		return nil, err
+	}
+
+	reason := "waitlist offer"
+	change := &entity.AppointmentStatusChange{ToStatus: appointment.Status, Reason: &reason}
+	if _, err := s.appointmentRepo.Create(ctx, appointment, entry.ServiceIDs, req, change); err != nil {
+		if errors.Is(err, storages.ErrAppointmentOverlap) {
+			return nil, ErrTimeSlotUnavailable
+		}
+		return nil, err
+	}
+
+	offer := &entity.WaitlistOffer{
+		EntryID:         entry.ID,
+		AppointmentID:   appointment.ID,
+		AppointmentTime: appointment.AppointmentTime,
+		Status:          entity.WaitlistOfferPending,
+		ExpiresAt:       time.Now().Add(s.booking.WaitlistOfferTTL),
+	}
+	if err := s.waitlistRepo.CreateOffer(ctx, offer); err != nil {
+		// The entry was taken meanwhile, give the time back
+		if releaseErr := s.releaseReserved(ctx, appointment, entity.Actor{}, "waitlist entry is no longer waiting"); releaseErr != nil {
+			s.log.Error().Err(releaseErr).Str("appointment_id", appointment.ID.String()).Msg("error releasing reserved appointment")
+		}
+		return nil, err
+	}
+
+	return offer, nil
+}
+
+// releaseReserved cancels an appointment reserved for an offer.
+func (s *appointmentService) releaseReserved(ctx context.Context, appointment *entity.Appointment, actor entity.Actor, reason string) error {
+	if appointment.Status != entity.AppointmentStatusReserved {
+		return nil
+	}
+
+	change, err := statusChange(appointment, entity.AppointmentStatusCancelled, actor, &reason)
+	if err != nil {
+		return err
+	}
+	appointment.Status = entity.AppointmentStatusCancelled
+
+	return s.appointmentRepo.Update(ctx, appointment, change)
+}
+
+// waitlistOffer returns the offer if it was made to the client.
+func (s *appointmentService) waitlistOffer(ctx context.Context, userID, offerID uuid.UUID) (*entity.WaitlistOffer, error) {
+	offer, err := s.waitlistRepo.GetOffer(ctx, offerID)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := s.waitlistRepo.GetById(ctx, offer.EntryID)
+	if err != nil {
+		return nil, err
+	}
+	if entry.UserID != userID {
+		return nil, ErrNotOfferRecipient
+	}
+
+	if offer.Status != entity.WaitlistOfferPending {
+		return nil, ErrWaitlistOfferClosed
+	}
+
+	return offer, nil
+}
+
+// AcceptWaitlistOffer turns the reserved appointment into a scheduled one.
+// The contact could have changed since joining, so it is checked again.
+func (s *appointmentService) AcceptWaitlistOffer(ctx context.Context, userID, offerID uuid.UUID, actor entity.Actor) (*entity.Appointment, error) {
+	offer, err := s.waitlistOffer(ctx, userID, offerID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if s.booking.RequireVerifiedContact && !user.HasVerifiedContact() {
+		return nil, ErrContactNotVerified
+	}
+
+	if !time.Now().Before(offer.ExpiresAt) {
+		if err := s.expireOffer(ctx, offer); err != nil {
+			return nil, err
+		}
+		return nil, ErrWaitlistOfferClosed
+	}
+
+	appointment, err := s.appointmentRepo.GetById(ctx, offer.AppointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get appointment: %w", err)
+	}
+
+	change, err := statusChange(appointment, entity.AppointmentStatusScheduled, actor, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.waitlistRepo.AcceptOffer(ctx, offer, change); err != nil {
+		if errors.Is(err, storages.ErrWaitlistOfferClosed) {
+			return nil, ErrWaitlistOfferClosed
+		}
+		return nil, fmt.Errorf("failed to accept waitlist offer: %w", err)
+	}
+
+	return s.appointmentRepo.GetById(ctx, appointment.ID)
+}
+
+// DeclineWaitlistOffer releases the reserved time and offers it to the next
+// client. The entry stays on the waitlist for other slots.
+func (s *appointmentService) DeclineWaitlistOffer(ctx context.Context, userID, offerID uuid.UUID, actor entity.Actor) error {
+	offer, err := s.waitlistOffer(ctx, userID, offerID)
+	if err != nil {
+		return err
+	}
+
+	return s.closeOffer(ctx, offer, entity.WaitlistOfferDeclined, entity.WaitlistStatusWaiting, actor)
+}
+
+// ExpireWaitlistOffers closes the offers nobody answered in time and passes
+// their slots on. It returns how many offers expired; an offer that fails is
+// logged and retried on the next run.
+func (s *appointmentService) ExpireWaitlistOffers(ctx context.Context) (int, error) {
+	offers, err := s.waitlistRepo.GetExpiredOffers(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, offer := range offers {
+		if err := s.expireOffer(ctx, offer); err != nil {
+			// Answered while we were getting to it
+			if !errors.Is(err, ErrWaitlistOfferClosed) {
+				s.log.Error().Err(err).Str("offer_id", offer.ID.String()).Msg("error expiring waitlist offer")
+			}
+			continue
+		}
+		expired++
+	}
+
+	return expired, nil
+}
+
+// expireOffer takes the client off the waitlist: they did not answer in time.
+func (s *appointmentService) expireOffer(ctx context.Context, offer *entity.WaitlistOffer) error {
+	return s.closeOffer(ctx, offer, entity.WaitlistOfferExpired, entity.WaitlistStatusExpired, entity.Actor{})
+}
+
+func (s *appointmentService) closeOffer(
+	ctx context.Context,
+	offer *entity.WaitlistOffer,
+	status entity.WaitlistOfferStatus,
+	entryStatus entity.WaitlistStatus,
+	actor entity.Actor,
+) error {
+	appointment, err := s.appointmentRepo.GetById(ctx, offer.AppointmentID)
+	if err != nil {
+		return fmt.Errorf("failed to get appointment: %w", err)
+	}
+
+	// The offer is closed and its reservation cancelled together, so a
+	// failure never leaves the slot held by a closed offer
+	var change *entity.AppointmentStatusChange
+	if appointment.Status == entity.AppointmentStatusReserved {
+		reason := fmt.Sprintf("waitlist offer %s", status)
+		if change, err = statusChange(appointment, entity.AppointmentStatusCancelled, actor, &reason); err != nil {
+			return err
+		}
+	}
+
+	if err := s.waitlistRepo.CloseOffer(ctx, offer, status, entryStatus, change); err != nil {
+		if errors.Is(err, storages.ErrWaitlistOfferClosed) {
+			return ErrWaitlistOfferClosed
+		}
+		return err
+	}
+	if change != nil {
+		appointment.Status = entity.AppointmentStatusCancelled
+	}
+
+	s.offerFreedSlot(ctx, appointment, offer.EntryID)
+	return nil
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/internal/storages"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrWorkOrderExists = errors.New("appointment already has a work order")
+	ErrWorkOrderClosed = errors.New("work order is closed and can no longer be changed")
+	ErrWorkOrderNotDue = errors.New("work order can only be opened once work on the appointment has started")
+	ErrLaborLineLinked = errors.New("labor line of a booked service must stay labor")
+)
+
+type WorkOrderService interface {
+	Create(ctx context.Context, appointmentID uuid.UUID) (*entity.WorkOrder, error)
+	GetByAppointmentId(ctx context.Context, appointmentID uuid.UUID) (*entity.WorkOrder, error)
+	AddLine(ctx context.Context, appointmentID uuid.UUID, input *entity.WorkOrderLineInput) (*entity.WorkOrder, error)
+	UpdateLine(ctx context.Context, appointmentID, lineID uuid.UUID, input *entity.WorkOrderLineInput) (*entity.WorkOrder, error)
+	DeleteLine(ctx context.Context, appointmentID, lineID uuid.UUID) (*entity.WorkOrder, error)
+	Close(ctx context.Context, appointmentID uuid.UUID, actor entity.Actor) (*entity.WorkOrder, error)
+}
+
+type workOrderService struct {
+	repo            storages.WorkOrderRepository
+	appointmentRepo storages.AppointmentRepository
+}
+
+func NewWorkOrderService(repo storages.WorkOrderRepository, appointmentRepo storages.AppointmentRepository) WorkOrderService {
+	return &workOrderService{
+		repo:            repo,
+		appointmentRepo: appointmentRepo,
+	}
+}
+
+// Create opens the work order of the appointment once work on it has started:
+// until then the client may still change the services. Its services become
+// labor lines priced as they were at booking; parts, consumables and extra
+// labor are added as the job goes.
+func (s *workOrderService) Create(ctx context.Context, appointmentID uuid.UUID) (*entity.WorkOrder, error) {
+	appointment, err := s.appointmentRepo.GetById(ctx, appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get appointment: %w", err)
+	}
+
+	switch appointment.Status {
+	case entity.AppointmentStatusInProgress, entity.AppointmentStatusAwaitingParts,
+		entity.AppointmentStatusReady, entity.AppointmentStatusCompleted:
+	default:
+		return nil, ErrWorkOrderNotDue
+	}
+
+	order := &entity.WorkOrder{AppointmentID: appointmentID}
+	if _, err := s.repo.Create(ctx, order); err != nil {
+		if errors.Is(err, storages.ErrWorkOrderExists) {
+			return nil, ErrWorkOrderExists
+		}
+		return nil, err
+	}
+
+	return s.GetByAppointmentId(ctx, appointmentID)
+}
+
+// GetByAppointmentId returns the work order with its estimated and actual totals.
+func (s *workOrderService) GetByAppointmentId(ctx context.Context, appointmentID uuid.UUID) (*entity.WorkOrder, error) {
+	order, err := s.repo.GetByAppointmentId(ctx, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	order.CalculateTotals()
+	return order, nil
+}
+
+func (s *workOrderService) AddLine(ctx context.Context, appointmentID uuid.UUID, input *entity.WorkOrderLineInput) (*entity.WorkOrder, error) {
+	if err := input.Validate(); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	order, err := s.openWorkOrder(ctx, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repo.AddLine(ctx, input.ToLine(order.ID)); err != nil {
+		return nil, workOrderError(err)
+	}
+
+	return s.GetByAppointmentId(ctx, appointmentID)
+}
+
+// UpdateLine replaces the line, e.g. to record the actual quantity or give a discount.
+func (s *workOrderService) UpdateLine(ctx context.Context, appointmentID, lineID uuid.UUID, input *entity.WorkOrderLineInput) (*entity.WorkOrder, error) {
+	if err := input.Validate(); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	order, err := s.openWorkOrder(ctx, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	line := input.ToLine(order.ID)
+	line.ID = lineID
+	if err := s.repo.UpdateLine(ctx, line); err != nil {
+		return nil, workOrderError(err)
+	}
+
+	return s.GetByAppointmentId(ctx, appointmentID)
+}
+
+func (s *workOrderService) DeleteLine(ctx context.Context, appointmentID, lineID uuid.UUID) (*entity.WorkOrder, error) {
+	order, err := s.openWorkOrder(ctx, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.DeleteLine(ctx, order.ID, lineID); err != nil {
+		return nil, workOrderError(err)
+	}
+
+	return s.GetByAppointmentId(ctx, appointmentID)
+}
+
+// Close locks the work order. Lines whose actual quantity was never recorded
+// are closed at their estimate.
+func (s *workOrderService) Close(ctx context.Context, appointmentID uuid.UUID, actor entity.Actor) (*entity.WorkOrder, error) {
+	order, err := s.openWorkOrder(ctx, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Close(ctx, order.ID, actor.UserID); err != nil {
+		return nil, workOrderError(err)
+	}
+
+	return s.GetByAppointmentId(ctx, appointmentID)
+}
+
+// openWorkOrder returns the work order of the appointment if it can still be changed.
+func (s *workOrderService) openWorkOrder(ctx context.Context, appointmentID uuid.UUID) (*entity.WorkOrder, error) {
+	order, err := s.repo.GetByAppointmentId(ctx, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != entity.WorkOrderStatusOpen {
+		return nil, ErrWorkOrderClosed
+	}
+	return order, nil
+}
+
+// workOrderError reports a work order closed concurrently as ErrWorkOrderClosed.
+func workOrderError(err error) error {
+	switch {
+	case errors.Is(err, storages.ErrWorkOrderClosed):
+		return ErrWorkOrderClosed
+	case errors.Is(err, storages.ErrLaborLineLinked):
+		return ErrLaborLineLinked
+	}
+	return err
+}
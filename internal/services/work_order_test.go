@@ -0,0 +1,367 @@
+package services
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/internal/storages"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"testing"
+)
+
+// fakeAppointmentRepo serves appointments from memory. Only GetById is used
+// by the work order service.
+type fakeAppointmentRepo struct {
+	storages.AppointmentRepository
+	appointments map[uuid.UUID]*entity.Appointment
+}
+
+func (r *fakeAppointmentRepo) GetById(_ context.Context, id uuid.UUID) (*entity.Appointment, error) {
+	appointment, ok := r.appointments[id]
+	if !ok {
+		return nil, fmt.Errorf("appointment %w", storages.ErrNotFound)
+	}
+	return appointment, nil
+}
+
+// fakeWorkOrderRepo keeps work orders in memory and mirrors the checks of the
+// storage. With closeOnWrite the order is closed right before a change, as if
+// someone closed it concurrently.
+type fakeWorkOrderRepo struct {
+	orders       map[uuid.UUID]*entity.WorkOrder
+	labor        []*entity.WorkOrderLine
+	closeOnWrite bool
+}
+
+func newFakeWorkOrderRepo(labor ...*entity.WorkOrderLine) *fakeWorkOrderRepo {
+	return &fakeWorkOrderRepo{orders: map[uuid.UUID]*entity.WorkOrder{}, labor: labor}
+}
+
+func (r *fakeWorkOrderRepo) Create(_ context.Context, order *entity.WorkOrder) (uuid.UUID, error) {
+	if _, ok := r.orders[order.AppointmentID]; ok {
+		return uuid.Nil, storages.ErrWorkOrderExists
+	}
+
+	order.ID = uuid.New()
+	order.Status = entity.WorkOrderStatusOpen
+	for _, labor := range r.labor {
+		line := *labor
+		line.ID = uuid.New()
+		line.WorkOrderID = order.ID
+		order.Lines = append(order.Lines, &line)
+	}
+	r.orders[order.AppointmentID] = order
+	return order.ID, nil
+}
+
+func (r *fakeWorkOrderRepo) GetByAppointmentId(_ context.Context, appointmentID uuid.UUID) (*entity.WorkOrder, error) {
+	order, ok := r.orders[appointmentID]
+	if !ok {
+		return nil, fmt.Errorf("work order %w", storages.ErrNotFound)
+	}
+	return order, nil
+}
+
+func (r *fakeWorkOrderRepo) openOrder(id uuid.UUID) (*entity.WorkOrder, error) {
+	for _, order := range r.orders {
+		if order.ID != id {
+			continue
+		}
+		if r.closeOnWrite {
+			order.Status = entity.WorkOrderStatusClosed
+		}
+		if order.Status != entity.WorkOrderStatusOpen {
+			return nil, storages.ErrWorkOrderClosed
+		}
+		return order, nil
+	}
+	return nil, fmt.Errorf("work order %w", storages.ErrNotFound)
+}
+
+func (r *fakeWorkOrderRepo) AddLine(_ context.Context, line *entity.WorkOrderLine) (uuid.UUID, error) {
+	order, err := r.openOrder(line.WorkOrderID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	line.ID = uuid.New()
+	order.Lines = append(order.Lines, line)
+	return line.ID, nil
+}
+
+func (r *fakeWorkOrderRepo) UpdateLine(_ context.Context, line *entity.WorkOrderLine) error {
+	order, err := r.openOrder(line.WorkOrderID)
+	if err != nil {
+		return err
+	}
+	for i, existing := range order.Lines {
+		if existing.ID != line.ID {
+			continue
+		}
+		if existing.AppointmentServiceID != nil && line.Kind != entity.WorkOrderLineLabor {
+			return storages.ErrLaborLineLinked
+		}
+		line.AppointmentServiceID = existing.AppointmentServiceID
+		order.Lines[i] = line
+		return nil
+	}
+	return fmt.Errorf("work order line %w", storages.ErrNotFound)
+}
+
+func (r *fakeWorkOrderRepo) DeleteLine(_ context.Context, workOrderID, lineID uuid.UUID) error {
+	order, err := r.openOrder(workOrderID)
+	if err != nil {
+		return err
+	}
+	for i, existing := range order.Lines {
+		if existing.ID == lineID {
+			order.Lines = append(order.Lines[:i], order.Lines[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("work order line %w", storages.ErrNotFound)
+}
+
+func (r *fakeWorkOrderRepo) Close(_ context.Context, id uuid.UUID, closedBy *uuid.UUID) error {
+	order, err := r.openOrder(id)
+	if err != nil {
+		return err
+	}
+	for _, line := range order.Lines {
+		if line.ActualQuantity == nil {
+			quantity := line.Quantity
+			line.ActualQuantity = &quantity
+		}
+	}
+	order.Status = entity.WorkOrderStatusClosed
+	order.ClosedBy = closedBy
+	return nil
+}
+
+// newWorkOrderTest returns a service over an appointment in the given status
+// with one booked service priced at 4500.
+func newWorkOrderTest(status entity.AppointmentStatus) (*workOrderService, *fakeWorkOrderRepo, uuid.UUID) {
+	appointmentID := uuid.New()
+	appointmentServiceID := uuid.New()
+	appointments := &fakeAppointmentRepo{appointments: map[uuid.UUID]*entity.Appointment{
+		appointmentID: {ID: appointmentID, Status: status},
+	}}
+	repo := newFakeWorkOrderRepo(&entity.WorkOrderLine{
+		Kind:                 entity.WorkOrderLineLabor,
+		AppointmentServiceID: &appointmentServiceID,
+		Description:          "Замена масла",
+		Quantity:             1,
+		UnitPrice:            4500,
+	})
+
+	return &workOrderService{repo: repo, appointmentRepo: appointments}, repo, appointmentID
+}
+
+func TestWorkOrderCreate(t *testing.T) {
+	tests := []struct {
+		status  entity.AppointmentStatus
+		wantErr error
+	}{
+		{entity.AppointmentStatusReserved, ErrWorkOrderNotDue},
+		{entity.AppointmentStatusScheduled, ErrWorkOrderNotDue},
+		{entity.AppointmentStatusCheckedIn, ErrWorkOrderNotDue},
+		{entity.AppointmentStatusInProgress, nil},
+		{entity.AppointmentStatusAwaitingParts, nil},
+		{entity.AppointmentStatusReady, nil},
+		{entity.AppointmentStatusCompleted, nil},
+		{entity.AppointmentStatusCancelled, ErrWorkOrderNotDue},
+		{entity.AppointmentStatusNoShow, ErrWorkOrderNotDue},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			s, _, appointmentID := newWorkOrderTest(tt.status)
+
+			order, err := s.Create(context.Background(), appointmentID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Create() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(order.Lines) != 1 || order.EstimatedTotal != 4500 || order.ActualTotal != 4500 {
+				t.Errorf("Create() = %d lines, totals %v, %v, want 1 line at 4500", len(order.Lines), order.EstimatedTotal, order.ActualTotal)
+			}
+		})
+	}
+}
+
+func TestWorkOrderCreateTwice(t *testing.T) {
+	s, _, appointmentID := newWorkOrderTest(entity.AppointmentStatusInProgress)
+
+	if _, err := s.Create(context.Background(), appointmentID); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := s.Create(context.Background(), appointmentID); !errors.Is(err, ErrWorkOrderExists) {
+		t.Errorf("second Create() error = %v, want %v", err, ErrWorkOrderExists)
+	}
+}
+
+func TestWorkOrderCreateMissingAppointment(t *testing.T) {
+	s, _, _ := newWorkOrderTest(entity.AppointmentStatusInProgress)
+
+	if _, err := s.Create(context.Background(), uuid.New()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Create() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestWorkOrderAddLine(t *testing.T) {
+	s, _, appointmentID := newWorkOrderTest(entity.AppointmentStatusInProgress)
+	ctx := context.Background()
+
+	if _, err := s.AddLine(ctx, appointmentID, &entity.WorkOrderLineInput{
+		Kind: entity.WorkOrderLinePart, Description: "Фильтр", Quantity: 1, UnitPrice: 900,
+	}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("AddLine() without a work order error = %v, want %v", err, ErrNotFound)
+	}
+
+	if _, err := s.Create(ctx, appointmentID); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if _, err := s.AddLine(ctx, appointmentID, &entity.WorkOrderLineInput{
+		Kind: entity.WorkOrderLinePart, Quantity: 1, UnitPrice: 900,
+	}); err == nil {
+		t.Error("AddLine() without a description error = nil, want validation error")
+	}
+
+	actual := 3.0
+	order, err := s.AddLine(ctx, appointmentID, &entity.WorkOrderLineInput{
+		Kind:            entity.WorkOrderLinePart,
+		Description:     "Фильтр",
+		Quantity:        2,
+		ActualQuantity:  &actual,
+		UnitPrice:       900,
+		DiscountPercent: 10,
+	})
+	if err != nil {
+		t.Fatalf("AddLine() error = %v", err)
+	}
+	if len(order.Lines) != 2 {
+		t.Fatalf("AddLine() = %d lines, want 2", len(order.Lines))
+	}
+	if order.EstimatedTotal != 6120 || order.ActualTotal != 6930 {
+		t.Errorf("totals = %v, %v, want 6120, 6930", order.EstimatedTotal, order.ActualTotal)
+	}
+}
+
+func TestWorkOrderUpdateLaborLine(t *testing.T) {
+	s, _, appointmentID := newWorkOrderTest(entity.AppointmentStatusInProgress)
+	ctx := context.Background()
+
+	order, err := s.Create(ctx, appointmentID)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	laborID := order.Lines[0].ID
+
+	if _, err := s.UpdateLine(ctx, appointmentID, laborID, &entity.WorkOrderLineInput{
+		Kind: entity.WorkOrderLinePart, Description: "Масло", Quantity: 1, UnitPrice: 4500,
+	}); !errors.Is(err, ErrLaborLineLinked) {
+		t.Errorf("UpdateLine() to a part error = %v, want %v", err, ErrLaborLineLinked)
+	}
+
+	order, err = s.UpdateLine(ctx, appointmentID, laborID, &entity.WorkOrderLineInput{
+		Kind: entity.WorkOrderLineLabor, Description: "Замена масла", Quantity: 1, UnitPrice: 4500, DiscountPercent: 20,
+	})
+	if err != nil {
+		t.Fatalf("UpdateLine() error = %v", err)
+	}
+	if order.Lines[0].AppointmentServiceID == nil || order.EstimatedTotal != 3600 {
+		t.Errorf("UpdateLine() = linked %v, total %v, want linked at 3600", order.Lines[0].AppointmentServiceID != nil, order.EstimatedTotal)
+	}
+
+	if _, err := s.UpdateLine(ctx, appointmentID, uuid.New(), &entity.WorkOrderLineInput{
+		Kind: entity.WorkOrderLineLabor, Description: "Диагностика", Quantity: 1, UnitPrice: 1000,
+	}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateLine() of a missing line error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestWorkOrderClose(t *testing.T) {
+	s, _, appointmentID := newWorkOrderTest(entity.AppointmentStatusReady)
+	ctx := context.Background()
+
+	if _, err := s.Create(ctx, appointmentID); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	staffID := uuid.New()
+	order, err := s.Close(ctx, appointmentID, entity.Actor{UserID: &staffID})
+	if err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if order.Status != entity.WorkOrderStatusClosed || order.ClosedBy != &staffID {
+		t.Errorf("Close() = status %s, closed by %v, want closed by %v", order.Status, order.ClosedBy, staffID)
+	}
+	if actual := order.Lines[0].ActualQuantity; actual == nil || *actual != 1 {
+		t.Errorf("Close() left actual quantity %v, want the estimate", actual)
+	}
+	if order.ActualTotal != 4500 {
+		t.Errorf("ActualTotal = %v, want 4500", order.ActualTotal)
+	}
+}
+
+func TestWorkOrderClosedGuard(t *testing.T) {
+	input := &entity.WorkOrderLineInput{Kind: entity.WorkOrderLinePart, Description: "Фильтр", Quantity: 1, UnitPrice: 900}
+
+	changes := []struct {
+		name   string
+		change func(s *workOrderService, appointmentID, lineID uuid.UUID) error
+	}{
+		{"add line", func(s *workOrderService, appointmentID, _ uuid.UUID) error {
+			_, err := s.AddLine(context.Background(), appointmentID, input)
+			return err
+		}},
+		{"update line", func(s *workOrderService, appointmentID, lineID uuid.UUID) error {
+			_, err := s.UpdateLine(context.Background(), appointmentID, lineID, &entity.WorkOrderLineInput{
+				Kind: entity.WorkOrderLineLabor, Description: "Замена масла", Quantity: 2, UnitPrice: 4500,
+			})
+			return err
+		}},
+		{"delete line", func(s *workOrderService, appointmentID, lineID uuid.UUID) error {
+			_, err := s.DeleteLine(context.Background(), appointmentID, lineID)
+			return err
+		}},
+		{"close", func(s *workOrderService, appointmentID, _ uuid.UUID) error {
+			_, err := s.Close(context.Background(), appointmentID, entity.Actor{})
+			return err
+		}},
+	}
+
+	for _, tt := range changes {
+		t.Run(tt.name+" after close", func(t *testing.T) {
+			s, _, appointmentID := newWorkOrderTest(entity.AppointmentStatusInProgress)
+			order, err := s.Create(context.Background(), appointmentID)
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			lineID := order.Lines[0].ID
+			if _, err := s.Close(context.Background(), appointmentID, entity.Actor{}); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			if err := tt.change(s, appointmentID, lineID); !errors.Is(err, ErrWorkOrderClosed) {
+				t.Errorf("error = %v, want %v", err, ErrWorkOrderClosed)
+			}
+		})
+
+		t.Run(tt.name+" closed concurrently", func(t *testing.T) {
+			s, repo, appointmentID := newWorkOrderTest(entity.AppointmentStatusInProgress)
+			order, err := s.Create(context.Background(), appointmentID)
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			repo.closeOnWrite = true
+
+			if err := tt.change(s, appointmentID, order.Lines[0].ID); !errors.Is(err, ErrWorkOrderClosed) {
+				t.Errorf("error = %v, want %v", err, ErrWorkOrderClosed)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package storages
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/pkg/database"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *entity.APIKey, keyHash string) (uuid.UUID, error)
+	GetActiveByHash(ctx context.Context, keyHash string) (*entity.APIKey, error)
+	GetAll(ctx context.Context) ([]*entity.APIKey, error)
+	TouchLastUsed(ctx context.Context, id uuid.UUID) error
+	Revoke(ctx context.Context, id uuid.UUID) error
+}
+
+type apiKeyStorage struct {
+	pg *database.PostgresDB
+}
+
+func NewAPIKeyStorage(deps StorageDeps) APIKeyRepository {
+	return &apiKeyStorage{
+		pg: deps.PostgresDB,
+	}
+}
+
+func (s *apiKeyStorage) Create(ctx context.Context, key *entity.APIKey, keyHash string) (uuid.UUID, error) {
+	if key.ID == uuid.Nil {
+		key.ID = uuid.New()
+	}
+
+	const query = `
+		INSERT INTO api_keys (id, name, prefix, key_hash, permissions, created_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at;
+	`
+
+	row := s.pg.DB.QueryRowContext(ctx, query,
+		key.ID, key.Name, key.Prefix, keyHash, pq.Array(permissionsToStrings(key.Permissions)), key.CreatedBy, key.ExpiresAt,
+	)
+
+	if err := row.Scan(&key.ID, &key.CreatedAt); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to insert api key: %w", err)
+	}
+
+	return key.ID, nil
+}
+
+func (s *apiKeyStorage) GetActiveByHash(ctx context.Context, keyHash string) (*entity.APIKey, error) {
+	const query = `
+		SELECT id, name, prefix, permissions, created_by, created_at, expires_at, last_used_at, revoked_at
+		FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW());
+	`
+
+	key, err := scanAPIKey(s.pg.DB.QueryRowContext(ctx, query, keyHash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("api key not found")
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	return key, nil
+}
+
+func (s *apiKeyStorage) GetAll(ctx context.Context) ([]*entity.APIKey, error) {
+	const query = `
+		SELECT id, name, prefix, permissions, created_by, created_at, expires_at, last_used_at, revoked_at
+		FROM api_keys
+		ORDER BY created_at DESC;
+	`
+
+	rows, err := s.pg.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*entity.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (s *apiKeyStorage) TouchLastUsed(ctx context.Context, id uuid.UUID) error {
+	const query = `UPDATE api_keys SET last_used_at = NOW() WHERE id = $1;`
+
+	if _, err := s.pg.DB.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to touch api key: %w", err)
+	}
+
+	return nil
+}
+
+func (s *apiKeyStorage) Revoke(ctx context.Context, id uuid.UUID) error {
+	const query = `
+		UPDATE api_keys
+		SET revoked_at = NOW()
+		WHERE id = $1 AND revoked_at IS NULL;
+	`
+
+	result, err := s.pg.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("api key %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// scanAPIKey читает строку api_keys в порядке колонок из запросов выше.
+func scanAPIKey(row interface {
+	Scan(dest ...interface{}) error
+}) (*entity.APIKey, error) {
+	var key entity.APIKey
+	var permissions []string
+	if err := row.Scan(
+		&key.ID, &key.Name, &key.Prefix, pq.Array(&permissions), &key.CreatedBy,
+		&key.CreatedAt, &key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	key.Permissions = make([]entity.Permission, 0, len(permissions))
+	for _, p := range permissions {
+		key.Permissions = append(key.Permissions, entity.Permission(p))
+	}
+
+	return &key, nil
+}
+
+func permissionsToStrings(permissions []entity.Permission) []string {
+	result := make([]string, 0, len(permissions))
+	for _, p := range permissions {
+		result = append(result, string(p))
+	}
+	return result
+}
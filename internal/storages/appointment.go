@@ -4,20 +4,53 @@ import (
 	"backend-service/internal/entity"
 	"backend-service/pkg/database"
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/lib/pq"
+	"time"
 )
 
+// ErrAppointmentOverlap is returned when no unit of a required resource type
+// is free for the appointment time, either found while allocating or reported
+// by the appointment_resources_no_overlap constraint, or when an assigned
+// mechanic is busy according to appointment_mechanics_no_overlap.
+var ErrAppointmentOverlap = errors.New("no resource is free for the appointment time")
+
+// ErrAppointmentStatusChanged is returned when a status change finds the
+// appointment no longer in the status the change was made from.
+var ErrAppointmentStatusChanged = errors.New("appointment status was changed concurrently")
+
+// exclusionViolation is the PostgreSQL error code of a violated EXCLUDE constraint.
+const exclusionViolation = "23P01"
+
+// wrapOverlapError turns an exclusion constraint violation into ErrAppointmentOverlap.
+func wrapOverlapError(err error, msg string) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == exclusionViolation {
+		return ErrAppointmentOverlap
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
 type AppointmentRepository interface {
-	Create(ctx context.Context, appointment *entity.Appointment, serviceIDs []uuid.UUID) (uuid.UUID, error)
+	Create(ctx context.Context, appointment *entity.Appointment, serviceIDs []uuid.UUID, req *entity.AppointmentRequirements, change *entity.AppointmentStatusChange) (uuid.UUID, error)
 	GetById(ctx context.Context, id uuid.UUID) (*entity.Appointment, error)
 	GetByUserId(ctx context.Context, userId uuid.UUID) ([]*entity.Appointment, error)
-	Update(ctx context.Context, appointment *entity.Appointment) error
-	UpdateServices(ctx context.Context, appointmentID uuid.UUID, serviceIDs []uuid.UUID) error
+	GetByMechanicId(ctx context.Context, mechanicID uuid.UUID, from, to time.Time) ([]*entity.Appointment, error)
+	GetOverdue(ctx context.Context, before time.Time) ([]*entity.Appointment, error)
+	Update(ctx context.Context, appointment *entity.Appointment, change *entity.AppointmentStatusChange) error
+	MarkPrepaid(ctx context.Context, id uuid.UUID) error
+	UpdateServices(ctx context.Context, appointment *entity.Appointment, change *entity.AppointmentStatusChange, serviceIDs []uuid.UUID, req *entity.AppointmentRequirements) error
+	SetMechanics(ctx context.Context, appointment *entity.Appointment, mechanicIDs []uuid.UUID) error
+	Reschedule(ctx context.Context, appointment *entity.Appointment, req *entity.AppointmentRequirements, change *entity.AppointmentTimeChange) error
+	GetTimeHistory(ctx context.Context, appointmentID uuid.UUID) ([]*entity.AppointmentTimeChange, error)
 	Delete(ctx context.Context, id uuid.UUID) error
-	CheckTimeSlotAvailable(ctx context.Context, appointmentTime string) (bool, error)
+	GetStatusHistory(ctx context.Context, appointmentID uuid.UUID) ([]*entity.AppointmentStatusChange, error)
+	GetAllocations(ctx context.Context, resourceIDs []uuid.UUID, from, to time.Time) ([]entity.ResourceAllocation, error)
+	GetMechanicAllocations(ctx context.Context, mechanicIDs []uuid.UUID, from, to time.Time) ([]entity.MechanicAllocation, error)
 }
 
 type appointmentStorage struct {
@@ -30,7 +63,10 @@ func NewAppointmentStorage(deps StorageDeps) AppointmentRepository {
 	}
 }
 
-func (s *appointmentStorage) Create(ctx context.Context, appointment *entity.Appointment, serviceIDs []uuid.UUID) (uuid.UUID, error) {
+// Create inserts the appointment with its services, assigns it one resource
+// of every required type and the required mechanics, and starts its status
+// history with change.
+func (s *appointmentStorage) Create(ctx context.Context, appointment *entity.Appointment, serviceIDs []uuid.UUID, req *entity.AppointmentRequirements, change *entity.AppointmentStatusChange) (uuid.UUID, error) {
 	tx, err := s.pg.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
@@ -43,14 +79,15 @@ func (s *appointmentStorage) Create(ctx context.Context, appointment *entity.App
 
 	// Insert appointment
 	const appointmentQuery = `
-		INSERT INTO appointments (id, user_id, vehicle_id, appointment_time, status, attachments)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO appointments (id, user_id, location_id, vehicle_id, appointment_time, ends_at, status, attachments, prepayment_required)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		RETURNING id;
 	`
 
 	row := tx.QueryRowContext(ctx, appointmentQuery,
-		appointment.ID, appointment.UserID, appointment.VehicleID,
-		appointment.AppointmentTime, appointment.Status, pq.Array(appointment.Attachments),
+		appointment.ID, appointment.UserID, appointment.LocationID, appointment.VehicleID,
+		appointment.AppointmentTime, appointment.EndsAt, appointment.Status, pq.Array(appointment.Attachments),
+		appointment.PrepaymentRequired,
 	)
 
 	if err := row.Scan(&appointment.ID); err != nil {
@@ -69,6 +106,19 @@ func (s *appointmentStorage) Create(ctx context.Context, appointment *entity.App
 		return uuid.Nil, fmt.Errorf("failed to insert appointment services: %w", err)
 	}
 
+	if err := allocateResources(ctx, tx, appointment, req.ResourceTypes); err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := assignMechanics(ctx, tx, appointment, req.MechanicIDs); err != nil {
+		return uuid.Nil, err
+	}
+
+	change.AppointmentID = appointment.ID
+	if err := recordStatusChange(ctx, tx, change); err != nil {
+		return uuid.Nil, err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -79,19 +129,23 @@ func (s *appointmentStorage) Create(ctx context.Context, appointment *entity.App
 func (s *appointmentStorage) GetById(ctx context.Context, id uuid.UUID) (*entity.Appointment, error) {
 	const query = `
 		SELECT 
-			a.id, a.user_id, a.vehicle_id, a.appointment_time, a.status, a.attachments,
+			a.id, a.user_id, a.location_id, a.vehicle_id, a.appointment_time, a.ends_at, a.status, a.attachments,
+			a.prepayment_required, a.prepaid_at, a.cancellation_terms, a.cancellation_fee,
 			COALESCE(json_agg(json_build_object(
 				'id', s.id,
 				'name', s.name,
 				'description', s.description,
 				'price', s.price,
-				'duration_min', s.duration_min
-			)) FILTER (WHERE s.id IS NOT NULL), '[]') as services
+				'duration_min', s.duration_min,
+				'category', s.category
+			)) FILTER (WHERE s.id IS NOT NULL), '[]') as services,
+			COALESCE(SUM(as_link.price), 0) as services_total
 		FROM appointments a
 		LEFT JOIN appointment_services as_link ON a.id = as_link.appointment_id
 		LEFT JOIN services s ON as_link.service_id = s.id AND s.deleted_at IS NULL
 		WHERE a.id = $1 AND a.deleted_at IS NULL
-		GROUP BY a.id, a.user_id, a.vehicle_id, a.appointment_time, a.status, a.attachments;
+		GROUP BY a.id, a.user_id, a.location_id, a.vehicle_id, a.appointment_time, a.ends_at, a.status, a.attachments,
+			a.prepayment_required, a.prepaid_at, a.cancellation_terms, a.cancellation_fee;
 	`
 
 	row := s.pg.DB.QueryRowContext(ctx, query, id)
@@ -99,34 +153,82 @@ func (s *appointmentStorage) GetById(ctx context.Context, id uuid.UUID) (*entity
 	var appointment entity.Appointment
 	var servicesJSON []byte
 	if err := row.Scan(
-		&appointment.ID, &appointment.UserID, &appointment.VehicleID,
-		&appointment.AppointmentTime, &appointment.Status, pq.Array(&appointment.Attachments), &servicesJSON,
+		&appointment.ID, &appointment.UserID, &appointment.LocationID, &appointment.VehicleID,
+		&appointment.AppointmentTime, &appointment.EndsAt, &appointment.Status, pq.Array(&appointment.Attachments),
+		&appointment.PrepaymentRequired, &appointment.PrepaidAt, &appointment.CancellationTerms, &appointment.CancellationFee, &servicesJSON,
+		&appointment.ServicesTotal,
 	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("appointment %w", ErrNotFound)
+		}
 		return nil, fmt.Errorf("failed to get appointment: %w", err)
 	}
 	var services []*entity.Service
 	if err := json.Unmarshal(servicesJSON, &services); err == nil {
 		appointment.Services = services
 	}
+
+	resources, err := s.getResources(ctx, appointment.ID)
+	if err != nil {
+		return nil, err
+	}
+	appointment.Resources = resources
+
+	mechanics, err := getMechanics(ctx, s.pg.DB, appointment.ID)
+	if err != nil {
+		return nil, err
+	}
+	appointment.Mechanics = mechanics
+
 	return &appointment, nil
 }
 
+func (s *appointmentStorage) getResources(ctx context.Context, appointmentID uuid.UUID) ([]*entity.AppointmentResource, error) {
+	const query = `
+		SELECT r.id, r.name, r.type, ar.unit
+		FROM appointment_resources ar
+		JOIN resources r ON r.id = ar.resource_id
+		WHERE ar.appointment_id = $1 AND ar.active
+		ORDER BY r.type, r.name;
+	`
+
+	rows, err := s.pg.DB.QueryContext(ctx, query, appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query appointment resources: %w", err)
+	}
+	defer rows.Close()
+
+	var resources []*entity.AppointmentResource
+	for rows.Next() {
+		var resource entity.AppointmentResource
+		if err := rows.Scan(&resource.ResourceID, &resource.Name, &resource.Type, &resource.Unit); err != nil {
+			return nil, fmt.Errorf("failed to scan appointment resource: %w", err)
+		}
+		resources = append(resources, &resource)
+	}
+
+	return resources, nil
+}
+
 func (s *appointmentStorage) GetByUserId(ctx context.Context, userId uuid.UUID) ([]*entity.Appointment, error) {
 	const query = `
 		SELECT 
-			a.id, a.user_id, a.vehicle_id, a.appointment_time, a.status, a.attachments,
+			a.id, a.user_id, a.location_id, a.vehicle_id, a.appointment_time, a.ends_at, a.status, a.attachments,
+			a.prepayment_required, a.prepaid_at, a.cancellation_terms, a.cancellation_fee,
 			COALESCE(json_agg(json_build_object(
 				'id', s.id,
 				'name', s.name,
 				'description', s.description,
 				'price', s.price,
-				'duration_min', s.duration_min
+				'duration_min', s.duration_min,
+				'category', s.category
 			)) FILTER (WHERE s.id IS NOT NULL), '[]') as services
 		FROM appointments a
 		LEFT JOIN appointment_services as_link ON a.id = as_link.appointment_id
 		LEFT JOIN services s ON as_link.service_id = s.id AND s.deleted_at IS NULL
 		WHERE a.user_id = $1 AND a.deleted_at IS NULL
-		GROUP BY a.id, a.user_id, a.vehicle_id, a.appointment_time, a.status, a.attachments
+		GROUP BY a.id, a.user_id, a.location_id, a.vehicle_id, a.appointment_time, a.ends_at, a.status, a.attachments,
+			a.prepayment_required, a.prepaid_at, a.cancellation_terms, a.cancellation_fee
 		ORDER BY a.appointment_time DESC;
 	`
 
@@ -141,8 +243,60 @@ func (s *appointmentStorage) GetByUserId(ctx context.Context, userId uuid.UUID)
 		var appointment entity.Appointment
 		var servicesJSON []byte
 		if err := rows.Scan(
-			&appointment.ID, &appointment.UserID, &appointment.VehicleID,
-			&appointment.AppointmentTime, &appointment.Status, pq.Array(&appointment.Attachments), &servicesJSON,
+			&appointment.ID, &appointment.UserID, &appointment.LocationID, &appointment.VehicleID,
+			&appointment.AppointmentTime, &appointment.EndsAt, &appointment.Status, pq.Array(&appointment.Attachments),
+			&appointment.PrepaymentRequired, &appointment.PrepaidAt, &appointment.CancellationTerms, &appointment.CancellationFee, &servicesJSON,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan appointment: %w", err)
+		}
+		var services []*entity.Service
+		if err := json.Unmarshal(servicesJSON, &services); err == nil {
+			appointment.Services = services
+		}
+		appointments = append(appointments, &appointment)
+	}
+
+	return appointments, nil
+}
+
+// GetByMechanicId returns active appointments assigned to the mechanic that start in [from, to).
+func (s *appointmentStorage) GetByMechanicId(ctx context.Context, mechanicID uuid.UUID, from, to time.Time) ([]*entity.Appointment, error) {
+	const query = `
+		SELECT 
+			a.id, a.user_id, a.location_id, a.vehicle_id, a.appointment_time, a.ends_at, a.status, a.attachments,
+			a.prepayment_required, a.prepaid_at, a.cancellation_terms, a.cancellation_fee,
+			COALESCE(json_agg(json_build_object(
+				'id', s.id,
+				'name', s.name,
+				'description', s.description,
+				'price', s.price,
+				'duration_min', s.duration_min,
+				'category', s.category
+			)) FILTER (WHERE s.id IS NOT NULL), '[]') as services
+		FROM appointments a
+		JOIN appointment_mechanics am ON am.appointment_id = a.id AND am.user_id = $1 AND am.active
+		LEFT JOIN appointment_services as_link ON a.id = as_link.appointment_id
+		LEFT JOIN services s ON as_link.service_id = s.id AND s.deleted_at IS NULL
+		WHERE a.appointment_time >= $2 AND a.appointment_time < $3 AND a.deleted_at IS NULL
+		GROUP BY a.id, a.user_id, a.location_id, a.vehicle_id, a.appointment_time, a.ends_at, a.status, a.attachments,
+			a.prepayment_required, a.prepaid_at, a.cancellation_terms, a.cancellation_fee
+		ORDER BY a.appointment_time;
+	`
+
+	rows, err := s.pg.DB.QueryContext(ctx, query, mechanicID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query appointments: %w", err)
+	}
+	defer rows.Close()
+
+	appointments := []*entity.Appointment{}
+	for rows.Next() {
+		var appointment entity.Appointment
+		var servicesJSON []byte
+		if err := rows.Scan(
+			&appointment.ID, &appointment.UserID, &appointment.LocationID, &appointment.VehicleID,
+			&appointment.AppointmentTime, &appointment.EndsAt, &appointment.Status, pq.Array(&appointment.Attachments),
+			&appointment.PrepaymentRequired, &appointment.PrepaidAt, &appointment.CancellationTerms, &appointment.CancellationFee, &servicesJSON,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan appointment: %w", err)
 		}
@@ -156,15 +310,80 @@ func (s *appointmentStorage) GetByUserId(ctx context.Context, userId uuid.UUID)
 	return appointments, nil
 }
 
-func (s *appointmentStorage) Update(ctx context.Context, appointment *entity.Appointment) error {
+// GetOverdue returns scheduled appointments that start before the given time,
+// that is the ones the client has not checked in for.
+func (s *appointmentStorage) GetOverdue(ctx context.Context, before time.Time) ([]*entity.Appointment, error) {
+	const query = `
+		SELECT id, user_id, location_id, vehicle_id, appointment_time, ends_at, status, attachments,
+			prepayment_required, prepaid_at, cancellation_terms, cancellation_fee
+		FROM appointments
+		WHERE status = 'scheduled' AND appointment_time < $1 AND deleted_at IS NULL
+		ORDER BY appointment_time;
+	`
+
+	rows, err := s.pg.DB.QueryContext(ctx, query, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query overdue appointments: %w", err)
+	}
+	defer rows.Close()
+
+	var appointments []*entity.Appointment
+	for rows.Next() {
+		var appointment entity.Appointment
+		if err := rows.Scan(
+			&appointment.ID, &appointment.UserID, &appointment.LocationID, &appointment.VehicleID,
+			&appointment.AppointmentTime, &appointment.EndsAt, &appointment.Status, pq.Array(&appointment.Attachments),
+			&appointment.PrepaymentRequired, &appointment.PrepaidAt, &appointment.CancellationTerms, &appointment.CancellationFee,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan appointment: %w", err)
+		}
+		appointments = append(appointments, &appointment)
+	}
+
+	return appointments, nil
+}
+
+// Update saves the appointment and moves its resource and mechanic
+// allocations along with it. A status that releases the slot frees them.
+// A non-nil change applies only while the appointment is still in its
+// FromStatus, otherwise ErrAppointmentStatusChanged is returned. The change is
+// appended to the status history in the same transaction, and a change to
+// no_show counts against the client.
+func (s *appointmentStorage) Update(ctx context.Context, appointment *entity.Appointment, change *entity.AppointmentStatusChange) error {
+	tx, err := s.pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := updateAppointment(ctx, tx, appointment, change); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// updateAppointment saves the appointment within tx as described for Update.
+func updateAppointment(ctx context.Context, tx *sql.Tx, appointment *entity.Appointment, change *entity.AppointmentStatusChange) error {
+	var fromStatus *entity.AppointmentStatus
+	if change != nil {
+		fromStatus = change.FromStatus
+	}
+
 	const query = `
 		UPDATE appointments
-		SET appointment_time = $2, status = $3, attachments = $4, updated_at = NOW()
-		WHERE id = $1 AND deleted_at IS NULL;
+		SET appointment_time = $2, ends_at = $3, status = $4, attachments = $5,
+			cancellation_terms = $6, cancellation_fee = $7, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL AND ($8::text IS NULL OR status = $8);
 	`
 
-	result, err := s.pg.DB.ExecContext(ctx, query,
-		appointment.ID, appointment.AppointmentTime, appointment.Status, pq.Array(appointment.Attachments),
+	result, err := tx.ExecContext(ctx, query,
+		appointment.ID, appointment.AppointmentTime, appointment.EndsAt, appointment.Status, pq.Array(appointment.Attachments),
+		appointment.CancellationTerms, appointment.CancellationFee, fromStatus,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to update appointment: %w", err)
@@ -176,26 +395,154 @@ func (s *appointmentStorage) Update(ctx context.Context, appointment *entity.App
 	}
 
 	if rows == 0 {
+		if fromStatus != nil {
+			return ErrAppointmentStatusChanged
+		}
 		return fmt.Errorf("appointment not found")
 	}
 
+	const allocationsQuery = `
+		UPDATE appointment_resources
+		SET starts_at = $2, ends_at = $3, active = $4
+		WHERE appointment_id = $1;
+	`
+
+	active := !appointment.Status.ReleasesSlot()
+	if _, err := tx.ExecContext(ctx, allocationsQuery,
+		appointment.ID, appointment.AppointmentTime, appointment.EndsAt, active,
+	); err != nil {
+		return wrapOverlapError(err, "failed to update appointment resources")
+	}
+
+	const mechanicsQuery = `
+		UPDATE appointment_mechanics
+		SET starts_at = $2, ends_at = $3, active = $4
+		WHERE appointment_id = $1;
+	`
+
+	if _, err := tx.ExecContext(ctx, mechanicsQuery,
+		appointment.ID, appointment.AppointmentTime, appointment.EndsAt, active,
+	); err != nil {
+		return wrapOverlapError(err, "failed to update appointment mechanics")
+	}
+
+	if change != nil {
+		if err := recordStatusChange(ctx, tx, change); err != nil {
+			return err
+		}
+
+		if change.ToStatus == entity.AppointmentStatusNoShow {
+			const noShowQuery = `
+				UPDATE users
+				SET no_show_count = no_show_count + 1, updated_at = NOW()
+				WHERE id = $1;
+			`
+
+			if _, err := tx.ExecContext(ctx, noShowQuery, appointment.UserID); err != nil {
+				return fmt.Errorf("failed to count no-show: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// MarkPrepaid records that the prepayment required for the appointment was received.
+func (s *appointmentStorage) MarkPrepaid(ctx context.Context, id uuid.UUID) error {
+	const query = `
+		UPDATE appointments
+		SET prepaid_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND prepayment_required AND prepaid_at IS NULL AND deleted_at IS NULL;
+	`
+
+	result, err := s.pg.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark appointment prepaid: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("appointment not found or does not await prepayment")
+	}
+
 	return nil
 }
 
-func (s *appointmentStorage) UpdateServices(ctx context.Context, appointmentID uuid.UUID, serviceIDs []uuid.UUID) error {
+// UpdateServices saves the appointment as Update does, with appointment.EndsAt
+// for the total duration of the new services, replaces the services and
+// assigns resources and mechanics from scratch, all in one transaction.
+// Services are only replaced while the appointment is scheduled or reserved,
+// otherwise ErrAppointmentStatusChanged is returned, and never once it has a
+// work order, which is priced from them: then ErrWorkOrderExists is.
+func (s *appointmentStorage) UpdateServices(
+	ctx context.Context,
+	appointment *entity.Appointment,
+	change *entity.AppointmentStatusChange,
+	serviceIDs []uuid.UUID,
+	req *entity.AppointmentRequirements,
+) error {
 	tx, err := s.pg.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	const lockQuery = `
+		SELECT a.status, EXISTS (SELECT 1 FROM work_orders w WHERE w.appointment_id = a.id)
+		FROM appointments a
+		WHERE a.id = $1 AND a.deleted_at IS NULL
+		FOR UPDATE;
+	`
+
+	var status entity.AppointmentStatus
+	var hasWorkOrder bool
+	if err := tx.QueryRowContext(ctx, lockQuery, appointment.ID).Scan(&status, &hasWorkOrder); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("appointment %w", ErrNotFound)
+		}
+		return fmt.Errorf("failed to lock appointment: %w", err)
+	}
+	if hasWorkOrder {
+		return ErrWorkOrderExists
+	}
+	if status != entity.AppointmentStatusScheduled && status != entity.AppointmentStatusReserved {
+		return ErrAppointmentStatusChanged
+	}
+
+	// The old allocations go first, so the new end never conflicts with them
+	const releaseQuery = `
+		DELETE FROM appointment_resources
+		WHERE appointment_id = $1;
+	`
+
+	if _, err := tx.ExecContext(ctx, releaseQuery, appointment.ID); err != nil {
+		return fmt.Errorf("failed to release appointment resources: %w", err)
+	}
+
+	const releaseMechanicsQuery = `
+		DELETE FROM appointment_mechanics
+		WHERE appointment_id = $1;
+	`
+
+	if _, err := tx.ExecContext(ctx, releaseMechanicsQuery, appointment.ID); err != nil {
+		return fmt.Errorf("failed to release appointment mechanics: %w", err)
+	}
+
+	if err := updateAppointment(ctx, tx, appointment, change); err != nil {
+		return err
+	}
+
 	// Delete existing services
 	const deleteQuery = `
 		DELETE FROM appointment_services
 		WHERE appointment_id = $1;
 	`
 
-	if _, err := tx.ExecContext(ctx, deleteQuery, appointmentID); err != nil {
+	if _, err := tx.ExecContext(ctx, deleteQuery, appointment.ID); err != nil {
 		return fmt.Errorf("failed to delete existing services: %w", err)
 	}
 
@@ -207,10 +554,93 @@ func (s *appointmentStorage) UpdateServices(ctx context.Context, appointmentID u
 		WHERE id = ANY($2) AND deleted_at IS NULL;
 	`
 
-	if _, err := tx.ExecContext(ctx, insertQuery, appointmentID, pq.Array(serviceIDs)); err != nil {
+	if _, err := tx.ExecContext(ctx, insertQuery, appointment.ID, pq.Array(serviceIDs)); err != nil {
 		return fmt.Errorf("failed to insert new services: %w", err)
 	}
 
+	// A cancelled or missed appointment keeps no resources or mechanics
+	if appointment.Status.ReleasesSlot() {
+		req = &entity.AppointmentRequirements{}
+	}
+
+	if err := allocateResources(ctx, tx, appointment, req.ResourceTypes); err != nil {
+		return err
+	}
+
+	if err := assignMechanics(ctx, tx, appointment, req.MechanicIDs); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Reschedule moves the appointment to its new time, allocates resources and
+// mechanics for it from scratch and records the previous time. The old
+// allocations are released first, so the appointment never conflicts with itself.
+func (s *appointmentStorage) Reschedule(ctx context.Context, appointment *entity.Appointment, req *entity.AppointmentRequirements, change *entity.AppointmentTimeChange) error {
+	tx, err := s.pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	const query = `
+		UPDATE appointments
+		SET appointment_time = $2, ends_at = $3, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL;
+	`
+
+	result, err := tx.ExecContext(ctx, query, appointment.ID, appointment.AppointmentTime, appointment.EndsAt)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule appointment: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("appointment %w", ErrNotFound)
+	}
+
+	const releaseQuery = `
+		DELETE FROM appointment_resources
+		WHERE appointment_id = $1;
+	`
+
+	if _, err := tx.ExecContext(ctx, releaseQuery, appointment.ID); err != nil {
+		return fmt.Errorf("failed to release appointment resources: %w", err)
+	}
+
+	if err := allocateResources(ctx, tx, appointment, req.ResourceTypes); err != nil {
+		return err
+	}
+
+	if err := assignMechanics(ctx, tx, appointment, req.MechanicIDs); err != nil {
+		return err
+	}
+
+	const historyQuery = `
+		INSERT INTO appointment_time_history (id, appointment_id, from_time, to_time, changed_by, api_key_id, reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7);
+	`
+
+	if change.ID == uuid.Nil {
+		change.ID = uuid.New()
+	}
+
+	if _, err := tx.ExecContext(ctx, historyQuery,
+		change.ID, change.AppointmentID, change.FromTime, change.ToTime,
+		change.Actor.UserID, change.Actor.APIKeyID, change.Reason,
+	); err != nil {
+		return fmt.Errorf("failed to record reschedule: %w", err)
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -218,14 +648,50 @@ func (s *appointmentStorage) UpdateServices(ctx context.Context, appointmentID u
 	return nil
 }
 
+// GetTimeHistory returns the reschedules of the appointment, oldest first.
+func (s *appointmentStorage) GetTimeHistory(ctx context.Context, appointmentID uuid.UUID) ([]*entity.AppointmentTimeChange, error) {
+	const query = `
+		SELECT id, appointment_id, from_time, to_time, changed_by, api_key_id, reason, created_at
+		FROM appointment_time_history
+		WHERE appointment_id = $1
+		ORDER BY created_at, id;
+	`
+
+	rows, err := s.pg.DB.QueryContext(ctx, query, appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reschedule history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []*entity.AppointmentTimeChange{}
+	for rows.Next() {
+		var change entity.AppointmentTimeChange
+		if err := rows.Scan(
+			&change.ID, &change.AppointmentID, &change.FromTime, &change.ToTime,
+			&change.Actor.UserID, &change.Actor.APIKeyID, &change.Reason, &change.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan reschedule: %w", err)
+		}
+		history = append(history, &change)
+	}
+
+	return history, nil
+}
+
 func (s *appointmentStorage) Delete(ctx context.Context, id uuid.UUID) error {
+	tx, err := s.pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	const query = `
 		UPDATE appointments
 		SET deleted_at = NOW()
 		WHERE id = $1 AND deleted_at IS NULL;
 	`
 
-	result, err := s.pg.DB.ExecContext(ctx, query, id)
+	result, err := tx.ExecContext(ctx, query, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete appointment: %w", err)
 	}
@@ -239,24 +705,289 @@ func (s *appointmentStorage) Delete(ctx context.Context, id uuid.UUID) error {
 		return fmt.Errorf("appointment not found")
 	}
 
+	const releaseQuery = `
+		UPDATE appointment_resources
+		SET active = FALSE
+		WHERE appointment_id = $1;
+	`
+
+	if _, err := tx.ExecContext(ctx, releaseQuery, id); err != nil {
+		return fmt.Errorf("failed to release appointment resources: %w", err)
+	}
+
+	const releaseMechanicsQuery = `
+		UPDATE appointment_mechanics
+		SET active = FALSE
+		WHERE appointment_id = $1;
+	`
+
+	if _, err := tx.ExecContext(ctx, releaseMechanicsQuery, id); err != nil {
+		return fmt.Errorf("failed to release appointment mechanics: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return nil
 }
 
-func (s *appointmentStorage) CheckTimeSlotAvailable(ctx context.Context, appointmentTime string) (bool, error) {
+// GetAllocations returns the active allocations of the resources that intersect [from, to).
+func (s *appointmentStorage) GetAllocations(ctx context.Context, resourceIDs []uuid.UUID, from, to time.Time) ([]entity.ResourceAllocation, error) {
 	const query = `
-		SELECT EXISTS (
+		SELECT resource_id, unit, starts_at, ends_at
+		FROM appointment_resources
+		WHERE resource_id = ANY($1)
+		AND tstzrange(starts_at, ends_at) && tstzrange($2, $3)
+		AND active
+		ORDER BY starts_at;
+	`
+
+	rows, err := s.pg.DB.QueryContext(ctx, query, pq.Array(resourceIDs), from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query resource allocations: %w", err)
+	}
+	defer rows.Close()
+
+	var allocations []entity.ResourceAllocation
+	for rows.Next() {
+		var allocation entity.ResourceAllocation
+		if err := rows.Scan(&allocation.ResourceID, &allocation.Unit, &allocation.Start, &allocation.End); err != nil {
+			return nil, fmt.Errorf("failed to scan resource allocation: %w", err)
+		}
+		allocations = append(allocations, allocation)
+	}
+
+	return allocations, nil
+}
+
+// allocateResources assigns the appointment the first free unit of a resource
+// of every type at its location. The resources are locked first, so concurrent bookings of the
+// same types wait for each other instead of failing on the constraint.
+func allocateResources(ctx context.Context, tx *sql.Tx, appointment *entity.Appointment, resourceTypes []string) error {
+	appointment.Resources = nil
+	if len(resourceTypes) == 0 {
+		return nil
+	}
+
+	const lockQuery = `
+		SELECT id
+		FROM resources
+		WHERE location_id = $1 AND type = ANY($2) AND deleted_at IS NULL
+		ORDER BY id
+		FOR UPDATE;
+	`
+
+	if _, err := tx.ExecContext(ctx, lockQuery, appointment.LocationID, pq.Array(resourceTypes)); err != nil {
+		return fmt.Errorf("failed to lock resources: %w", err)
+	}
+
+	const freeUnitQuery = `
+		SELECT r.id, r.name, r.type, u.unit
+		FROM resources r
+		CROSS JOIN LATERAL generate_series(1, r.capacity) AS u(unit)
+		WHERE r.location_id = $1 AND r.type = $2 AND r.deleted_at IS NULL
+		AND NOT EXISTS (
 			SELECT 1
-			FROM appointments
-			WHERE appointment_time = $1
-			AND deleted_at IS NULL
-			AND status != 'cancelled'
-		);
+			FROM appointment_resources ar
+			WHERE ar.resource_id = r.id AND ar.unit = u.unit AND ar.active
+			AND tstzrange(ar.starts_at, ar.ends_at) && tstzrange($3, $4)
+		)
+		ORDER BY r.name, u.unit
+		LIMIT 1;
+	`
+
+	const insertQuery = `
+		INSERT INTO appointment_resources (appointment_id, resource_id, unit, starts_at, ends_at)
+		VALUES ($1, $2, $3, $4, $5);
+	`
+
+	for _, resourceType := range resourceTypes {
+		var resource entity.AppointmentResource
+		row := tx.QueryRowContext(ctx, freeUnitQuery,
+			appointment.LocationID, resourceType, appointment.AppointmentTime, appointment.EndsAt,
+		)
+		if err := row.Scan(&resource.ResourceID, &resource.Name, &resource.Type, &resource.Unit); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrAppointmentOverlap
+			}
+			return fmt.Errorf("failed to find free %s: %w", resourceType, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, insertQuery,
+			appointment.ID, resource.ResourceID, resource.Unit, appointment.AppointmentTime, appointment.EndsAt,
+		); err != nil {
+			return wrapOverlapError(err, "failed to allocate resource")
+		}
+
+		appointment.Resources = append(appointment.Resources, &resource)
+	}
+
+	return nil
+}
+
+// SetMechanics replaces the mechanics assigned to the appointment.
+func (s *appointmentStorage) SetMechanics(ctx context.Context, appointment *entity.Appointment, mechanicIDs []uuid.UUID) error {
+	tx, err := s.pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := assignMechanics(ctx, tx, appointment, mechanicIDs); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetMechanicAllocations returns the active assignments of the mechanics that intersect [from, to).
+func (s *appointmentStorage) GetMechanicAllocations(ctx context.Context, mechanicIDs []uuid.UUID, from, to time.Time) ([]entity.MechanicAllocation, error) {
+	const query = `
+		SELECT user_id, appointment_id, starts_at, ends_at
+		FROM appointment_mechanics
+		WHERE user_id = ANY($1)
+		AND tstzrange(starts_at, ends_at) && tstzrange($2, $3)
+		AND active
+		ORDER BY starts_at;
+	`
+
+	rows, err := s.pg.DB.QueryContext(ctx, query, pq.Array(mechanicIDs), from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query mechanic allocations: %w", err)
+	}
+	defer rows.Close()
+
+	var allocations []entity.MechanicAllocation
+	for rows.Next() {
+		var allocation entity.MechanicAllocation
+		if err := rows.Scan(&allocation.UserID, &allocation.AppointmentID, &allocation.Start, &allocation.End); err != nil {
+			return nil, fmt.Errorf("failed to scan mechanic allocation: %w", err)
+		}
+		allocations = append(allocations, allocation)
+	}
+
+	return allocations, nil
+}
+
+// assignMechanics replaces the mechanics of the appointment. The caller picks
+// them, the appointment_mechanics_no_overlap constraint rejects anyone who was
+// booked concurrently in the meantime.
+func assignMechanics(ctx context.Context, tx *sql.Tx, appointment *entity.Appointment, mechanicIDs []uuid.UUID) error {
+	const deleteQuery = `
+		DELETE FROM appointment_mechanics
+		WHERE appointment_id = $1;
+	`
+
+	if _, err := tx.ExecContext(ctx, deleteQuery, appointment.ID); err != nil {
+		return fmt.Errorf("failed to release appointment mechanics: %w", err)
+	}
+
+	const insertQuery = `
+		INSERT INTO appointment_mechanics (appointment_id, user_id, starts_at, ends_at, active)
+		VALUES ($1, $2, $3, $4, $5);
+	`
+
+	active := !appointment.Status.ReleasesSlot()
+	for _, mechanicID := range mechanicIDs {
+		if _, err := tx.ExecContext(ctx, insertQuery,
+			appointment.ID, mechanicID, appointment.AppointmentTime, appointment.EndsAt, active,
+		); err != nil {
+			return wrapOverlapError(err, "failed to assign mechanic")
+		}
+	}
+
+	mechanics, err := getMechanics(ctx, tx, appointment.ID)
+	if err != nil {
+		return err
+	}
+	appointment.Mechanics = mechanics
+
+	return nil
+}
+
+// queryer is implemented by both the database and a transaction.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func getMechanics(ctx context.Context, db queryer, appointmentID uuid.UUID) ([]*entity.AppointmentMechanic, error) {
+	const query = `
+		SELECT u.id, u.full_name
+		FROM appointment_mechanics am
+		JOIN users u ON u.id = am.user_id
+		WHERE am.appointment_id = $1 AND am.active
+		ORDER BY u.full_name;
+	`
+
+	rows, err := db.QueryContext(ctx, query, appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query appointment mechanics: %w", err)
+	}
+	defer rows.Close()
+
+	var mechanics []*entity.AppointmentMechanic
+	for rows.Next() {
+		var mechanic entity.AppointmentMechanic
+		if err := rows.Scan(&mechanic.UserID, &mechanic.FullName); err != nil {
+			return nil, fmt.Errorf("failed to scan appointment mechanic: %w", err)
+		}
+		mechanics = append(mechanics, &mechanic)
+	}
+
+	return mechanics, nil
+}
+
+func recordStatusChange(ctx context.Context, tx *sql.Tx, change *entity.AppointmentStatusChange) error {
+	const query = `
+		INSERT INTO appointment_status_history (id, appointment_id, from_status, to_status, changed_by, api_key_id, reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7);
 	`
 
-	var exists bool
-	if err := s.pg.DB.QueryRowContext(ctx, query, appointmentTime).Scan(&exists); err != nil {
-		return false, fmt.Errorf("failed to check time slot: %w", err)
+	if change.ID == uuid.Nil {
+		change.ID = uuid.New()
+	}
+
+	if _, err := tx.ExecContext(ctx, query,
+		change.ID, change.AppointmentID, change.FromStatus, change.ToStatus,
+		change.Actor.UserID, change.Actor.APIKeyID, change.Reason,
+	); err != nil {
+		return fmt.Errorf("failed to record status change: %w", err)
+	}
+
+	return nil
+}
+
+// GetStatusHistory returns the status changes of the appointment, oldest first.
+func (s *appointmentStorage) GetStatusHistory(ctx context.Context, appointmentID uuid.UUID) ([]*entity.AppointmentStatusChange, error) {
+	const query = `
+		SELECT id, appointment_id, from_status, to_status, changed_by, api_key_id, reason, created_at
+		FROM appointment_status_history
+		WHERE appointment_id = $1
+		ORDER BY created_at, id;
+	`
+
+	rows, err := s.pg.DB.QueryContext(ctx, query, appointmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query status history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []*entity.AppointmentStatusChange{}
+	for rows.Next() {
+		var change entity.AppointmentStatusChange
+		if err := rows.Scan(
+			&change.ID, &change.AppointmentID, &change.FromStatus, &change.ToStatus,
+			&change.Actor.UserID, &change.Actor.APIKeyID, &change.Reason, &change.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan status change: %w", err)
+		}
+		history = append(history, &change)
 	}
 
-	return !exists, nil
+	return history, nil
 }
@@ -0,0 +1,204 @@
+package storages
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/pkg/database"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+type CalendarRepository interface {
+	GetBusinessHours(ctx context.Context, locationID uuid.UUID) ([]entity.BusinessHours, error)
+	SetBusinessHours(ctx context.Context, locationID uuid.UUID, hours []entity.BusinessHours) error
+	GetDays(ctx context.Context, from, to string) ([]*entity.CalendarDay, error)
+	UpsertDay(ctx context.Context, day *entity.CalendarDay) error
+	DeleteDay(ctx context.Context, date string) error
+	ReplaceImported(ctx context.Context, year int, days []*entity.CalendarDay) (int, error)
+}
+
+type calendarStorage struct {
+	pg *database.PostgresDB
+}
+
+func NewCalendarStorage(deps StorageDeps) CalendarRepository {
+	return &calendarStorage{
+		pg: deps.PostgresDB,
+	}
+}
+
+func (s *calendarStorage) GetBusinessHours(ctx context.Context, locationID uuid.UUID) ([]entity.BusinessHours, error) {
+	const query = `
+		SELECT weekday, to_char(open_time, 'HH24:MI'), to_char(close_time, 'HH24:MI'),
+			to_char(break_start, 'HH24:MI'), to_char(break_end, 'HH24:MI')
+		FROM business_hours
+		WHERE location_id = $1
+		ORDER BY weekday;
+	`
+
+	rows, err := s.pg.DB.QueryContext(ctx, query, locationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query business hours: %w", err)
+	}
+	defer rows.Close()
+
+	hours := []entity.BusinessHours{}
+	for rows.Next() {
+		var day entity.BusinessHours
+		if err := rows.Scan(&day.Weekday, &day.OpenTime, &day.CloseTime, &day.BreakStart, &day.BreakEnd); err != nil {
+			return nil, fmt.Errorf("failed to scan business hours: %w", err)
+		}
+		hours = append(hours, day)
+	}
+
+	return hours, nil
+}
+
+// SetBusinessHours replaces the whole weekly schedule of the location.
+func (s *calendarStorage) SetBusinessHours(ctx context.Context, locationID uuid.UUID, hours []entity.BusinessHours) error {
+	tx, err := s.pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM business_hours WHERE location_id = $1;`, locationID); err != nil {
+		return fmt.Errorf("failed to delete business hours: %w", err)
+	}
+
+	const insertQuery = `
+		INSERT INTO business_hours (location_id, weekday, open_time, close_time, break_start, break_end)
+		VALUES ($1, $2, $3::TIME, $4::TIME, $5::TIME, $6::TIME);
+	`
+
+	for _, day := range hours {
+		if _, err := tx.ExecContext(ctx, insertQuery,
+			locationID, int(day.Weekday), day.OpenTime, day.CloseTime, day.BreakStart, day.BreakEnd,
+		); err != nil {
+			return fmt.Errorf("failed to insert business hours: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetDays returns the calendar entries between the dates, both inclusive.
+func (s *calendarStorage) GetDays(ctx context.Context, from, to string) ([]*entity.CalendarDay, error) {
+	const query = `
+		SELECT to_char(date, 'YYYY-MM-DD'), kind, to_char(open_time, 'HH24:MI'), to_char(close_time, 'HH24:MI'),
+			name, source
+		FROM calendar_days
+		WHERE date BETWEEN $1::DATE AND $2::DATE
+		ORDER BY date;
+	`
+
+	rows, err := s.pg.DB.QueryContext(ctx, query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query calendar days: %w", err)
+	}
+	defer rows.Close()
+
+	days := []*entity.CalendarDay{}
+	for rows.Next() {
+		var day entity.CalendarDay
+		if err := rows.Scan(&day.Date, &day.Kind, &day.OpenTime, &day.CloseTime, &day.Name, &day.Source); err != nil {
+			return nil, fmt.Errorf("failed to scan calendar day: %w", err)
+		}
+		days = append(days, &day)
+	}
+
+	return days, nil
+}
+
+func (s *calendarStorage) UpsertDay(ctx context.Context, day *entity.CalendarDay) error {
+	const query = `
+		INSERT INTO calendar_days (date, kind, open_time, close_time, name, source)
+		VALUES ($1::DATE, $2, $3::TIME, $4::TIME, $5, $6)
+		ON CONFLICT (date) DO UPDATE
+		SET kind = EXCLUDED.kind,
+			open_time = EXCLUDED.open_time,
+			close_time = EXCLUDED.close_time,
+			name = EXCLUDED.name,
+			source = EXCLUDED.source;
+	`
+
+	if _, err := s.pg.DB.ExecContext(ctx, query,
+		day.Date, day.Kind, day.OpenTime, day.CloseTime, day.Name, day.Source,
+	); err != nil {
+		return fmt.Errorf("failed to save calendar day: %w", err)
+	}
+
+	return nil
+}
+
+func (s *calendarStorage) DeleteDay(ctx context.Context, date string) error {
+	const query = `
+		DELETE FROM calendar_days
+		WHERE date = $1::DATE;
+	`
+
+	result, err := s.pg.DB.ExecContext(ctx, query, date)
+	if err != nil {
+		return fmt.Errorf("failed to delete calendar day: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("calendar day not found")
+	}
+
+	return nil
+}
+
+// ReplaceImported swaps the imported entries of the year for the new ones.
+// Manual entries win: imported days on the same date are skipped.
+// It returns how many days were imported.
+func (s *calendarStorage) ReplaceImported(ctx context.Context, year int, days []*entity.CalendarDay) (int, error) {
+	tx, err := s.pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	const deleteQuery = `
+		DELETE FROM calendar_days
+		WHERE source = 'import' AND EXTRACT(YEAR FROM date) = $1;
+	`
+
+	if _, err := tx.ExecContext(ctx, deleteQuery, year); err != nil {
+		return 0, fmt.Errorf("failed to delete imported days: %w", err)
+	}
+
+	const insertQuery = `
+		INSERT INTO calendar_days (date, kind, name, source)
+		VALUES ($1::DATE, $2, $3, 'import')
+		ON CONFLICT (date) DO NOTHING;
+	`
+
+	imported := 0
+	for _, day := range days {
+		result, err := tx.ExecContext(ctx, insertQuery, day.Date, day.Kind, day.Name)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert imported day: %w", err)
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get affected rows: %w", err)
+		}
+		imported += int(rows)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return imported, nil
+}
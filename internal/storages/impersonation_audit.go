@@ -0,0 +1,75 @@
+package storages
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/pkg/database"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+type ImpersonationAuditRepository interface {
+	Create(ctx context.Context, entry *entity.ImpersonationAuditEntry) error
+	GetByUserId(ctx context.Context, userId uuid.UUID) ([]*entity.ImpersonationAuditEntry, error)
+}
+
+type impersonationAuditStorage struct {
+	pg *database.PostgresDB
+}
+
+func NewImpersonationAuditStorage(deps StorageDeps) ImpersonationAuditRepository {
+	return &impersonationAuditStorage{
+		pg: deps.PostgresDB,
+	}
+}
+
+func (s *impersonationAuditStorage) Create(ctx context.Context, entry *entity.ImpersonationAuditEntry) error {
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
+	}
+
+	const query = `
+		INSERT INTO impersonation_audit (id, actor_id, user_id, action, method, path, status, ip)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+	`
+
+	_, err := s.pg.DB.ExecContext(ctx, query,
+		entry.ID, entry.ActorID, entry.UserID, entry.Action, entry.Method, entry.Path, entry.Status, entry.IP,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert impersonation audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// GetByUserId возвращает записи, где пользователь был актором или тем, от чьего имени действовали.
+func (s *impersonationAuditStorage) GetByUserId(ctx context.Context, userId uuid.UUID) ([]*entity.ImpersonationAuditEntry, error) {
+	const query = `
+		SELECT id, actor_id, user_id, action, COALESCE(method, ''), COALESCE(path, ''),
+			COALESCE(status, 0), COALESCE(ip, ''), created_at
+		FROM impersonation_audit
+		WHERE user_id = $1 OR actor_id = $1
+		ORDER BY created_at DESC;
+	`
+
+	rows, err := s.pg.DB.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query impersonation audit: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*entity.ImpersonationAuditEntry
+	for rows.Next() {
+		var entry entity.ImpersonationAuditEntry
+		if err := rows.Scan(
+			&entry.ID, &entry.ActorID, &entry.UserID, &entry.Action, &entry.Method, &entry.Path,
+			&entry.Status, &entry.IP, &entry.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan impersonation audit entry: %w", err)
+		}
+		entries = append(entries, &entry)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,161 @@
+package storages
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/pkg/database"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+type LocationRepository interface {
+	Create(ctx context.Context, location *entity.Location) (uuid.UUID, error)
+	GetById(ctx context.Context, id uuid.UUID) (*entity.Location, error)
+	GetAll(ctx context.Context) ([]*entity.Location, error)
+	Update(ctx context.Context, location *entity.Location) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	HasUpcomingAppointments(ctx context.Context, id uuid.UUID) (bool, error)
+}
+
+type locationStorage struct {
+	pg *database.PostgresDB
+}
+
+func NewLocationStorage(deps StorageDeps) LocationRepository {
+	return &locationStorage{
+		pg: deps.PostgresDB,
+	}
+}
+
+func (s *locationStorage) Create(ctx context.Context, location *entity.Location) (uuid.UUID, error) {
+	if location.ID == uuid.Nil {
+		location.ID = uuid.New()
+	}
+
+	const query = `
+		INSERT INTO locations (id, name, address, time_zone)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id;
+	`
+
+	row := s.pg.DB.QueryRowContext(ctx, query, location.ID, location.Name, location.Address, location.TimeZone)
+	if err := row.Scan(&location.ID); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to insert location: %w", err)
+	}
+
+	return location.ID, nil
+}
+
+func (s *locationStorage) GetById(ctx context.Context, id uuid.UUID) (*entity.Location, error) {
+	const query = `
+		SELECT id, name, address, time_zone, created_at, updated_at
+		FROM locations
+		WHERE id = $1 AND deleted_at IS NULL;
+	`
+
+	var location entity.Location
+	row := s.pg.DB.QueryRowContext(ctx, query, id)
+	if err := row.Scan(
+		&location.ID, &location.Name, &location.Address, &location.TimeZone, &location.CreatedAt, &location.UpdatedAt,
+	); err != nil {
+		return nil, fmt.Errorf("failed to get location: %w", err)
+	}
+
+	return &location, nil
+}
+
+func (s *locationStorage) GetAll(ctx context.Context) ([]*entity.Location, error) {
+	const query = `
+		SELECT id, name, address, time_zone, created_at, updated_at
+		FROM locations
+		WHERE deleted_at IS NULL
+		ORDER BY name;
+	`
+
+	rows, err := s.pg.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query locations: %w", err)
+	}
+	defer rows.Close()
+
+	locations := []*entity.Location{}
+	for rows.Next() {
+		var location entity.Location
+		if err := rows.Scan(
+			&location.ID, &location.Name, &location.Address, &location.TimeZone, &location.CreatedAt, &location.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan location: %w", err)
+		}
+		locations = append(locations, &location)
+	}
+
+	return locations, nil
+}
+
+func (s *locationStorage) Update(ctx context.Context, location *entity.Location) error {
+	const query = `
+		UPDATE locations
+		SET name = $2, address = $3, time_zone = $4, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL;
+	`
+
+	result, err := s.pg.DB.ExecContext(ctx, query, location.ID, location.Name, location.Address, location.TimeZone)
+	if err != nil {
+		return fmt.Errorf("failed to update location: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("location not found")
+	}
+
+	return nil
+}
+
+func (s *locationStorage) Delete(ctx context.Context, id uuid.UUID) error {
+	const query = `
+		UPDATE locations
+		SET deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL;
+	`
+
+	result, err := s.pg.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete location: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("location not found")
+	}
+
+	return nil
+}
+
+// HasUpcomingAppointments reports whether the location has appointments that
+// still hold its resources and have not ended yet.
+func (s *locationStorage) HasUpcomingAppointments(ctx context.Context, id uuid.UUID) (bool, error) {
+	const query = `
+		SELECT EXISTS (
+			SELECT 1
+			FROM appointments
+			WHERE location_id = $1 AND deleted_at IS NULL AND ends_at > NOW()
+			AND status NOT IN ('cancelled', 'no_show', 'completed')
+		);
+	`
+
+	var exists bool
+	if err := s.pg.DB.QueryRowContext(ctx, query, id).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check location appointments: %w", err)
+	}
+
+	return exists, nil
+}
@@ -0,0 +1,75 @@
+package storages
+
+import (
+	"backend-service/pkg/database"
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	loginFailuresKeyPrefix = "login_failures:"
+	loginBlockKeyPrefix    = "login_block:"
+)
+
+// LoginAttemptRepository tracks failed logins per subject (account or IP)
+// and the temporary blocks derived from them.
+type LoginAttemptRepository interface {
+	AddFailure(ctx context.Context, subject string, window time.Duration) (int64, error)
+	Block(ctx context.Context, subject string, ttl time.Duration) error
+	BlockedFor(ctx context.Context, subject string) (time.Duration, error)
+	Reset(ctx context.Context, subject string) error
+}
+
+type loginAttemptStorage struct {
+	redis *database.Redis
+}
+
+func NewLoginAttemptStorage(deps StorageDeps) LoginAttemptRepository {
+	return &loginAttemptStorage{
+		redis: deps.Redis,
+	}
+}
+
+func (s *loginAttemptStorage) AddFailure(ctx context.Context, subject string, window time.Duration) (int64, error) {
+	key := loginFailuresKeyPrefix + subject
+	client := s.redis.Client.WithContext(ctx)
+
+	count, err := client.Incr(key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to add login failure: %w", err)
+	}
+	if count == 1 {
+		if err := client.Expire(key, window).Err(); err != nil {
+			return 0, fmt.Errorf("failed to add login failure: %w", err)
+		}
+	}
+
+	return count, nil
+}
+
+func (s *loginAttemptStorage) Block(ctx context.Context, subject string, ttl time.Duration) error {
+	if err := s.redis.Client.WithContext(ctx).Set(loginBlockKeyPrefix+subject, 1, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to block login: %w", err)
+	}
+	return nil
+}
+
+func (s *loginAttemptStorage) BlockedFor(ctx context.Context, subject string) (time.Duration, error) {
+	ttl, err := s.redis.Client.WithContext(ctx).PTTL(loginBlockKeyPrefix + subject).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check login block: %w", err)
+	}
+	// PTTL returns a negative value when the key does not exist
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+func (s *loginAttemptStorage) Reset(ctx context.Context, subject string) error {
+	if err := s.redis.Client.WithContext(ctx).Del(loginFailuresKeyPrefix+subject, loginBlockKeyPrefix+subject).Err(); err != nil {
+		return fmt.Errorf("failed to reset login attempts: %w", err)
+	}
+	return nil
+}
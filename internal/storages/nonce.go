@@ -0,0 +1,54 @@
+package storages
+
+import (
+	"backend-service/pkg/database"
+	"backend-service/pkg/jwt"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const nonceKeyPrefix = "refresh_nonce:"
+
+// consumeNonceScript атомарно забирает nonce: ключ удаляется в любом случае,
+// а 1 возвращается только если сохраненный refresh-токен совпал с переданным.
+// Повторное предъявление старого токена удаляет nonce и обрывает всю цепочку.
+var consumeNonceScript = redis.NewScript(`
+local stored = redis.call('GET', KEYS[1])
+if not stored then
+	return -1
+end
+redis.call('DEL', KEYS[1])
+if stored == ARGV[1] then
+	return 1
+end
+return 0
+`)
+
+type nonceStorage struct {
+	redis *database.Redis
+	ttl   time.Duration
+}
+
+func NewNonceStorage(deps StorageDeps) jwt.NonceStorage {
+	return &nonceStorage{
+		redis: deps.Redis,
+		ttl:   deps.NonceTTL,
+	}
+}
+
+func (s *nonceStorage) Save(nonce, token string) error {
+	if err := s.redis.Client.Set(nonceKeyPrefix+nonce, token, s.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save nonce: %w", err)
+	}
+	return nil
+}
+
+func (s *nonceStorage) Validate(nonce, token string) (bool, error) {
+	res, err := consumeNonceScript.Run(s.redis.Client, []string{nonceKeyPrefix + nonce}, token).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to validate nonce: %w", err)
+	}
+	return res == 1, nil
+}
@@ -0,0 +1,102 @@
+package storages
+
+import (
+	"backend-service/pkg/database"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	oneTimeCodeKeyPrefix      = "otp:"
+	oneTimeCodeThrottlePrefix = "otp_throttle:"
+)
+
+// verifyCodeScript counts the attempt, drops the code once attempts are
+// exhausted and consumes it on a match, so every code is single-use.
+var verifyCodeScript = redis.NewScript(`
+local hash = redis.call('HGET', KEYS[1], 'hash')
+if not hash then
+	return 0
+end
+local attempts = redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+if hash == ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	return 1
+end
+if attempts >= tonumber(ARGV[2]) then
+	redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+type OneTimeCodeRepository interface {
+	Save(ctx context.Context, purpose, subject, code string, ttl time.Duration) error
+	Verify(ctx context.Context, purpose, subject, code string, maxAttempts int) (bool, error)
+	Throttle(ctx context.Context, purpose, subject string, limit int, window time.Duration) (bool, error)
+}
+
+type oneTimeCodeStorage struct {
+	redis *database.Redis
+}
+
+func NewOneTimeCodeStorage(deps StorageDeps) OneTimeCodeRepository {
+	return &oneTimeCodeStorage{
+		redis: deps.Redis,
+	}
+}
+
+func hashCode(code string) string {
+	hash := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(hash[:])
+}
+
+func (s *oneTimeCodeStorage) Save(ctx context.Context, purpose, subject, code string, ttl time.Duration) error {
+	key := oneTimeCodeKeyPrefix + purpose + ":" + subject
+	client := s.redis.Client.WithContext(ctx)
+
+	// A new code replaces the previous one and resets the attempt counter
+	pipe := client.TxPipeline()
+	pipe.Del(key)
+	pipe.HSet(key, "hash", hashCode(code))
+	pipe.HSet(key, "attempts", 0)
+	pipe.Expire(key, ttl)
+	if _, err := pipe.Exec(); err != nil {
+		return fmt.Errorf("failed to save code: %w", err)
+	}
+
+	return nil
+}
+
+func (s *oneTimeCodeStorage) Verify(ctx context.Context, purpose, subject, code string, maxAttempts int) (bool, error) {
+	key := oneTimeCodeKeyPrefix + purpose + ":" + subject
+	client := s.redis.Client.WithContext(ctx)
+
+	res, err := verifyCodeScript.Run(client, []string{key}, hashCode(code), maxAttempts).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to verify code: %w", err)
+	}
+
+	return res == 1, nil
+}
+
+func (s *oneTimeCodeStorage) Throttle(ctx context.Context, purpose, subject string, limit int, window time.Duration) (bool, error) {
+	key := oneTimeCodeThrottlePrefix + purpose + ":" + subject
+	client := s.redis.Client.WithContext(ctx)
+
+	count, err := client.Incr(key).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to throttle code: %w", err)
+	}
+	if count == 1 {
+		if err := client.Expire(key, window).Err(); err != nil {
+			return false, fmt.Errorf("failed to throttle code: %w", err)
+		}
+	}
+
+	return count <= int64(limit), nil
+}
@@ -0,0 +1,86 @@
+package storages
+
+import (
+	"backend-service/pkg/database"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+// RecoveryCodeRepository stores hashes of single-use 2FA recovery codes.
+type RecoveryCodeRepository interface {
+	Replace(ctx context.Context, userId uuid.UUID, codeHashes []string) error
+	Use(ctx context.Context, userId uuid.UUID, codeHash string) (bool, error)
+	DeleteByUserId(ctx context.Context, userId uuid.UUID) error
+}
+
+type recoveryCodeStorage struct {
+	pg *database.PostgresDB
+}
+
+func NewRecoveryCodeStorage(deps StorageDeps) RecoveryCodeRepository {
+	return &recoveryCodeStorage{
+		pg: deps.PostgresDB,
+	}
+}
+
+// Replace drops all previous codes of the user and stores the new set.
+func (s *recoveryCodeStorage) Replace(ctx context.Context, userId uuid.UUID, codeHashes []string) error {
+	tx, err := s.pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	const deleteQuery = `DELETE FROM totp_recovery_codes WHERE user_id = $1;`
+	if _, err := tx.ExecContext(ctx, deleteQuery, userId); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	const insertQuery = `
+		INSERT INTO totp_recovery_codes (id, user_id, code_hash)
+		VALUES ($1, $2, $3);
+	`
+	for _, codeHash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, insertQuery, uuid.New(), userId, codeHash); err != nil {
+			return fmt.Errorf("failed to insert recovery code: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Use marks an unused code as used. It returns false if there is no such code.
+func (s *recoveryCodeStorage) Use(ctx context.Context, userId uuid.UUID, codeHash string) (bool, error) {
+	const query = `
+		UPDATE totp_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+	`
+
+	result, err := s.pg.DB.ExecContext(ctx, query, userId, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+func (s *recoveryCodeStorage) DeleteByUserId(ctx context.Context, userId uuid.UUID) error {
+	const query = `DELETE FROM totp_recovery_codes WHERE user_id = $1;`
+
+	if _, err := s.pg.DB.ExecContext(ctx, query, userId); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	return nil
+}
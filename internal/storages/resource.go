@@ -0,0 +1,248 @@
+package storages
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/pkg/database"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type ResourceRepository interface {
+	Create(ctx context.Context, resource *entity.Resource) (uuid.UUID, error)
+	GetById(ctx context.Context, id uuid.UUID) (*entity.Resource, error)
+	GetAll(ctx context.Context, locationID *uuid.UUID) ([]*entity.Resource, error)
+	GetByTypes(ctx context.Context, locationID uuid.UUID, types []string) ([]*entity.Resource, error)
+	Update(ctx context.Context, resource *entity.Resource) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	HasUpcomingAllocations(ctx context.Context, id uuid.UUID, minUnit int) (bool, error)
+	GetServiceTypes(ctx context.Context, serviceID uuid.UUID) ([]string, error)
+	SetServiceTypes(ctx context.Context, serviceID uuid.UUID, types []string) error
+	GetTypesByServiceIds(ctx context.Context, serviceIDs []uuid.UUID) ([]string, error)
+}
+
+type resourceStorage struct {
+	pg *database.PostgresDB
+}
+
+func NewResourceStorage(deps StorageDeps) ResourceRepository {
+	return &resourceStorage{
+		pg: deps.PostgresDB,
+	}
+}
+
+func (s *resourceStorage) Create(ctx context.Context, resource *entity.Resource) (uuid.UUID, error) {
+	if resource.ID == uuid.Nil {
+		resource.ID = uuid.New()
+	}
+
+	const query = `
+		INSERT INTO resources (id, location_id, name, type, capacity)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id;
+	`
+
+	row := s.pg.DB.QueryRowContext(ctx, query,
+		resource.ID, resource.LocationID, resource.Name, resource.Type, resource.Capacity,
+	)
+	if err := row.Scan(&resource.ID); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to insert resource: %w", err)
+	}
+
+	return resource.ID, nil
+}
+
+func (s *resourceStorage) GetById(ctx context.Context, id uuid.UUID) (*entity.Resource, error) {
+	const query = `
+		SELECT id, location_id, name, type, capacity, created_at, updated_at
+		FROM resources
+		WHERE id = $1 AND deleted_at IS NULL;
+	`
+
+	var resource entity.Resource
+	row := s.pg.DB.QueryRowContext(ctx, query, id)
+	if err := row.Scan(
+		&resource.ID, &resource.LocationID, &resource.Name, &resource.Type, &resource.Capacity, &resource.CreatedAt, &resource.UpdatedAt,
+	); err != nil {
+		return nil, fmt.Errorf("failed to get resource: %w", err)
+	}
+
+	return &resource, nil
+}
+
+// GetAll returns the resources of the location, or of every location when it is nil.
+func (s *resourceStorage) GetAll(ctx context.Context, locationID *uuid.UUID) ([]*entity.Resource, error) {
+	const query = `
+		SELECT id, location_id, name, type, capacity, created_at, updated_at
+		FROM resources
+		WHERE ($1::UUID IS NULL OR location_id = $1) AND deleted_at IS NULL
+		ORDER BY type, name;
+	`
+
+	return s.query(ctx, query, locationID)
+}
+
+// GetByTypes returns the resources of the given types at the location,
+// ordered the same way the appointment storage picks them.
+func (s *resourceStorage) GetByTypes(ctx context.Context, locationID uuid.UUID, types []string) ([]*entity.Resource, error) {
+	const query = `
+		SELECT id, location_id, name, type, capacity, created_at, updated_at
+		FROM resources
+		WHERE location_id = $1 AND type = ANY($2) AND deleted_at IS NULL
+		ORDER BY type, name;
+	`
+
+	return s.query(ctx, query, locationID, pq.Array(types))
+}
+
+func (s *resourceStorage) query(ctx context.Context, query string, args ...interface{}) ([]*entity.Resource, error) {
+	rows, err := s.pg.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query resources: %w", err)
+	}
+	defer rows.Close()
+
+	var resources []*entity.Resource
+	for rows.Next() {
+		var resource entity.Resource
+		if err := rows.Scan(
+			&resource.ID, &resource.LocationID, &resource.Name, &resource.Type, &resource.Capacity, &resource.CreatedAt, &resource.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan resource: %w", err)
+		}
+		resources = append(resources, &resource)
+	}
+
+	return resources, nil
+}
+
+func (s *resourceStorage) Update(ctx context.Context, resource *entity.Resource) error {
+	const query = `
+		UPDATE resources
+		SET name = $2, type = $3, capacity = $4, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL;
+	`
+
+	result, err := s.pg.DB.ExecContext(ctx, query, resource.ID, resource.Name, resource.Type, resource.Capacity)
+	if err != nil {
+		return fmt.Errorf("failed to update resource: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("resource not found")
+	}
+
+	return nil
+}
+
+func (s *resourceStorage) Delete(ctx context.Context, id uuid.UUID) error {
+	const query = `
+		UPDATE resources
+		SET deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL;
+	`
+
+	result, err := s.pg.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete resource: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("resource not found")
+	}
+
+	return nil
+}
+
+// HasUpcomingAllocations reports whether active appointments that have not
+// ended yet hold a unit of the resource numbered minUnit or higher.
+func (s *resourceStorage) HasUpcomingAllocations(ctx context.Context, id uuid.UUID, minUnit int) (bool, error) {
+	const query = `
+		SELECT EXISTS (
+			SELECT 1
+			FROM appointment_resources
+			WHERE resource_id = $1 AND unit >= $2 AND active AND ends_at > NOW()
+		);
+	`
+
+	var exists bool
+	if err := s.pg.DB.QueryRowContext(ctx, query, id, minUnit).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check resource allocations: %w", err)
+	}
+
+	return exists, nil
+}
+
+func (s *resourceStorage) GetServiceTypes(ctx context.Context, serviceID uuid.UUID) ([]string, error) {
+	return s.GetTypesByServiceIds(ctx, []uuid.UUID{serviceID})
+}
+
+func (s *resourceStorage) SetServiceTypes(ctx context.Context, serviceID uuid.UUID, types []string) error {
+	tx, err := s.pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	const deleteQuery = `
+		DELETE FROM service_resource_types
+		WHERE service_id = $1;
+	`
+
+	if _, err := tx.ExecContext(ctx, deleteQuery, serviceID); err != nil {
+		return fmt.Errorf("failed to delete service resource types: %w", err)
+	}
+
+	const insertQuery = `
+		INSERT INTO service_resource_types (service_id, resource_type)
+		SELECT $1, unnest($2::TEXT[]);
+	`
+
+	if _, err := tx.ExecContext(ctx, insertQuery, serviceID, pq.Array(types)); err != nil {
+		return fmt.Errorf("failed to insert service resource types: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetTypesByServiceIds returns the distinct resource types needed by any of the services.
+func (s *resourceStorage) GetTypesByServiceIds(ctx context.Context, serviceIDs []uuid.UUID) ([]string, error) {
+	const query = `
+		SELECT DISTINCT resource_type
+		FROM service_resource_types
+		WHERE service_id = ANY($1)
+		ORDER BY resource_type;
+	`
+
+	rows, err := s.pg.DB.QueryContext(ctx, query, pq.Array(serviceIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query service resource types: %w", err)
+	}
+	defer rows.Close()
+
+	types := []string{}
+	for rows.Next() {
+		var resourceType string
+		if err := rows.Scan(&resourceType); err != nil {
+			return nil, fmt.Errorf("failed to scan service resource type: %w", err)
+		}
+		types = append(types, resourceType)
+	}
+
+	return types, nil
+}
@@ -0,0 +1,59 @@
+package storages
+
+import (
+	"backend-service/pkg/database"
+	"backend-service/pkg/jwt"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	revokedTokenKeyPrefix    = "revoked_token:"
+	tokenGenerationKeyPrefix = "token_generation:"
+)
+
+type revocationStorage struct {
+	redis *database.Redis
+}
+
+func NewRevocationStorage(deps StorageDeps) jwt.RevocationStorage {
+	return &revocationStorage{
+		redis: deps.Redis,
+	}
+}
+
+func (s *revocationStorage) Revoke(tokenId string, ttl time.Duration) error {
+	if err := s.redis.Client.Set(revokedTokenKeyPrefix+tokenId, 1, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+func (s *revocationStorage) IsRevoked(tokenId string) (bool, error) {
+	n, err := s.redis.Client.Exists(revokedTokenKeyPrefix + tokenId).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check revoked token: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (s *revocationStorage) Generation(userId string) (int64, error) {
+	generation, err := s.redis.Client.Get(tokenGenerationKeyPrefix + userId).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get token generation: %w", err)
+	}
+	return generation, nil
+}
+
+func (s *revocationStorage) IncrGeneration(userId string) (int64, error) {
+	generation, err := s.redis.Client.Incr(tokenGenerationKeyPrefix + userId).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment token generation: %w", err)
+	}
+	return generation, nil
+}
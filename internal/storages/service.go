@@ -4,13 +4,14 @@ import (
 	"backend-service/internal/entity"
 	"backend-service/pkg/database"
 	"context"
+	"fmt"
 	"github.com/google/uuid"
 )
 
 type ServiceRepository interface {
 	Create(ctx context.Context, service *entity.Service) (uuid.UUID, error)
 	GetById(ctx context.Context, id uuid.UUID) (*entity.Service, error)
-	GetAll(ctx context.Context) ([]*entity.Service, error)
+	GetAll(ctx context.Context, locationID *uuid.UUID) ([]*entity.Service, error)
 	Update(ctx context.Context, service *entity.Service) (uuid.UUID, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 }
@@ -25,31 +26,52 @@ func NewServiceStorage(deps StorageDeps) ServiceRepository {
 	}
 }
 
+// Create inserts the service with the default resource type, so a new
+// service never books without limit before its resources are configured.
 func (s *serviceStorage) Create(ctx context.Context, service *entity.Service) (uuid.UUID, error) {
+	tx, err := s.pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	if service.ID == uuid.Nil {
 		service.ID = uuid.New()
 	}
 
 	const query = `
-		INSERT INTO services (id, name, description, price, duration_min)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO services (id, location_id, name, description, price, duration_min, category)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id;
 	`
 
-	row := s.pg.DB.QueryRowContext(ctx, query,
-		service.ID, service.Name, service.Description, service.Price, service.DurationMin,
+	row := tx.QueryRowContext(ctx, query,
+		service.ID, service.LocationID, service.Name, service.Description, service.Price, service.DurationMin, service.Category,
 	)
 
 	if err := row.Scan(&service.ID); err != nil {
 		return uuid.Nil, err
 	}
 
+	const typesQuery = `
+		INSERT INTO service_resource_types (service_id, resource_type)
+		VALUES ($1, $2);
+	`
+
+	if _, err := tx.ExecContext(ctx, typesQuery, service.ID, entity.DefaultResourceType); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to insert service resource type: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return service.ID, nil
 }
 
 func (s *serviceStorage) GetById(ctx context.Context, id uuid.UUID) (*entity.Service, error) {
 	const query = `
-		SELECT id, name, description, price, duration_min
+		SELECT id, location_id, name, description, price, duration_min, category
 		FROM services
 		WHERE id = $1 AND deleted_at IS NULL;
 	`
@@ -57,21 +79,22 @@ func (s *serviceStorage) GetById(ctx context.Context, id uuid.UUID) (*entity.Ser
 	row := s.pg.DB.QueryRowContext(ctx, query, id)
 
 	var service entity.Service
-	if err := row.Scan(&service.ID, &service.Name, &service.Description, &service.Price, &service.DurationMin); err != nil {
+	if err := row.Scan(&service.ID, &service.LocationID, &service.Name, &service.Description, &service.Price, &service.DurationMin, &service.Category); err != nil {
 		return nil, err
 	}
 
 	return &service, nil
 }
 
-func (s *serviceStorage) GetAll(ctx context.Context) ([]*entity.Service, error) {
+// GetAll returns the services of the location, or of every location when it is nil.
+func (s *serviceStorage) GetAll(ctx context.Context, locationID *uuid.UUID) ([]*entity.Service, error) {
 	const query = `
-		SELECT id, name, description, price, duration_min
+		SELECT id, location_id, name, description, price, duration_min, category
 		FROM services
-		WHERE deleted_at IS NULL;
+		WHERE ($1::UUID IS NULL OR location_id = $1) AND deleted_at IS NULL;
 	`
 
-	rows, err := s.pg.DB.QueryContext(ctx, query)
+	rows, err := s.pg.DB.QueryContext(ctx, query, locationID)
 	if err != nil {
 		return nil, err
 	}
@@ -80,7 +103,7 @@ func (s *serviceStorage) GetAll(ctx context.Context) ([]*entity.Service, error)
 	var services []*entity.Service
 	for rows.Next() {
 		var service entity.Service
-		if err := rows.Scan(&service.ID, &service.Name, &service.Description, &service.Price, &service.DurationMin); err != nil {
+		if err := rows.Scan(&service.ID, &service.LocationID, &service.Name, &service.Description, &service.Price, &service.DurationMin, &service.Category); err != nil {
 			return nil, err
 		}
 		services = append(services, &service)
@@ -92,13 +115,13 @@ func (s *serviceStorage) GetAll(ctx context.Context) ([]*entity.Service, error)
 func (s *serviceStorage) Update(ctx context.Context, service *entity.Service) (uuid.UUID, error) {
 	const query = `
 		UPDATE services
-		SET name = $2, description = $3, price = $4, duration_min = $5
+		SET name = $2, description = $3, price = $4, duration_min = $5, category = $6
 		WHERE id = $1 AND deleted_at IS NULL
 		RETURNING id;
 	`
 
 	row := s.pg.DB.QueryRowContext(ctx, query,
-		service.ID, service.Name, service.Description, service.Price, service.DurationMin,
+		service.ID, service.Name, service.Description, service.Price, service.DurationMin, service.Category,
 	)
 
 	if err := row.Scan(&service.ID); err != nil {
@@ -0,0 +1,132 @@
+package storages
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/pkg/database"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *entity.Session) (uuid.UUID, error)
+	GetActiveByUserId(ctx context.Context, userId uuid.UUID) ([]*entity.Session, error)
+	Touch(ctx context.Context, id uuid.UUID, ip string, expiresAt time.Time) error
+	Revoke(ctx context.Context, id, userId uuid.UUID) error
+	RevokeAllByUserId(ctx context.Context, userId uuid.UUID) error
+}
+
+type sessionStorage struct {
+	pg *database.PostgresDB
+}
+
+func NewSessionStorage(deps StorageDeps) SessionRepository {
+	return &sessionStorage{
+		pg: deps.PostgresDB,
+	}
+}
+
+func (s *sessionStorage) Create(ctx context.Context, session *entity.Session) (uuid.UUID, error) {
+	if session.ID == uuid.Nil {
+		session.ID = uuid.New()
+	}
+
+	const query = `
+		INSERT INTO sessions (id, user_id, device_name, ip, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id;
+	`
+
+	row := s.pg.DB.QueryRowContext(ctx, query,
+		session.ID, session.UserID, session.DeviceName, session.IP, session.UserAgent, session.ExpiresAt,
+	)
+
+	if err := row.Scan(&session.ID); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to insert session: %w", err)
+	}
+
+	return session.ID, nil
+}
+
+func (s *sessionStorage) GetActiveByUserId(ctx context.Context, userId uuid.UUID) ([]*entity.Session, error) {
+	const query = `
+		SELECT id, user_id, COALESCE(device_name, ''), COALESCE(ip, ''), COALESCE(user_agent, ''),
+			created_at, last_used_at, expires_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_used_at DESC;
+	`
+
+	rows, err := s.pg.DB.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*entity.Session
+	for rows.Next() {
+		var session entity.Session
+		if err := rows.Scan(
+			&session.ID, &session.UserID, &session.DeviceName, &session.IP, &session.UserAgent,
+			&session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, &session)
+	}
+
+	return sessions, nil
+}
+
+func (s *sessionStorage) Touch(ctx context.Context, id uuid.UUID, ip string, expiresAt time.Time) error {
+	const query = `
+		UPDATE sessions
+		SET last_used_at = NOW(), ip = $2, expires_at = $3
+		WHERE id = $1 AND revoked_at IS NULL;
+	`
+
+	if _, err := s.pg.DB.ExecContext(ctx, query, id, ip, expiresAt); err != nil {
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+
+	return nil
+}
+
+func (s *sessionStorage) Revoke(ctx context.Context, id, userId uuid.UUID) error {
+	const query = `
+		UPDATE sessions
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+	`
+
+	result, err := s.pg.DB.ExecContext(ctx, query, id, userId)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("session not found")
+	}
+
+	return nil
+}
+
+func (s *sessionStorage) RevokeAllByUserId(ctx context.Context, userId uuid.UUID) error {
+	const query = `
+		UPDATE sessions
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL;
+	`
+
+	if _, err := s.pg.DB.ExecContext(ctx, query, userId); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	return nil
+}
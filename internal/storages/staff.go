@@ -0,0 +1,247 @@
+package storages
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/pkg/database"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"time"
+)
+
+type StaffRepository interface {
+	GetAll(ctx context.Context, locationID *uuid.UUID) ([]*entity.StaffProfile, error)
+	GetActive(ctx context.Context, locationID uuid.UUID) ([]*entity.StaffProfile, error)
+	GetById(ctx context.Context, userID uuid.UUID) (*entity.StaffProfile, error)
+	Upsert(ctx context.Context, userID uuid.UUID, input *entity.StaffProfileUpdate) error
+	SetSchedule(ctx context.Context, userID uuid.UUID, days []entity.WorkingHours) error
+	CreateException(ctx context.Context, exception *entity.ScheduleException) (uuid.UUID, error)
+	GetExceptions(ctx context.Context, userID uuid.UUID) ([]*entity.ScheduleException, error)
+	GetExceptionsInRange(ctx context.Context, from, to time.Time) ([]*entity.ScheduleException, error)
+	DeleteException(ctx context.Context, userID, exceptionID uuid.UUID) error
+}
+
+type staffStorage struct {
+	pg *database.PostgresDB
+}
+
+func NewStaffStorage(deps StorageDeps) StaffRepository {
+	return &staffStorage{
+		pg: deps.PostgresDB,
+	}
+}
+
+// staffProfileQuery selects profiles with their weekly schedule, callers add WHERE and ORDER BY.
+const staffProfileQuery = `
+	SELECT
+		p.user_id, u.full_name, u.role, u.location_id, p.skills, p.active, p.created_at, p.updated_at,
+		COALESCE((
+			SELECT json_agg(json_build_object(
+				'weekday', w.weekday,
+				'start_time', to_char(w.start_time, 'HH24:MI'),
+				'end_time', to_char(w.end_time, 'HH24:MI')
+			) ORDER BY w.weekday)
+			FROM staff_working_hours w
+			WHERE w.user_id = p.user_id
+		), '[]') AS schedule
+	FROM staff_profiles p
+	JOIN users u ON u.id = p.user_id AND u.deleted_at IS NULL
+`
+
+// GetAll returns the staff of the location, or of every location when it is nil.
+func (s *staffStorage) GetAll(ctx context.Context, locationID *uuid.UUID) ([]*entity.StaffProfile, error) {
+	return s.query(ctx, staffProfileQuery+` WHERE ($1::UUID IS NULL OR u.location_id = $1) ORDER BY u.full_name;`, locationID)
+}
+
+// GetActive returns the active staff working at the location.
+func (s *staffStorage) GetActive(ctx context.Context, locationID uuid.UUID) ([]*entity.StaffProfile, error) {
+	return s.query(ctx, staffProfileQuery+` WHERE p.active AND u.location_id = $1 ORDER BY u.full_name;`, locationID)
+}
+
+func (s *staffStorage) GetById(ctx context.Context, userID uuid.UUID) (*entity.StaffProfile, error) {
+	profiles, err := s.query(ctx, staffProfileQuery+` WHERE p.user_id = $1;`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(profiles) == 0 {
+		return nil, fmt.Errorf("staff profile not found")
+	}
+
+	return profiles[0], nil
+}
+
+func (s *staffStorage) query(ctx context.Context, query string, args ...interface{}) ([]*entity.StaffProfile, error) {
+	rows, err := s.pg.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query staff profiles: %w", err)
+	}
+	defer rows.Close()
+
+	var profiles []*entity.StaffProfile
+	for rows.Next() {
+		var profile entity.StaffProfile
+		var scheduleJSON []byte
+		if err := rows.Scan(
+			&profile.UserID, &profile.FullName, &profile.Role, &profile.LocationID, pq.Array(&profile.Skills), &profile.Active,
+			&profile.CreatedAt, &profile.UpdatedAt, &scheduleJSON,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan staff profile: %w", err)
+		}
+		if err := json.Unmarshal(scheduleJSON, &profile.Schedule); err != nil {
+			return nil, fmt.Errorf("failed to decode staff schedule: %w", err)
+		}
+		profiles = append(profiles, &profile)
+	}
+
+	return profiles, nil
+}
+
+// Upsert creates the profile or updates it. Active is left unchanged when not given.
+func (s *staffStorage) Upsert(ctx context.Context, userID uuid.UUID, input *entity.StaffProfileUpdate) error {
+	const query = `
+		INSERT INTO staff_profiles (user_id, skills, active)
+		VALUES ($1, $2, COALESCE($3, TRUE))
+		ON CONFLICT (user_id) DO UPDATE
+		SET skills = EXCLUDED.skills,
+			active = COALESCE($3, staff_profiles.active),
+			updated_at = NOW();
+	`
+
+	skills := input.Skills
+	if skills == nil {
+		skills = []string{}
+	}
+
+	if _, err := s.pg.DB.ExecContext(ctx, query, userID, pq.Array(skills), input.Active); err != nil {
+		return fmt.Errorf("failed to save staff profile: %w", err)
+	}
+
+	return nil
+}
+
+// SetSchedule replaces the whole weekly schedule.
+func (s *staffStorage) SetSchedule(ctx context.Context, userID uuid.UUID, days []entity.WorkingHours) error {
+	tx, err := s.pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	const deleteQuery = `
+		DELETE FROM staff_working_hours
+		WHERE user_id = $1;
+	`
+
+	if _, err := tx.ExecContext(ctx, deleteQuery, userID); err != nil {
+		return fmt.Errorf("failed to delete working hours: %w", err)
+	}
+
+	const insertQuery = `
+		INSERT INTO staff_working_hours (user_id, weekday, start_time, end_time)
+		VALUES ($1, $2, $3::TIME, $4::TIME);
+	`
+
+	for _, day := range days {
+		if _, err := tx.ExecContext(ctx, insertQuery, userID, int(day.Weekday), day.StartTime, day.EndTime); err != nil {
+			return fmt.Errorf("failed to insert working hours: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *staffStorage) CreateException(ctx context.Context, exception *entity.ScheduleException) (uuid.UUID, error) {
+	if exception.ID == uuid.Nil {
+		exception.ID = uuid.New()
+	}
+
+	const query = `
+		INSERT INTO staff_schedule_exceptions (id, user_id, kind, starts_on, ends_on, note)
+		VALUES ($1, $2, $3, $4::DATE, $5::DATE, $6)
+		RETURNING id;
+	`
+
+	row := s.pg.DB.QueryRowContext(ctx, query,
+		exception.ID, exception.UserID, exception.Kind, exception.StartsOn, exception.EndsOn, exception.Note,
+	)
+	if err := row.Scan(&exception.ID); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to insert schedule exception: %w", err)
+	}
+
+	return exception.ID, nil
+}
+
+func (s *staffStorage) GetExceptions(ctx context.Context, userID uuid.UUID) ([]*entity.ScheduleException, error) {
+	const query = `
+		SELECT id, user_id, kind, to_char(starts_on, 'YYYY-MM-DD'), to_char(ends_on, 'YYYY-MM-DD'), note, created_at
+		FROM staff_schedule_exceptions
+		WHERE user_id = $1
+		ORDER BY starts_on DESC;
+	`
+
+	return s.queryExceptions(ctx, query, userID)
+}
+
+// GetExceptionsInRange returns the absences of all staff that touch the days of [from, to).
+func (s *staffStorage) GetExceptionsInRange(ctx context.Context, from, to time.Time) ([]*entity.ScheduleException, error) {
+	const query = `
+		SELECT id, user_id, kind, to_char(starts_on, 'YYYY-MM-DD'), to_char(ends_on, 'YYYY-MM-DD'), note, created_at
+		FROM staff_schedule_exceptions
+		WHERE ends_on >= $1::DATE AND starts_on <= $2::DATE
+		ORDER BY starts_on;
+	`
+
+	return s.queryExceptions(ctx, query, from.Format("2006-01-02"), to.Format("2006-01-02"))
+}
+
+func (s *staffStorage) queryExceptions(ctx context.Context, query string, args ...interface{}) ([]*entity.ScheduleException, error) {
+	rows, err := s.pg.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schedule exceptions: %w", err)
+	}
+	defer rows.Close()
+
+	var exceptions []*entity.ScheduleException
+	for rows.Next() {
+		var exception entity.ScheduleException
+		if err := rows.Scan(
+			&exception.ID, &exception.UserID, &exception.Kind, &exception.StartsOn, &exception.EndsOn,
+			&exception.Note, &exception.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan schedule exception: %w", err)
+		}
+		exceptions = append(exceptions, &exception)
+	}
+
+	return exceptions, nil
+}
+
+func (s *staffStorage) DeleteException(ctx context.Context, userID, exceptionID uuid.UUID) error {
+	const query = `
+		DELETE FROM staff_schedule_exceptions
+		WHERE id = $1 AND user_id = $2;
+	`
+
+	result, err := s.pg.DB.ExecContext(ctx, query, exceptionID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete schedule exception: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("schedule exception not found")
+	}
+
+	return nil
+}
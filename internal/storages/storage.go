@@ -2,26 +2,65 @@ package storages
 
 import (
 	"backend-service/pkg/database"
+	"backend-service/pkg/jwt"
+	"errors"
 	"github.com/rs/zerolog"
+	"time"
 )
 
+// ErrNotFound is wrapped by the "... not found" errors of a missing row, so
+// callers can tell it apart from a database failure.
+var ErrNotFound = errors.New("not found")
+
 type Storage struct {
-	UserRepository        UserRepository
-	ServiceRepository     ServiceRepository
-	VehicleRepository     VehicleRepository
-	AppointmentRepository AppointmentRepository
+	UserRepository               UserRepository
+	ServiceRepository            ServiceRepository
+	ResourceRepository           ResourceRepository
+	StaffRepository              StaffRepository
+	CalendarRepository           CalendarRepository
+	LocationRepository           LocationRepository
+	WaitlistRepository           WaitlistRepository
+	VehicleRepository            VehicleRepository
+	AppointmentRepository        AppointmentRepository
+	WorkOrderRepository          WorkOrderRepository
+	SessionRepository            SessionRepository
+	ImpersonationAuditRepository ImpersonationAuditRepository
+	APIKeyRepository             APIKeyRepository
+	OneTimeCodeRepository        OneTimeCodeRepository
+	LoginAttemptRepository       LoginAttemptRepository
+	RecoveryCodeRepository       RecoveryCodeRepository
+	TwoFactorChallengeRepository TwoFactorChallengeRepository
+	NonceStorage                 jwt.NonceStorage
+	RevocationStorage            jwt.RevocationStorage
 }
 
 type StorageDeps struct {
 	PostgresDB *database.PostgresDB
+	Redis      *database.Redis
+	NonceTTL   time.Duration
 	Log        zerolog.Logger
 }
 
 func NewStorage(deps StorageDeps) *Storage {
 	return &Storage{
-		UserRepository:        NewUserStorage(deps),
-		ServiceRepository:     NewServiceStorage(deps),
-		VehicleRepository:     NewVehicleStorage(deps),
-		AppointmentRepository: NewAppointmentStorage(deps),
+		UserRepository:               NewUserStorage(deps),
+		ServiceRepository:            NewServiceStorage(deps),
+		ResourceRepository:           NewResourceStorage(deps),
+		StaffRepository:              NewStaffStorage(deps),
+		CalendarRepository:           NewCalendarStorage(deps),
+		LocationRepository:           NewLocationStorage(deps),
+		WaitlistRepository:           NewWaitlistStorage(deps),
+		VehicleRepository:            NewVehicleStorage(deps),
+		AppointmentRepository:        NewAppointmentStorage(deps),
+		WorkOrderRepository:          NewWorkOrderStorage(deps),
+		SessionRepository:            NewSessionStorage(deps),
+		ImpersonationAuditRepository: NewImpersonationAuditStorage(deps),
+		APIKeyRepository:             NewAPIKeyStorage(deps),
+		OneTimeCodeRepository:        NewOneTimeCodeStorage(deps),
+		LoginAttemptRepository:       NewLoginAttemptStorage(deps),
+		RecoveryCodeRepository:       NewRecoveryCodeStorage(deps),
+		TwoFactorChallengeRepository: NewTwoFactorChallengeStorage(deps),
+		NonceStorage:                 NewNonceStorage(deps),
+		RevocationStorage:            NewRevocationStorage(deps),
 	}
 }
@@ -0,0 +1,86 @@
+package storages
+
+import (
+	"backend-service/pkg/database"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/google/uuid"
+)
+
+const twoFactorChallengeKeyPrefix = "2fa_challenge:"
+
+// attemptChallengeScript counts an attempt to complete the challenge and
+// drops it once attempts are exhausted. Returns the user id or nil.
+var attemptChallengeScript = redis.NewScript(`
+local userId = redis.call('HGET', KEYS[1], 'user_id')
+if not userId then
+	return false
+end
+local attempts = redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+if attempts > tonumber(ARGV[1]) then
+	redis.call('DEL', KEYS[1])
+	return false
+end
+return userId
+`)
+
+// TwoFactorChallengeRepository keeps logins that passed the password check
+// and wait for the second factor.
+type TwoFactorChallengeRepository interface {
+	Create(ctx context.Context, token string, userId uuid.UUID, ttl time.Duration) error
+	Attempt(ctx context.Context, token string, maxAttempts int) (uuid.UUID, error)
+	Delete(ctx context.Context, token string) error
+}
+
+type twoFactorChallengeStorage struct {
+	redis *database.Redis
+}
+
+func NewTwoFactorChallengeStorage(deps StorageDeps) TwoFactorChallengeRepository {
+	return &twoFactorChallengeStorage{
+		redis: deps.Redis,
+	}
+}
+
+func (s *twoFactorChallengeStorage) Create(ctx context.Context, token string, userId uuid.UUID, ttl time.Duration) error {
+	key := twoFactorChallengeKeyPrefix + hashCode(token)
+	client := s.redis.Client.WithContext(ctx)
+
+	pipe := client.TxPipeline()
+	pipe.HSet(key, "user_id", userId.String())
+	pipe.HSet(key, "attempts", 0)
+	pipe.Expire(key, ttl)
+	if _, err := pipe.Exec(); err != nil {
+		return fmt.Errorf("failed to save two factor challenge: %w", err)
+	}
+
+	return nil
+}
+
+func (s *twoFactorChallengeStorage) Attempt(ctx context.Context, token string, maxAttempts int) (uuid.UUID, error) {
+	key := twoFactorChallengeKeyPrefix + hashCode(token)
+	client := s.redis.Client.WithContext(ctx)
+
+	res, err := attemptChallengeScript.Run(client, []string{key}, maxAttempts).String()
+	if err == redis.Nil {
+		return uuid.Nil, fmt.Errorf("two factor challenge not found")
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to check two factor challenge: %w", err)
+	}
+
+	return uuid.Parse(res)
+}
+
+func (s *twoFactorChallengeStorage) Delete(ctx context.Context, token string) error {
+	key := twoFactorChallengeKeyPrefix + hashCode(token)
+
+	if err := s.redis.Client.WithContext(ctx).Del(key).Err(); err != nil {
+		return fmt.Errorf("failed to delete two factor challenge: %w", err)
+	}
+
+	return nil
+}
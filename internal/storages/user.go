@@ -4,15 +4,54 @@ import (
 	"backend-service/internal/entity"
 	"backend-service/pkg/database"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+var (
+	// ErrUserEmailTaken is returned when the users_email_key constraint is violated.
+	ErrUserEmailTaken = errors.New("email is already in use")
+	// ErrUserPhoneTaken is returned when the users_phone_key constraint is violated.
+	ErrUserPhoneTaken = errors.New("phone is already in use")
+)
+
+// uniqueViolation is the PostgreSQL error code of a violated UNIQUE constraint.
+const uniqueViolation = "23505"
+
+// wrapContactError turns a unique violation on the user's email or phone
+// into ErrUserEmailTaken or ErrUserPhoneTaken.
+func wrapContactError(err error, msg string) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		switch pqErr.Constraint {
+		case "users_email_key":
+			return ErrUserEmailTaken
+		case "users_phone_key":
+			return ErrUserPhoneTaken
+		}
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
 type UserRepository interface {
 	Create(ctx context.Context, user *entity.User) (userID uuid.UUID, err error)
 	GetById(ctx context.Context, id uuid.UUID) (user *entity.User, err error)
 	GetByEmail(ctx context.Context, email string) (user *entity.User, err error)
+	GetByPhone(ctx context.Context, phone string) (user *entity.User, err error)
 	GetAllClients(ctx context.Context) ([]*entity.User, error)
+	UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error
+	UpdateRole(ctx context.Context, id uuid.UUID, role entity.Role) error
+	ResetNoShows(ctx context.Context, id uuid.UUID, locationID *uuid.UUID) error
+	UpdateLocation(ctx context.Context, id uuid.UUID, locationID *uuid.UUID) error
+	MarkVerified(ctx context.Context, id uuid.UUID, channel entity.VerificationChannel) error
+	UpdateProfile(ctx context.Context, user *entity.User) error
+	Anonymize(ctx context.Context, id uuid.UUID) error
+	SetTOTPSecret(ctx context.Context, id uuid.UUID, secret string) error
+	EnableTOTP(ctx context.Context, id uuid.UUID) error
+	DisableTOTP(ctx context.Context, id uuid.UUID) error
+	UseTOTPStep(ctx context.Context, id uuid.UUID, step int64) (bool, error)
 }
 
 type userStorage struct {
@@ -31,13 +70,13 @@ func (s *userStorage) Create(ctx context.Context, user *entity.User) (uuid.UUID,
 	}
 
 	const query = `
-		INSERT INTO users (id, full_name, phone, email, password_hash, is_admin)
+		INSERT INTO users (id, full_name, phone, email, password_hash, role)
 		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id;
 	`
 
 	row := s.pg.DB.QueryRowContext(ctx, query,
-		user.ID, user.FullName, user.Phone, user.Email, user.PasswordHash, user.IsAdmin,
+		user.ID, user.FullName, user.Phone, user.Email, user.PasswordHash, user.Role,
 	)
 
 	if err := row.Scan(&user.ID); err != nil {
@@ -49,7 +88,9 @@ func (s *userStorage) Create(ctx context.Context, user *entity.User) (uuid.UUID,
 
 func (s *userStorage) GetById(ctx context.Context, id uuid.UUID) (*entity.User, error) {
 	const query = `
-		SELECT id, full_name, phone, email, password_hash, is_admin, created_at, updated_at
+		SELECT id, full_name, phone, email, password_hash, role,
+			email_verified_at, phone_verified_at, COALESCE(totp_secret, ''), totp_enabled_at,
+			no_show_count, location_id, created_at, updated_at
 		FROM users
 		WHERE id = $1 AND deleted_at IS NULL;
 	`
@@ -59,7 +100,8 @@ func (s *userStorage) GetById(ctx context.Context, id uuid.UUID) (*entity.User,
 	var user entity.User
 	if err := row.Scan(
 		&user.ID, &user.FullName, &user.Phone, &user.Email,
-		&user.PasswordHash, &user.IsAdmin, &user.CreatedAt, &user.UpdatedAt,
+		&user.PasswordHash, &user.Role, &user.EmailVerifiedAt, &user.PhoneVerifiedAt,
+		&user.TOTPSecret, &user.TOTPEnabledAt, &user.NoShowCount, &user.LocationID, &user.CreatedAt, &user.UpdatedAt,
 	); err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
@@ -69,7 +111,7 @@ func (s *userStorage) GetById(ctx context.Context, id uuid.UUID) (*entity.User,
 
 func (s *userStorage) GetByEmail(ctx context.Context, email string) (*entity.User, error) {
 	const query = `
-		SELECT id, full_name, phone, email, password_hash, is_admin
+		SELECT id, full_name, phone, email, password_hash, role, totp_enabled_at
 		FROM users
 		WHERE email = $1 AND deleted_at IS NULL;
 	`
@@ -77,7 +119,28 @@ func (s *userStorage) GetByEmail(ctx context.Context, email string) (*entity.Use
 	row := s.pg.DB.QueryRowContext(ctx, query, email)
 
 	var user entity.User
-	if err := row.Scan(&user.ID, &user.FullName, &user.Phone, &user.Email, &user.PasswordHash, &user.IsAdmin); err != nil {
+	if err := row.Scan(
+		&user.ID, &user.FullName, &user.Phone, &user.Email, &user.PasswordHash, &user.Role, &user.TOTPEnabledAt,
+	); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (s *userStorage) GetByPhone(ctx context.Context, phone string) (*entity.User, error) {
+	const query = `
+		SELECT id, full_name, phone, email, password_hash, role, totp_enabled_at
+		FROM users
+		WHERE phone = $1 AND deleted_at IS NULL;
+	`
+
+	row := s.pg.DB.QueryRowContext(ctx, query, phone)
+
+	var user entity.User
+	if err := row.Scan(
+		&user.ID, &user.FullName, &user.Phone, &user.Email, &user.PasswordHash, &user.Role, &user.TOTPEnabledAt,
+	); err != nil {
 		return nil, err
 	}
 
@@ -86,7 +149,7 @@ func (s *userStorage) GetByEmail(ctx context.Context, email string) (*entity.Use
 
 func (s *userStorage) GetAllClients(ctx context.Context) ([]*entity.User, error) {
 	const query = `
-		SELECT id, full_name, phone, email, is_admin, created_at, updated_at
+		SELECT id, full_name, phone, email, role, no_show_count, created_at, updated_at
 		FROM users
 		WHERE deleted_at IS NULL;
 	`
@@ -101,7 +164,7 @@ func (s *userStorage) GetAllClients(ctx context.Context) ([]*entity.User, error)
 		var user entity.User
 		if err := rows.Scan(
 			&user.ID, &user.FullName, &user.Phone, &user.Email,
-			&user.IsAdmin, &user.CreatedAt, &user.UpdatedAt,
+			&user.Role, &user.NoShowCount, &user.CreatedAt, &user.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -109,3 +172,272 @@ func (s *userStorage) GetAllClients(ctx context.Context) ([]*entity.User, error)
 	}
 	return users, nil
 }
+
+func (s *userStorage) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	const query = `
+		UPDATE users
+		SET password_hash = $2, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL;
+	`
+
+	result, err := s.pg.DB.ExecContext(ctx, query, id, passwordHash)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+func (s *userStorage) UpdateRole(ctx context.Context, id uuid.UUID, role entity.Role) error {
+	const query = `
+		UPDATE users
+		SET role = $2, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL;
+	`
+
+	result, err := s.pg.DB.ExecContext(ctx, query, id, role)
+	if err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+func (s *userStorage) UpdateLocation(ctx context.Context, id uuid.UUID, locationID *uuid.UUID) error {
+	const query = `
+		UPDATE users
+		SET location_id = $2, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL;
+	`
+
+	result, err := s.pg.DB.ExecContext(ctx, query, id, locationID)
+	if err != nil {
+		return fmt.Errorf("failed to update location: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// ResetNoShows clears the no-show counter, lifting the booking restrictions
+// it brought on the client. With a location only a client who missed an
+// appointment there is reset.
+func (s *userStorage) ResetNoShows(ctx context.Context, id uuid.UUID, locationID *uuid.UUID) error {
+	const query = `
+		UPDATE users
+		SET no_show_count = 0, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+			AND ($2::uuid IS NULL OR EXISTS (
+				SELECT 1
+				FROM appointments a
+				WHERE a.user_id = users.id AND a.location_id = $2
+					AND a.status = 'no_show' AND a.deleted_at IS NULL
+			));
+	`
+
+	result, err := s.pg.DB.ExecContext(ctx, query, id, locationID)
+	if err != nil {
+		return fmt.Errorf("failed to reset no-shows: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("user %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *userStorage) MarkVerified(ctx context.Context, id uuid.UUID, channel entity.VerificationChannel) error {
+	var query string
+	switch channel {
+	case entity.VerificationChannelEmail:
+		query = `
+			UPDATE users
+			SET email_verified_at = NOW(), updated_at = NOW()
+			WHERE id = $1 AND deleted_at IS NULL;
+		`
+	case entity.VerificationChannelPhone:
+		query = `
+			UPDATE users
+			SET phone_verified_at = NOW(), updated_at = NOW()
+			WHERE id = $1 AND deleted_at IS NULL;
+		`
+	default:
+		return fmt.Errorf("unknown verification channel: %s", channel)
+	}
+
+	result, err := s.pg.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark contact verified: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+func (s *userStorage) UpdateProfile(ctx context.Context, user *entity.User) error {
+	const query = `
+		UPDATE users
+		SET full_name = $2, phone = $3, email = $4,
+			email_verified_at = $5, phone_verified_at = $6, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL;
+	`
+
+	result, err := s.pg.DB.ExecContext(ctx, query,
+		user.ID, user.FullName, user.Phone, user.Email,
+		user.EmailVerifiedAt, user.PhoneVerifiedAt,
+	)
+	if err != nil {
+		return wrapContactError(err, "failed to update profile")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// Anonymize soft-deletes the user and wipes personal data. The phone is
+// replaced with a unique placeholder because the column is NOT NULL UNIQUE.
+func (s *userStorage) Anonymize(ctx context.Context, id uuid.UUID) error {
+	const query = `
+		UPDATE users
+		SET full_name = 'Deleted user', phone = 'deleted:' || id::text, email = NULL,
+			password_hash = '', email_verified_at = NULL, phone_verified_at = NULL,
+			totp_secret = NULL, totp_enabled_at = NULL, updated_at = NOW(), deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL;
+	`
+
+	result, err := s.pg.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// SetTOTPSecret stores a pending secret. TOTP stays disabled until EnableTOTP
+// is called after the user proves the authenticator app produces valid codes.
+func (s *userStorage) SetTOTPSecret(ctx context.Context, id uuid.UUID, secret string) error {
+	const query = `
+		UPDATE users
+		SET totp_secret = $2, totp_enabled_at = NULL, totp_last_step = 0, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL;
+	`
+
+	return s.execTOTPUpdate(ctx, query, id, secret)
+}
+
+func (s *userStorage) EnableTOTP(ctx context.Context, id uuid.UUID) error {
+	const query = `
+		UPDATE users
+		SET totp_enabled_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND totp_secret IS NOT NULL AND deleted_at IS NULL;
+	`
+
+	return s.execTOTPUpdate(ctx, query, id)
+}
+
+func (s *userStorage) DisableTOTP(ctx context.Context, id uuid.UUID) error {
+	const query = `
+		UPDATE users
+		SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL;
+	`
+
+	return s.execTOTPUpdate(ctx, query, id)
+}
+
+// UseTOTPStep atomically records the accepted time step. It returns false if
+// this or a later step was already used, so a code cannot be replayed.
+func (s *userStorage) UseTOTPStep(ctx context.Context, id uuid.UUID, step int64) (bool, error) {
+	const query = `
+		UPDATE users
+		SET totp_last_step = $2
+		WHERE id = $1 AND totp_last_step < $2 AND deleted_at IS NULL;
+	`
+
+	result, err := s.pg.DB.ExecContext(ctx, query, id, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to use totp step: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+func (s *userStorage) execTOTPUpdate(ctx context.Context, query string, args ...interface{}) error {
+	result, err := s.pg.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update totp: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
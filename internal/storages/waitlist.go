@@ -0,0 +1,495 @@
+package storages
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/pkg/database"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"time"
+)
+
+var (
+	// ErrWaitlistEntryTaken is returned when an entry is no longer waiting,
+	// e.g. it got an offer concurrently or was cancelled.
+	ErrWaitlistEntryTaken = errors.New("waitlist entry is no longer waiting")
+	// ErrWaitlistOfferClosed is returned when an offer was already accepted,
+	// declined or expired.
+	ErrWaitlistOfferClosed = errors.New("waitlist offer is no longer pending")
+)
+
+type WaitlistRepository interface {
+	Create(ctx context.Context, entry *entity.WaitlistEntry) (uuid.UUID, error)
+	GetById(ctx context.Context, id uuid.UUID) (*entity.WaitlistEntry, error)
+	GetByUserId(ctx context.Context, userID uuid.UUID) ([]*entity.WaitlistEntry, error)
+	GetWaiting(ctx context.Context, locationID uuid.UUID, date string) ([]*entity.WaitlistEntry, error)
+	Cancel(ctx context.Context, id uuid.UUID) error
+	CreateOffer(ctx context.Context, offer *entity.WaitlistOffer) error
+	GetOffer(ctx context.Context, id uuid.UUID) (*entity.WaitlistOffer, error)
+	GetOfferByAppointmentId(ctx context.Context, appointmentID uuid.UUID) (*entity.WaitlistOffer, error)
+	GetExpiredOffers(ctx context.Context, now time.Time) ([]*entity.WaitlistOffer, error)
+	ResolveOffer(ctx context.Context, offer *entity.WaitlistOffer, status entity.WaitlistOfferStatus, entryStatus entity.WaitlistStatus) error
+	AcceptOffer(ctx context.Context, offer *entity.WaitlistOffer, change *entity.AppointmentStatusChange) error
+	CloseOffer(ctx context.Context, offer *entity.WaitlistOffer, status entity.WaitlistOfferStatus, entryStatus entity.WaitlistStatus, change *entity.AppointmentStatusChange) error
+}
+
+type waitlistStorage struct {
+	pg *database.PostgresDB
+}
+
+func NewWaitlistStorage(deps StorageDeps) WaitlistRepository {
+	return &waitlistStorage{
+		pg: deps.PostgresDB,
+	}
+}
+
+func (s *waitlistStorage) Create(ctx context.Context, entry *entity.WaitlistEntry) (uuid.UUID, error) {
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
+	}
+
+	const query = `
+		INSERT INTO waitlist_entries (id, user_id, location_id, vehicle_id, service_ids, date_from, date_to, status)
+		VALUES ($1, $2, $3, $4, $5, $6::DATE, $7::DATE, $8)
+		RETURNING id;
+	`
+
+	row := s.pg.DB.QueryRowContext(ctx, query,
+		entry.ID, entry.UserID, entry.LocationID, entry.VehicleID, pq.Array(entry.ServiceIDs), entry.DateFrom, entry.DateTo, entry.Status,
+	)
+	if err := row.Scan(&entry.ID); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to insert waitlist entry: %w", err)
+	}
+
+	return entry.ID, nil
+}
+
+// entryColumns selects an entry with its pending offer, if any.
+const entryColumns = `
+	e.id, e.user_id, e.location_id, e.vehicle_id, e.service_ids, to_char(e.date_from, 'YYYY-MM-DD'), to_char(e.date_to, 'YYYY-MM-DD'),
+	e.status, e.created_at,
+	o.id, o.appointment_id, o.appointment_time, o.status, o.expires_at, o.created_at
+`
+
+const entryFrom = `
+	FROM waitlist_entries e
+	LEFT JOIN waitlist_offers o ON o.entry_id = e.id AND o.status = 'pending'
+`
+
+func scanEntry(row interface {
+	Scan(dest ...interface{}) error
+}) (*entity.WaitlistEntry, error) {
+	var entry entity.WaitlistEntry
+	var serviceIDs []string
+	var offerID, appointmentID *uuid.UUID
+	var appointmentTime, expiresAt, offerCreatedAt *time.Time
+	var offerStatus *entity.WaitlistOfferStatus
+
+	if err := row.Scan(
+		&entry.ID, &entry.UserID, &entry.LocationID, &entry.VehicleID, pq.Array(&serviceIDs), &entry.DateFrom, &entry.DateTo,
+		&entry.Status, &entry.CreatedAt,
+		&offerID, &appointmentID, &appointmentTime, &offerStatus, &expiresAt, &offerCreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	for _, id := range serviceIDs {
+		serviceID, err := uuid.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid service id %q: %w", id, err)
+		}
+		entry.ServiceIDs = append(entry.ServiceIDs, serviceID)
+	}
+
+	if offerID != nil {
+		entry.Offer = &entity.WaitlistOffer{
+			ID:              *offerID,
+			EntryID:         entry.ID,
+			AppointmentID:   *appointmentID,
+			AppointmentTime: *appointmentTime,
+			Status:          *offerStatus,
+			ExpiresAt:       *expiresAt,
+			CreatedAt:       offerCreatedAt,
+		}
+	}
+
+	return &entry, nil
+}
+
+func (s *waitlistStorage) GetById(ctx context.Context, id uuid.UUID) (*entity.WaitlistEntry, error) {
+	query := `SELECT ` + entryColumns + entryFrom + ` WHERE e.id = $1;`
+
+	entry, err := scanEntry(s.pg.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("waitlist entry %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get waitlist entry: %w", err)
+	}
+
+	return entry, nil
+}
+
+func (s *waitlistStorage) GetByUserId(ctx context.Context, userID uuid.UUID) ([]*entity.WaitlistEntry, error) {
+	query := `SELECT ` + entryColumns + entryFrom + ` WHERE e.user_id = $1 ORDER BY e.created_at DESC;`
+
+	return s.queryEntries(ctx, query, userID)
+}
+
+// GetWaiting returns the entries still waiting for a slot at the location on
+// the date, first come first served.
+func (s *waitlistStorage) GetWaiting(ctx context.Context, locationID uuid.UUID, date string) ([]*entity.WaitlistEntry, error) {
+	query := `SELECT ` + entryColumns + entryFrom + `
+		WHERE e.location_id = $1 AND e.status = 'waiting' AND e.date_from <= $2::DATE AND e.date_to >= $2::DATE
+		ORDER BY e.created_at, e.id;
+	`
+
+	return s.queryEntries(ctx, query, locationID, date)
+}
+
+func (s *waitlistStorage) queryEntries(ctx context.Context, query string, args ...interface{}) ([]*entity.WaitlistEntry, error) {
+	rows, err := s.pg.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query waitlist entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*entity.WaitlistEntry{}
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan waitlist entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// Cancel takes a waiting entry off the list.
+func (s *waitlistStorage) Cancel(ctx context.Context, id uuid.UUID) error {
+	const query = `
+		UPDATE waitlist_entries
+		SET status = 'cancelled', updated_at = NOW()
+		WHERE id = $1 AND status = 'waiting';
+	`
+
+	result, err := s.pg.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to cancel waitlist entry: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrWaitlistEntryTaken
+	}
+
+	return nil
+}
+
+// CreateOffer saves the offer and marks its entry as offered. The entry must
+// still be waiting, otherwise ErrWaitlistEntryTaken is returned.
+func (s *waitlistStorage) CreateOffer(ctx context.Context, offer *entity.WaitlistOffer) error {
+	tx, err := s.pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	const entryQuery = `
+		UPDATE waitlist_entries
+		SET status = 'offered', updated_at = NOW()
+		WHERE id = $1 AND status = 'waiting';
+	`
+
+	result, err := tx.ExecContext(ctx, entryQuery, offer.EntryID)
+	if err != nil {
+		return fmt.Errorf("failed to update waitlist entry: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrWaitlistEntryTaken
+	}
+
+	if offer.ID == uuid.Nil {
+		offer.ID = uuid.New()
+	}
+
+	const offerQuery = `
+		INSERT INTO waitlist_offers (id, entry_id, appointment_id, appointment_time, status, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6);
+	`
+
+	if _, err := tx.ExecContext(ctx, offerQuery,
+		offer.ID, offer.EntryID, offer.AppointmentID, offer.AppointmentTime, offer.Status, offer.ExpiresAt,
+	); err != nil {
+		return fmt.Errorf("failed to insert waitlist offer: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+const offerColumns = `
+	SELECT id, entry_id, appointment_id, appointment_time, status, expires_at, created_at
+	FROM waitlist_offers
+`
+
+func scanOffer(row interface {
+	Scan(dest ...interface{}) error
+}) (*entity.WaitlistOffer, error) {
+	var offer entity.WaitlistOffer
+	if err := row.Scan(
+		&offer.ID, &offer.EntryID, &offer.AppointmentID, &offer.AppointmentTime,
+		&offer.Status, &offer.ExpiresAt, &offer.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &offer, nil
+}
+
+func (s *waitlistStorage) GetOffer(ctx context.Context, id uuid.UUID) (*entity.WaitlistOffer, error) {
+	offer, err := scanOffer(s.pg.DB.QueryRowContext(ctx, offerColumns+` WHERE id = $1;`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("waitlist offer %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get waitlist offer: %w", err)
+	}
+
+	return offer, nil
+}
+
+// GetOfferByAppointmentId returns the pending offer holding the appointment, or nil.
+func (s *waitlistStorage) GetOfferByAppointmentId(ctx context.Context, appointmentID uuid.UUID) (*entity.WaitlistOffer, error) {
+	query := offerColumns + ` WHERE appointment_id = $1 AND status = 'pending';`
+
+	offer, err := scanOffer(s.pg.DB.QueryRowContext(ctx, query, appointmentID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get waitlist offer: %w", err)
+	}
+
+	return offer, nil
+}
+
+func (s *waitlistStorage) GetExpiredOffers(ctx context.Context, now time.Time) ([]*entity.WaitlistOffer, error) {
+	query := offerColumns + ` WHERE status = 'pending' AND expires_at <= $1 ORDER BY expires_at;`
+
+	rows, err := s.pg.DB.QueryContext(ctx, query, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expired offers: %w", err)
+	}
+	defer rows.Close()
+
+	offers := []*entity.WaitlistOffer{}
+	for rows.Next() {
+		offer, err := scanOffer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan waitlist offer: %w", err)
+		}
+		offers = append(offers, offer)
+	}
+
+	return offers, nil
+}
+
+// ResolveOffer closes a pending offer and moves its entry to entryStatus.
+// It reports ErrWaitlistOfferClosed if the offer was already closed.
+func (s *waitlistStorage) ResolveOffer(ctx context.Context, offer *entity.WaitlistOffer, status entity.WaitlistOfferStatus, entryStatus entity.WaitlistStatus) error {
+	tx, err := s.pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := resolveOffer(ctx, tx, offer, status, entryStatus); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	offer.Status = status
+	return nil
+}
+
+// AcceptOffer closes the offer as accepted, books its entry and schedules the
+// reserved appointment in one transaction, recording change in its status
+// history. It reports ErrWaitlistOfferClosed if the offer was already closed
+// or its appointment no longer reserved.
+func (s *waitlistStorage) AcceptOffer(ctx context.Context, offer *entity.WaitlistOffer, change *entity.AppointmentStatusChange) error {
+	tx, err := s.pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := resolveOffer(ctx, tx, offer, entity.WaitlistOfferAccepted, entity.WaitlistStatusBooked); err != nil {
+		return err
+	}
+
+	// The reservation already holds the resources and mechanics
+	const appointmentQuery = `
+		UPDATE appointments
+		SET status = $2, updated_at = NOW()
+		WHERE id = $1 AND status = $3 AND deleted_at IS NULL;
+	`
+
+	result, err := tx.ExecContext(ctx, appointmentQuery,
+		offer.AppointmentID, entity.AppointmentStatusScheduled, entity.AppointmentStatusReserved,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to schedule appointment: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrWaitlistOfferClosed
+	}
+
+	if err := recordStatusChange(ctx, tx, change); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	offer.Status = entity.WaitlistOfferAccepted
+	return nil
+}
+
+// CloseOffer closes the offer as declined or expired, moves its entry and,
+// with a change, cancels the reserved appointment and frees its resources and
+// mechanics in one transaction. It reports ErrWaitlistOfferClosed if the offer
+// was already closed or its appointment no longer reserved.
+func (s *waitlistStorage) CloseOffer(
+	ctx context.Context,
+	offer *entity.WaitlistOffer,
+	status entity.WaitlistOfferStatus,
+	entryStatus entity.WaitlistStatus,
+	change *entity.AppointmentStatusChange,
+) error {
+	tx, err := s.pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := resolveOffer(ctx, tx, offer, status, entryStatus); err != nil {
+		return err
+	}
+
+	if change != nil {
+		const appointmentQuery = `
+			UPDATE appointments
+			SET status = $2, updated_at = NOW()
+			WHERE id = $1 AND status = $3 AND deleted_at IS NULL;
+		`
+
+		result, err := tx.ExecContext(ctx, appointmentQuery,
+			offer.AppointmentID, entity.AppointmentStatusCancelled, entity.AppointmentStatusReserved,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to cancel appointment: %w", err)
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get affected rows: %w", err)
+		}
+
+		if rows == 0 {
+			return ErrWaitlistOfferClosed
+		}
+
+		const resourcesQuery = `
+			UPDATE appointment_resources
+			SET active = FALSE
+			WHERE appointment_id = $1;
+		`
+
+		if _, err := tx.ExecContext(ctx, resourcesQuery, offer.AppointmentID); err != nil {
+			return fmt.Errorf("failed to release appointment resources: %w", err)
+		}
+
+		const mechanicsQuery = `
+			UPDATE appointment_mechanics
+			SET active = FALSE
+			WHERE appointment_id = $1;
+		`
+
+		if _, err := tx.ExecContext(ctx, mechanicsQuery, offer.AppointmentID); err != nil {
+			return fmt.Errorf("failed to release appointment mechanics: %w", err)
+		}
+
+		if err := recordStatusChange(ctx, tx, change); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	offer.Status = status
+	return nil
+}
+
+// resolveOffer closes the pending offer and moves its entry within tx.
+func resolveOffer(ctx context.Context, tx *sql.Tx, offer *entity.WaitlistOffer, status entity.WaitlistOfferStatus, entryStatus entity.WaitlistStatus) error {
+	const offerQuery = `
+		UPDATE waitlist_offers
+		SET status = $2, resolved_at = NOW()
+		WHERE id = $1 AND status = 'pending';
+	`
+
+	result, err := tx.ExecContext(ctx, offerQuery, offer.ID, status)
+	if err != nil {
+		return fmt.Errorf("failed to update waitlist offer: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrWaitlistOfferClosed
+	}
+
+	const entryQuery = `
+		UPDATE waitlist_entries
+		SET status = $2, updated_at = NOW()
+		WHERE id = $1;
+	`
+
+	if _, err := tx.ExecContext(ctx, entryQuery, offer.EntryID, entryStatus); err != nil {
+		return fmt.Errorf("failed to update waitlist entry: %w", err)
+	}
+
+	return nil
+}
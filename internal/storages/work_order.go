@@ -0,0 +1,366 @@
+package storages
+
+import (
+	"backend-service/internal/entity"
+	"backend-service/pkg/database"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrWorkOrderExists is returned when the appointment already has a work order.
+	ErrWorkOrderExists = errors.New("appointment already has a work order")
+	// ErrWorkOrderClosed is returned when a closed work order would be changed.
+	ErrWorkOrderClosed = errors.New("work order is closed")
+	// ErrLaborLineLinked is returned when a labor line of a booked service would
+	// become a part or consumable.
+	ErrLaborLineLinked = errors.New("labor line of a booked service cannot change its kind")
+)
+
+type WorkOrderRepository interface {
+	Create(ctx context.Context, order *entity.WorkOrder) (uuid.UUID, error)
+	GetByAppointmentId(ctx context.Context, appointmentID uuid.UUID) (*entity.WorkOrder, error)
+	AddLine(ctx context.Context, line *entity.WorkOrderLine) (uuid.UUID, error)
+	UpdateLine(ctx context.Context, line *entity.WorkOrderLine) error
+	DeleteLine(ctx context.Context, workOrderID, lineID uuid.UUID) error
+	Close(ctx context.Context, id uuid.UUID, closedBy *uuid.UUID) error
+}
+
+type workOrderStorage struct {
+	pg *database.PostgresDB
+}
+
+func NewWorkOrderStorage(deps StorageDeps) WorkOrderRepository {
+	return &workOrderStorage{
+		pg: deps.PostgresDB,
+	}
+}
+
+// Create opens the work order with a labor line for every service of the
+// appointment. A line is one unit at the appointment_services.price snapshot,
+// so its amount is exactly the price the client booked at.
+func (s *workOrderStorage) Create(ctx context.Context, order *entity.WorkOrder) (uuid.UUID, error) {
+	tx, err := s.pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if order.ID == uuid.Nil {
+		order.ID = uuid.New()
+	}
+
+	const orderQuery = `
+		INSERT INTO work_orders (id, appointment_id, status)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (appointment_id) DO NOTHING
+		RETURNING id;
+	`
+
+	row := tx.QueryRowContext(ctx, orderQuery, order.ID, order.AppointmentID, entity.WorkOrderStatusOpen)
+	if err := row.Scan(&order.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.Nil, ErrWorkOrderExists
+		}
+		return uuid.Nil, fmt.Errorf("failed to insert work order: %w", err)
+	}
+
+	const linesQuery = `
+		INSERT INTO work_order_lines (id, work_order_id, kind, appointment_service_id, description, quantity, unit_price)
+		SELECT gen_random_uuid(), $1, 'labor', as_link.id, s.name, 1, as_link.price
+		FROM appointment_services as_link
+		JOIN services s ON s.id = as_link.service_id
+		WHERE as_link.appointment_id = $2 AND as_link.deleted_at IS NULL;
+	`
+
+	if _, err := tx.ExecContext(ctx, linesQuery, order.ID, order.AppointmentID); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to insert work order lines: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return order.ID, nil
+}
+
+func (s *workOrderStorage) GetByAppointmentId(ctx context.Context, appointmentID uuid.UUID) (*entity.WorkOrder, error) {
+	const query = `
+		SELECT id, appointment_id, status, closed_at, closed_by, created_at, updated_at
+		FROM work_orders
+		WHERE appointment_id = $1;
+	`
+
+	var order entity.WorkOrder
+	row := s.pg.DB.QueryRowContext(ctx, query, appointmentID)
+	if err := row.Scan(
+		&order.ID, &order.AppointmentID, &order.Status, &order.ClosedAt, &order.ClosedBy, &order.CreatedAt, &order.UpdatedAt,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("work order %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get work order: %w", err)
+	}
+
+	lines, err := s.getLines(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	order.Lines = lines
+
+	return &order, nil
+}
+
+func (s *workOrderStorage) getLines(ctx context.Context, workOrderID uuid.UUID) ([]*entity.WorkOrderLine, error) {
+	const query = `
+		SELECT id, work_order_id, kind, appointment_service_id, description, part_number,
+			quantity, actual_quantity, unit_price, discount_percent, created_at, updated_at
+		FROM work_order_lines
+		WHERE work_order_id = $1
+		ORDER BY created_at, id;
+	`
+
+	rows, err := s.pg.DB.QueryContext(ctx, query, workOrderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query work order lines: %w", err)
+	}
+	defer rows.Close()
+
+	lines := []*entity.WorkOrderLine{}
+	for rows.Next() {
+		var line entity.WorkOrderLine
+		if err := rows.Scan(
+			&line.ID, &line.WorkOrderID, &line.Kind, &line.AppointmentServiceID, &line.Description, &line.PartNumber,
+			&line.Quantity, &line.ActualQuantity, &line.UnitPrice, &line.DiscountPercent, &line.CreatedAt, &line.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan work order line: %w", err)
+		}
+		lines = append(lines, &line)
+	}
+
+	return lines, nil
+}
+
+// lockOpenWorkOrder locks the work order for the transaction and makes sure it is still open.
+func lockOpenWorkOrder(ctx context.Context, tx *sql.Tx, workOrderID uuid.UUID) error {
+	const query = `
+		SELECT status
+		FROM work_orders
+		WHERE id = $1
+		FOR UPDATE;
+	`
+
+	var status entity.WorkOrderStatus
+	if err := tx.QueryRowContext(ctx, query, workOrderID).Scan(&status); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("work order %w", ErrNotFound)
+		}
+		return fmt.Errorf("failed to lock work order: %w", err)
+	}
+	if status != entity.WorkOrderStatusOpen {
+		return ErrWorkOrderClosed
+	}
+
+	return nil
+}
+
+func (s *workOrderStorage) AddLine(ctx context.Context, line *entity.WorkOrderLine) (uuid.UUID, error) {
+	tx, err := s.pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockOpenWorkOrder(ctx, tx, line.WorkOrderID); err != nil {
+		return uuid.Nil, err
+	}
+
+	if line.ID == uuid.Nil {
+		line.ID = uuid.New()
+	}
+
+	const query = `
+		INSERT INTO work_order_lines (id, work_order_id, kind, description, part_number,
+			quantity, actual_quantity, unit_price, discount_percent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);
+	`
+
+	if _, err := tx.ExecContext(ctx, query,
+		line.ID, line.WorkOrderID, line.Kind, line.Description, line.PartNumber,
+		line.Quantity, line.ActualQuantity, line.UnitPrice, line.DiscountPercent,
+	); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to insert work order line: %w", err)
+	}
+
+	if err := touchWorkOrder(ctx, tx, line.WorkOrderID); err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return line.ID, nil
+}
+
+// UpdateLine replaces the line. A labor line keeps its link to the
+// appointment service and so must stay labor.
+func (s *workOrderStorage) UpdateLine(ctx context.Context, line *entity.WorkOrderLine) error {
+	tx, err := s.pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockOpenWorkOrder(ctx, tx, line.WorkOrderID); err != nil {
+		return err
+	}
+
+	const linkQuery = `
+		SELECT appointment_service_id
+		FROM work_order_lines
+		WHERE id = $1 AND work_order_id = $2;
+	`
+
+	var appointmentServiceID *uuid.UUID
+	if err := tx.QueryRowContext(ctx, linkQuery, line.ID, line.WorkOrderID).Scan(&appointmentServiceID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("work order line %w", ErrNotFound)
+		}
+		return fmt.Errorf("failed to get work order line: %w", err)
+	}
+	if appointmentServiceID != nil && line.Kind != entity.WorkOrderLineLabor {
+		return ErrLaborLineLinked
+	}
+
+	const query = `
+		UPDATE work_order_lines
+		SET kind = $3, description = $4, part_number = $5, quantity = $6, actual_quantity = $7,
+			unit_price = $8, discount_percent = $9, updated_at = NOW()
+		WHERE id = $1 AND work_order_id = $2;
+	`
+
+	result, err := tx.ExecContext(ctx, query,
+		line.ID, line.WorkOrderID, line.Kind, line.Description, line.PartNumber,
+		line.Quantity, line.ActualQuantity, line.UnitPrice, line.DiscountPercent,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update work order line: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("work order line %w", ErrNotFound)
+	}
+
+	if err := touchWorkOrder(ctx, tx, line.WorkOrderID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *workOrderStorage) DeleteLine(ctx context.Context, workOrderID, lineID uuid.UUID) error {
+	tx, err := s.pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockOpenWorkOrder(ctx, tx, workOrderID); err != nil {
+		return err
+	}
+
+	const query = `
+		DELETE FROM work_order_lines
+		WHERE id = $1 AND work_order_id = $2;
+	`
+
+	result, err := tx.ExecContext(ctx, query, lineID, workOrderID)
+	if err != nil {
+		return fmt.Errorf("failed to delete work order line: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("work order line %w", ErrNotFound)
+	}
+
+	if err := touchWorkOrder(ctx, tx, workOrderID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Close locks the work order. Lines without an actual quantity are closed at
+// their estimate, so the actual total no longer changes.
+func (s *workOrderStorage) Close(ctx context.Context, id uuid.UUID, closedBy *uuid.UUID) error {
+	tx, err := s.pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockOpenWorkOrder(ctx, tx, id); err != nil {
+		return err
+	}
+
+	const linesQuery = `
+		UPDATE work_order_lines
+		SET actual_quantity = quantity, updated_at = NOW()
+		WHERE work_order_id = $1 AND actual_quantity IS NULL;
+	`
+
+	if _, err := tx.ExecContext(ctx, linesQuery, id); err != nil {
+		return fmt.Errorf("failed to fill actual quantities: %w", err)
+	}
+
+	const orderQuery = `
+		UPDATE work_orders
+		SET status = $2, closed_at = NOW(), closed_by = $3, updated_at = NOW()
+		WHERE id = $1;
+	`
+
+	if _, err := tx.ExecContext(ctx, orderQuery, id, entity.WorkOrderStatusClosed, closedBy); err != nil {
+		return fmt.Errorf("failed to close work order: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func touchWorkOrder(ctx context.Context, tx *sql.Tx, id uuid.UUID) error {
+	const query = `
+		UPDATE work_orders
+		SET updated_at = NOW()
+		WHERE id = $1;
+	`
+
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to update work order: %w", err)
+	}
+	return nil
+}
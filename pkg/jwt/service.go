@@ -1,10 +1,13 @@
 // Package jwt предоставляет сервис для генерации, валидации и обновления
-// JWT access/refresh токенов с привязкой к nonce-хранилищу.
+// JWT access/refresh токенов с привязкой к nonce-хранилищу и списку отзыва.
 //
 // Он предназначен для использования в микросервисной архитектуре, где
 // аутентификация пользователя разделена по сервисам.
 //
-// Формат токенов: HMAC-SHA256, со встроенными кастомными клеймами.
+// Формат токенов: RS256 или EdDSA (по типу активного ключа) со встроенными
+// кастомными клеймами. Ключ указывается в заголовке kid, публичные ключи
+// отдаются в формате JWKS, поэтому другие сервисы проверяют токены сами,
+// без общего секрета.
 
 package jwt
 
@@ -13,6 +16,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -24,15 +28,18 @@ const (
 
 // Config содержит параметры конфигурации для JWT-сервиса.
 type Config struct {
-	SecretKey       string
+	Keys            *KeySet
 	AccessTokenTTL  time.Duration
 	RefreshTokenTTL time.Duration
+	// Время жизни токена, выданного администратору для входа от имени пользователя
+	ImpersonationTokenTTL time.Duration
 }
 
 // Service реализует логику генерации и валидации JWT-токенов.
 type Service struct {
-	cfg          Config
-	nonceStorage NonceStorage
+	cfg               Config
+	nonceStorage      NonceStorage
+	revocationStorage RevocationStorage
 }
 
 // NonceStorage описывает интерфейс для хранения и проверки nonce.
@@ -42,19 +49,56 @@ type NonceStorage interface {
 	Validate(nonce, token string) (bool, error)
 }
 
+// RevocationStorage описывает интерфейс списка отозванных токенов.
+// Отдельный токен отзывается по его идентификатору (jti), а все токены
+// пользователя разом — увеличением поколения (generation) пользователя.
+type RevocationStorage interface {
+	Revoke(tokenId string, ttl time.Duration) error
+	IsRevoked(tokenId string) (bool, error)
+	Generation(userId string) (int64, error)
+	IncrGeneration(userId string) (int64, error)
+}
+
 // CustomClaims расширяет стандартные JWT claims специфичными полями
-// для пользовательского идентификатора, типа токена, nonce и хеша access-токена.
+// для пользовательского идентификатора, роли, сессии, типа токена, nonce,
+// хеша access-токена, поколения токенов пользователя и признака входа
+// со вторым фактором (MFA). Actor заполнен, только если токен выдан
+// администратору для работы от имени пользователя.
 type CustomClaims struct {
-	UserId    string `json:"user_id,omitempty"`
-	TokenId   string `json:"token_id,omitempty"`
-	TokenType string `json:"token_type,omitempty"`
-	Nonce     string `json:"nonce,omitempty"`
+	UserId     string `json:"user_id,omitempty"`
+	Role       string `json:"role,omitempty"`
+	SessionId  string `json:"sid,omitempty"`
+	TokenId    string `json:"token_id,omitempty"`
+	TokenType  string `json:"token_type,omitempty"`
+	Nonce      string `json:"nonce,omitempty"`
+	Generation int64  `json:"gen,omitempty"`
+	MFA        bool   `json:"mfa,omitempty"`
+	Actor      *Actor `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// New создает экземпляр JWT-сервиса с заданной конфигурацией и хранилищем nonce.
-func New(cfg Config, ns NonceStorage) *Service {
-	return &Service{cfg: cfg, nonceStorage: ns}
+// Actor — клейм act (RFC 8693): тот, кто фактически действует от имени UserId.
+type Actor struct {
+	UserId string `json:"sub"`
+	// Поколение токенов актора: его токены имперсонации отзываются вместе с его собственными
+	Generation int64 `json:"gen,omitempty"`
+}
+
+// Subject описывает владельца пары токенов: пользователя, его роль, сессию,
+// к которой привязана пара, и пройден ли при входе второй фактор.
+// Сессия и признак MFA сохраняются при обновлении токенов.
+type Subject struct {
+	UserId    string
+	Role      string
+	SessionId string
+	MFA       bool
+}
+
+// New создает экземпляр JWT-сервиса с заданной конфигурацией, хранилищем nonce
+// и списком отзыва. Любое из хранилищ может быть nil — тогда соответствующая
+// проверка пропускается.
+func New(cfg Config, ns NonceStorage, rs RevocationStorage) *Service {
+	return &Service{cfg: cfg, nonceStorage: ns, revocationStorage: rs}
 }
 
 // generateNonce создает криптографически безопасный уникальный идентификатор nonce.
@@ -66,41 +110,69 @@ func generateNonce() (string, error) {
 	return hex.EncodeToString(nonce), nil
 }
 
-// generateJWT создает JWT с заданными параметрами.
+// generateJWT подписывает клеймы, дополняя их jti, временем выпуска и истечения.
 // Используется как для access, так и для refresh токенов.
-func (s *Service) generateJWT(userId string, duration time.Duration, accessTokenHash, tokenType, nonce string) (string, error) {
-	claims := CustomClaims{
-		UserId:    userId,
-		TokenId:   accessTokenHash,
-		TokenType: tokenType,
-		Nonce:     nonce,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(duration)),
-			Issuer:    issuer,
-		},
+func (s *Service) generateJWT(claims CustomClaims, duration time.Duration) (string, error) {
+	jti, err := generateNonce()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        jti,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
+		Issuer:    issuer,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.cfg.SecretKey))
+	return s.cfg.Keys.sign(claims)
 }
 
-// GenerateTokenPair создает пару access/refresh токенов.
+// generatePair создает access-токен и связанный с ним refresh-токен
+// для клеймов владельца base.
+func (s *Service) generatePair(base CustomClaims, nonce string) (string, string, error) {
+	access := base
+	access.TokenType = "access"
+	accessToken, err := s.generateJWT(access, s.cfg.AccessTokenTTL)
+	if err != nil {
+		return "", "", err
+	}
+
+	hash := sha256.Sum256([]byte(accessToken))
+
+	refresh := base
+	refresh.TokenType = "refresh"
+	refresh.TokenId = hex.EncodeToString(hash[:])
+	refresh.Nonce = nonce
+	refreshToken, err := s.generateJWT(refresh, s.cfg.RefreshTokenTTL)
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// GenerateTokenPair создает пару access/refresh токенов для владельца sub.
 // В refresh-токен вшивается хеш access-токена и nonce.
-func (s *Service) GenerateTokenPair(userID string) (string, string, error) {
+func (s *Service) GenerateTokenPair(sub Subject) (string, string, error) {
 	nonce, err := generateNonce()
 	if err != nil {
 		return "", "", err
 	}
 
-	accessToken, err := s.generateJWT(userID, s.cfg.AccessTokenTTL, "", "access", "")
+	generation, err := s.currentGeneration(sub.UserId)
 	if err != nil {
 		return "", "", err
 	}
 
-	hash := sha256.Sum256([]byte(accessToken))
-	accessTokenHash := hex.EncodeToString(hash[:])
-
-	refreshToken, err := s.generateJWT(userID, s.cfg.RefreshTokenTTL, accessTokenHash, "refresh", nonce)
+	accessToken, refreshToken, err := s.generatePair(CustomClaims{
+		UserId:     sub.UserId,
+		Role:       sub.Role,
+		SessionId:  sub.SessionId,
+		Generation: generation,
+		MFA:        sub.MFA,
+	}, nonce)
 	if err != nil {
 		return "", "", err
 	}
@@ -116,26 +188,167 @@ func (s *Service) GenerateTokenPair(userID string) (string, string, error) {
 	return accessToken, refreshToken, nil
 }
 
-// ValidateJWT проверяет валидность JWT и соответствие ожидаемому типу ("access"/"refresh").
-// Возвращает userID, если токен валиден.
-func (s *Service) ValidateJWT(tokenStr, expectedType string) (string, error) {
-	token, err := jwt.ParseWithClaims(tokenStr, &CustomClaims{}, func(t *jwt.Token) (interface{}, error) {
-		return []byte(s.cfg.SecretKey), nil
-	})
+// GenerateImpersonationToken выпускает короткоживущий access-токен, с которым
+// пользователь actorID действует от имени sub. Refresh-токен не выдается:
+// по истечении ImpersonationTokenTTL токен нужно запросить заново.
+func (s *Service) GenerateImpersonationToken(sub Subject, actorID string) (string, error) {
+	generation, err := s.currentGeneration(sub.UserId)
 	if err != nil {
 		return "", err
 	}
 
+	actorGeneration, err := s.currentGeneration(actorID)
+	if err != nil {
+		return "", err
+	}
+
+	return s.generateJWT(CustomClaims{
+		UserId:     sub.UserId,
+		Role:       sub.Role,
+		TokenType:  "access",
+		Generation: generation,
+		Actor: &Actor{
+			UserId:     actorID,
+			Generation: actorGeneration,
+		},
+	}, s.cfg.ImpersonationTokenTTL)
+}
+
+// ValidateJWT проверяет валидность JWT, соответствие ожидаемому типу ("access"/"refresh")
+// и отсутствие токена в списке отзыва. Возвращает клеймы, если токен валиден.
+func (s *Service) ValidateJWT(tokenStr, expectedType string) (*CustomClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenStr, &CustomClaims{}, s.cfg.Keys.keyFunc)
+	if err != nil {
+		return nil, err
+	}
+
 	claims, ok := token.Claims.(*CustomClaims)
 	if !ok || !token.Valid {
-		return "", errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 
 	if claims.TokenType != expectedType {
-		return "", errors.New("unexpected token type")
+		return nil, errors.New("unexpected token type")
+	}
+
+	if err := s.checkRevoked(claims); err != nil {
+		return nil, err
 	}
 
-	return claims.UserId, nil
+	return claims, nil
+}
+
+// JWKS возвращает публичные ключи, которыми можно проверить выданные токены.
+func (s *Service) JWKS() JWKS {
+	return s.cfg.Keys.JWKS()
+}
+
+// Revoke отзывает access-токен по его jti. Токен может быть уже просрочен —
+// запись в списке отзыва живет RefreshTokenTTL, поэтому связанный с ним
+// refresh-токен тоже перестает приниматься.
+func (s *Service) Revoke(accessToken string) error {
+	claims, err := s.parseAccessToken(accessToken)
+	if err != nil {
+		return err
+	}
+
+	if s.revocationStorage == nil || claims.ID == "" {
+		return nil
+	}
+
+	return s.revocationStorage.Revoke(claims.ID, s.cfg.RefreshTokenTTL)
+}
+
+// RevokeSession отзывает все токены сессии, включая будущие результаты
+// обновления ее refresh-токена.
+func (s *Service) RevokeSession(sessionID string) error {
+	if s.revocationStorage == nil || sessionID == "" {
+		return nil
+	}
+
+	return s.revocationStorage.Revoke(sessionID, s.cfg.RefreshTokenTTL)
+}
+
+// RevokeAll отзывает все выданные пользователю токены, увеличивая его поколение.
+func (s *Service) RevokeAll(userID string) error {
+	if s.revocationStorage == nil {
+		return nil
+	}
+
+	_, err := s.revocationStorage.IncrGeneration(userID)
+	return err
+}
+
+// currentGeneration возвращает текущее поколение токенов пользователя.
+func (s *Service) currentGeneration(userID string) (int64, error) {
+	if s.revocationStorage == nil {
+		return 0, nil
+	}
+
+	return s.revocationStorage.Generation(userID)
+}
+
+// checkRevoked проверяет, что токен не отозван ни по jti, ни по сессии,
+// ни по поколению пользователя, а для токена имперсонации — и актора.
+func (s *Service) checkRevoked(claims *CustomClaims) error {
+	if s.revocationStorage == nil {
+		return nil
+	}
+
+	for _, id := range []string{claims.ID, claims.SessionId} {
+		if id == "" {
+			continue
+		}
+		revoked, err := s.revocationStorage.IsRevoked(id)
+		if err != nil {
+			return fmt.Errorf("failed to check token revocation: %w", err)
+		}
+		if revoked {
+			return errors.New("token revoked")
+		}
+	}
+
+	generation, err := s.revocationStorage.Generation(claims.UserId)
+	if err != nil {
+		return fmt.Errorf("failed to check token generation: %w", err)
+	}
+	if claims.Generation != generation {
+		return errors.New("token revoked")
+	}
+
+	if claims.Actor == nil {
+		return nil
+	}
+
+	actorGeneration, err := s.revocationStorage.Generation(claims.Actor.UserId)
+	if err != nil {
+		return fmt.Errorf("failed to check actor token generation: %w", err)
+	}
+	if claims.Actor.Generation != actorGeneration {
+		return errors.New("token revoked")
+	}
+
+	return nil
+}
+
+// parseAccessToken проверяет подпись и тип access-токена без учета срока действия.
+// Используется там, где access-токен уже мог истечь: при обновлении пары и выходе.
+func (s *Service) parseAccessToken(accessToken string) (*CustomClaims, error) {
+	token, err := jwt.ParseWithClaims(accessToken, &CustomClaims{}, s.cfg.Keys.keyFunc, jwt.WithoutClaimsValidation())
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*CustomClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid access token")
+	}
+
+	if claims.TokenType != "access" {
+		return nil, errors.New("unexpected token type")
+	}
+
+	return claims, nil
 }
 
 // RefreshTokens валидирует refresh-токен и access-токен, генерирует новую пару токенов.
@@ -146,15 +359,13 @@ func (s *Service) RefreshTokens(refreshToken, accessToken string) (string, strin
 		return "", "", err
 	}
 
-	newAccessToken, err := s.generateJWT(claims.UserId, s.cfg.AccessTokenTTL, "", "access", "")
-	if err != nil {
-		return "", "", err
-	}
-
-	hash := sha256.Sum256([]byte(newAccessToken))
-	accessTokenHash := hex.EncodeToString(hash[:])
-
-	newRefreshToken, err := s.generateJWT(claims.UserId, s.cfg.RefreshTokenTTL, accessTokenHash, "refresh", claims.Nonce)
+	newAccessToken, newRefreshToken, err := s.generatePair(CustomClaims{
+		UserId:     claims.UserId,
+		Role:       claims.Role,
+		SessionId:  claims.SessionId,
+		Generation: claims.Generation,
+		MFA:        claims.MFA,
+	}, claims.Nonce)
 	if err != nil {
 		return "", "", err
 	}
@@ -171,14 +382,12 @@ func (s *Service) RefreshTokens(refreshToken, accessToken string) (string, strin
 }
 
 // validateRefreshToken выполняет полную проверку refresh-токена:
-// проверку подписи, типа, связи с access-токеном и валидацию nonce.
+// проверку подписи, типа, связи с access-токеном, списка отзыва и валидацию nonce.
 func (s *Service) validateRefreshToken(refreshToken, accessToken string) (*CustomClaims, error) {
 	hash := sha256.Sum256([]byte(accessToken))
 	accessTokenHash := hex.EncodeToString(hash[:])
 
-	token, err := jwt.ParseWithClaims(refreshToken, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.cfg.SecretKey), nil
-	})
+	token, err := jwt.ParseWithClaims(refreshToken, &CustomClaims{}, s.cfg.Keys.keyFunc)
 	if err != nil {
 		return nil, err
 	}
@@ -192,6 +401,15 @@ func (s *Service) validateRefreshToken(refreshToken, accessToken string) (*Custo
 		return nil, errors.New("refresh token hash mismatch")
 	}
 
+	accessClaims, err := s.parseAccessToken(accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkRevoked(accessClaims); err != nil {
+		return nil, err
+	}
+
 	if s.nonceStorage == nil {
 		return claims, nil
 	}
@@ -0,0 +1,363 @@
+package jwt
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryNonces — nonce-хранилище в памяти: nonce принимается один раз и
+// только для последнего сохраненного под ним refresh-токена.
+type memoryNonces map[string]string
+
+func (m memoryNonces) Save(nonce, token string) error {
+	m[nonce] = token
+	return nil
+}
+
+func (m memoryNonces) Validate(nonce, token string) (bool, error) {
+	stored, ok := m[nonce]
+	if !ok || stored != token {
+		return false, nil
+	}
+	delete(m, nonce)
+	return true, nil
+}
+
+// memoryRevocations — список отзыва в памяти.
+type memoryRevocations struct {
+	revoked     map[string]bool
+	generations map[string]int64
+}
+
+func newMemoryRevocations() *memoryRevocations {
+	return &memoryRevocations{revoked: map[string]bool{}, generations: map[string]int64{}}
+}
+
+func (m *memoryRevocations) Revoke(tokenId string, _ time.Duration) error {
+	m.revoked[tokenId] = true
+	return nil
+}
+
+func (m *memoryRevocations) IsRevoked(tokenId string) (bool, error) {
+	return m.revoked[tokenId], nil
+}
+
+func (m *memoryRevocations) Generation(userId string) (int64, error) {
+	return m.generations[userId], nil
+}
+
+func (m *memoryRevocations) IncrGeneration(userId string) (int64, error) {
+	m.generations[userId]++
+	return m.generations[userId], nil
+}
+
+func newTestKeySet(t *testing.T, activeID string, ids ...string) *KeySet {
+	t.Helper()
+	keys := make([]*Key, 0, len(ids))
+	for _, id := range ids {
+		key, err := GenerateEd25519Key(id)
+		if err != nil {
+			t.Fatalf("GenerateEd25519Key() error = %v", err)
+		}
+		keys = append(keys, key)
+	}
+	ks, err := NewKeySet(activeID, keys...)
+	if err != nil {
+		t.Fatalf("NewKeySet() error = %v", err)
+	}
+	return ks
+}
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	return New(Config{
+		Keys:                  newTestKeySet(t, "k1", "k1"),
+		AccessTokenTTL:        time.Minute,
+		RefreshTokenTTL:       time.Hour,
+		ImpersonationTokenTTL: time.Minute,
+	}, memoryNonces{}, newMemoryRevocations())
+}
+
+// tamper подставляет в token клеймы из other, оставляя подпись token.
+func tamper(token, other string) string {
+	parts, otherParts := strings.Split(token, "."), strings.Split(other, ".")
+	return parts[0] + "." + otherParts[1] + "." + parts[2]
+}
+
+var testSubject = Subject{UserId: "user-1", Role: "client", SessionId: "session-1", MFA: true}
+
+func TestGenerateTokenPair(t *testing.T) {
+	s := newTestService(t)
+
+	access, refresh, err := s.GenerateTokenPair(testSubject)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair() error = %v", err)
+	}
+
+	claims, err := s.ValidateJWT(access, "access")
+	if err != nil {
+		t.Fatalf("ValidateJWT() error = %v", err)
+	}
+	if claims.UserId != testSubject.UserId || claims.Role != testSubject.Role ||
+		claims.SessionId != testSubject.SessionId || !claims.MFA {
+		t.Errorf("ValidateJWT() claims = %+v, want subject %+v", claims, testSubject)
+	}
+	if claims.Issuer != issuer || claims.ID == "" {
+		t.Errorf("ValidateJWT() issuer = %q, jti = %q", claims.Issuer, claims.ID)
+	}
+
+	tests := []struct {
+		name         string
+		token        string
+		expectedType string
+	}{
+		{"access token as refresh", access, "refresh"},
+		{"refresh token as access", refresh, "access"},
+		{"payload of another token", tamper(access, refresh), "refresh"},
+		{"not a token", "not-a-token", "access"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.ValidateJWT(tt.token, tt.expectedType); err == nil {
+				t.Error("ValidateJWT() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestValidateJWTExpired(t *testing.T) {
+	s := newTestService(t)
+	s.cfg.AccessTokenTTL = -time.Minute
+
+	access, _, err := s.GenerateTokenPair(testSubject)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair() error = %v", err)
+	}
+
+	if _, err := s.ValidateJWT(access, "access"); err == nil {
+		t.Error("ValidateJWT() accepted an expired token")
+	}
+
+	// Просроченный access-токен все равно можно отозвать при выходе
+	if err := s.Revoke(access); err != nil {
+		t.Errorf("Revoke() of an expired token error = %v", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKeys := newTestKeySet(t, "k1", "k1")
+	old := New(Config{Keys: oldKeys, AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour}, nil, nil)
+	access, _, err := old.GenerateTokenPair(testSubject)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair() error = %v", err)
+	}
+
+	// Новый активный ключ, старый оставлен только для проверки
+	next, err := GenerateEd25519Key("k2")
+	if err != nil {
+		t.Fatalf("GenerateEd25519Key() error = %v", err)
+	}
+	retired := *oldKeys.active
+	retired.PrivateKey = nil
+	rotated, err := NewKeySet("k2", &retired, next)
+	if err != nil {
+		t.Fatalf("NewKeySet() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		keys    *KeySet
+		wantErr string
+	}{
+		{name: "old key kept for verification", keys: rotated},
+		{name: "old key dropped", keys: newTestKeySet(t, "k2", "k2"), wantErr: "unknown key id"},
+		{name: "same kid, other key", keys: newTestKeySet(t, "k1", "k1"), wantErr: "signature"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(Config{Keys: tt.keys}, nil, nil)
+			_, err := s.ValidateJWT(access, "access")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateJWT() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateJWT() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	rotatedService := New(Config{Keys: rotated, AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour}, nil, nil)
+	fresh, _, err := rotatedService.GenerateTokenPair(testSubject)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair() error = %v", err)
+	}
+	if _, err := old.ValidateJWT(fresh, "access"); err == nil {
+		t.Error("a token signed with the new key validated against the old key set")
+	}
+
+	jwks := rotatedService.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kid != "k1" || jwks.Keys[1].Kid != "k2" {
+		t.Fatalf("JWKS() = %+v, want k1 and k2", jwks.Keys)
+	}
+	for _, key := range jwks.Keys {
+		if key.Kty != "OKP" || key.Crv != "Ed25519" || key.Alg != "EdDSA" || key.X == "" {
+			t.Errorf("JWKS() key = %+v", key)
+		}
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	tests := []struct {
+		name   string
+		revoke func(s *Service, access string) error
+	}{
+		{
+			name:   "token",
+			revoke: func(s *Service, access string) error { return s.Revoke(access) },
+		},
+		{
+			name:   "session",
+			revoke: func(s *Service, _ string) error { return s.RevokeSession(testSubject.SessionId) },
+		},
+		{
+			name:   "all user tokens",
+			revoke: func(s *Service, _ string) error { return s.RevokeAll(testSubject.UserId) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t)
+			access, refresh, err := s.GenerateTokenPair(testSubject)
+			if err != nil {
+				t.Fatalf("GenerateTokenPair() error = %v", err)
+			}
+
+			if err := tt.revoke(s, access); err != nil {
+				t.Fatalf("revoke error = %v", err)
+			}
+
+			if _, err := s.ValidateJWT(access, "access"); err == nil || err.Error() != "token revoked" {
+				t.Errorf("ValidateJWT() error = %v, want token revoked", err)
+			}
+			if _, _, err := s.RefreshTokens(refresh, access); err == nil {
+				t.Error("RefreshTokens() accepted a revoked pair")
+			}
+		})
+	}
+}
+
+func TestRevokeKeepsOtherTokens(t *testing.T) {
+	s := newTestService(t)
+
+	revoked, _, err := s.GenerateTokenPair(testSubject)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair() error = %v", err)
+	}
+	other := testSubject
+	other.SessionId = "session-2"
+	kept, _, err := s.GenerateTokenPair(other)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair() error = %v", err)
+	}
+
+	if err := s.RevokeSession(testSubject.SessionId); err != nil {
+		t.Fatalf("RevokeSession() error = %v", err)
+	}
+
+	if _, err := s.ValidateJWT(revoked, "access"); err == nil {
+		t.Error("ValidateJWT() accepted a token of the revoked session")
+	}
+	if _, err := s.ValidateJWT(kept, "access"); err != nil {
+		t.Errorf("ValidateJWT() of another session error = %v", err)
+	}
+
+	// После отзыва всех токенов новые токены пользователя снова действуют
+	if err := s.RevokeAll(testSubject.UserId); err != nil {
+		t.Fatalf("RevokeAll() error = %v", err)
+	}
+	fresh, _, err := s.GenerateTokenPair(other)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair() error = %v", err)
+	}
+	if _, err := s.ValidateJWT(fresh, "access"); err != nil {
+		t.Errorf("ValidateJWT() of a token issued after RevokeAll error = %v", err)
+	}
+}
+
+func TestRefreshTokens(t *testing.T) {
+	s := newTestService(t)
+
+	access, refresh, err := s.GenerateTokenPair(testSubject)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair() error = %v", err)
+	}
+	otherAccess, _, err := s.GenerateTokenPair(testSubject)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair() error = %v", err)
+	}
+
+	if _, _, err := s.RefreshTokens(refresh, otherAccess); err == nil || err.Error() != "refresh token hash mismatch" {
+		t.Errorf("RefreshTokens() with another access token error = %v, want hash mismatch", err)
+	}
+	if _, _, err := s.RefreshTokens(access, access); err == nil {
+		t.Error("RefreshTokens() accepted an access token as refresh")
+	}
+
+	newAccess, newRefresh, err := s.RefreshTokens(refresh, access)
+	if err != nil {
+		t.Fatalf("RefreshTokens() error = %v", err)
+	}
+
+	claims, err := s.ValidateJWT(newAccess, "access")
+	if err != nil {
+		t.Fatalf("ValidateJWT() of the rotated token error = %v", err)
+	}
+	if claims.SessionId != testSubject.SessionId || !claims.MFA {
+		t.Errorf("rotated claims = %+v, want session and MFA kept", claims)
+	}
+
+	// Старый refresh-токен одноразовый
+	if _, _, err := s.RefreshTokens(refresh, access); err == nil || err.Error() != "nonce validation failed" {
+		t.Errorf("RefreshTokens() reuse error = %v, want nonce validation failed", err)
+	}
+
+	if _, _, err := s.RefreshTokens(newRefresh, newAccess); err != nil {
+		t.Errorf("RefreshTokens() of the rotated pair error = %v", err)
+	}
+}
+
+func TestGenerateImpersonationToken(t *testing.T) {
+	s := newTestService(t)
+	const adminID = "admin-1"
+
+	token, err := s.GenerateImpersonationToken(testSubject, adminID)
+	if err != nil {
+		t.Fatalf("GenerateImpersonationToken() error = %v", err)
+	}
+
+	claims, err := s.ValidateJWT(token, "access")
+	if err != nil {
+		t.Fatalf("ValidateJWT() error = %v", err)
+	}
+	if claims.UserId != testSubject.UserId || claims.Actor == nil || claims.Actor.UserId != adminID {
+		t.Fatalf("ValidateJWT() claims = %+v, want user %s acting as admin %s", claims, testSubject.UserId, adminID)
+	}
+	if claims.SessionId != "" || claims.MFA {
+		t.Errorf("impersonation token carries the user's session or MFA: %+v", claims)
+	}
+
+	// Токены имперсонации отзываются вместе с токенами администратора
+	if err := s.RevokeAll(adminID); err != nil {
+		t.Fatalf("RevokeAll() error = %v", err)
+	}
+	if _, err := s.ValidateJWT(token, "access"); err == nil {
+		t.Error("ValidateJWT() accepted an impersonation token after the actor's tokens were revoked")
+	}
+}
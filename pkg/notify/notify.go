@@ -0,0 +1,46 @@
+// Package notify предоставляет интерфейс для доставки уведомлений
+// пользователям (email, SMS) и простую реализацию для локальной разработки.
+//
+// Реальные провайдеры подключаются реализацией интерфейса Sender
+// без изменений в бизнес-логике.
+
+package notify
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+)
+
+// Message описывает одно уведомление. Для SMS поле Subject игнорируется.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender описывает канал доставки уведомлений.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogSender пишет уведомления в лог вместо реальной отправки.
+type LogSender struct {
+	log     zerolog.Logger
+	channel string
+}
+
+// NewLogSender создает отправителя, который пишет сообщения канала channel в лог.
+func NewLogSender(log zerolog.Logger, channel string) *LogSender {
+	return &LogSender{log: log, channel: channel}
+}
+
+// Send записывает сообщение в лог.
+func (s *LogSender) Send(_ context.Context, msg Message) error {
+	s.log.Info().
+		Str("channel", s.channel).
+		Str("to", msg.To).
+		Str("subject", msg.Subject).
+		Msg(msg.Body)
+	return nil
+}
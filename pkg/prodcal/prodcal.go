@@ -0,0 +1,110 @@
+// Package prodcal разбирает производственный календарь в формате открытых
+// данных data.gov.ru (набор 7708660670-proizvcalendar).
+//
+// Файл — CSV, строка на год: первая колонка — год, следующие двенадцать —
+// месяцы. В ячейке месяца через запятую перечислены нерабочие дни, включая
+// обычные выходные. Суффикс «*» помечает сокращенный предпраздничный рабочий
+// день, «+» — перенесенный выходной.
+package prodcal
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Kind string
+
+const (
+	// Holiday — нерабочий будний день.
+	Holiday Kind = "holiday"
+	// ShortDay — рабочий день, сокращенный на час.
+	ShortDay Kind = "short_day"
+	// WorkingDay — суббота или воскресенье, ставшие рабочими из-за переноса.
+	WorkingDay Kind = "working_day"
+)
+
+// Day — отступление от пятидневной недели.
+type Day struct {
+	Date time.Time
+	Kind Kind
+}
+
+// ParseCSV возвращает отступления от пятидневной недели за год. Обычные
+// выходные в результат не попадают.
+func ParseCSV(r io.Reader, year int) ([]Day, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("year %d not found", year)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv: %w", err)
+		}
+
+		recordYear, err := strconv.Atoi(strings.TrimSpace(record[0]))
+		if err != nil || recordYear != year {
+			// Заголовок или другой год
+			continue
+		}
+
+		if len(record) < 13 {
+			return nil, fmt.Errorf("year %d: expected 12 months, got %d columns", year, len(record)-1)
+		}
+
+		return parseYear(year, record[1:13])
+	}
+}
+
+func parseYear(year int, months []string) ([]Day, error) {
+	var days []Day
+	for i, cell := range months {
+		month := time.Month(i + 1)
+
+		nonWorking := make(map[int]bool)
+		short := make(map[int]bool)
+		for _, item := range strings.Split(cell, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+
+			number, err := strconv.Atoi(strings.TrimRight(item, "*+"))
+			if err != nil {
+				return nil, fmt.Errorf("%s %d: invalid day %q", month, year, item)
+			}
+
+			date := time.Date(year, month, number, 0, 0, 0, 0, time.UTC)
+			if date.Month() != month {
+				return nil, fmt.Errorf("%s %d: invalid day %q", month, year, item)
+			}
+
+			if strings.HasSuffix(item, "*") {
+				short[number] = true
+			} else {
+				nonWorking[number] = true
+			}
+		}
+
+		for date := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC); date.Month() == month; date = date.AddDate(0, 0, 1) {
+			weekend := date.Weekday() == time.Saturday || date.Weekday() == time.Sunday
+			switch {
+			case short[date.Day()]:
+				days = append(days, Day{Date: date, Kind: ShortDay})
+			case nonWorking[date.Day()] && !weekend:
+				days = append(days, Day{Date: date, Kind: Holiday})
+			case !nonWorking[date.Day()] && weekend:
+				days = append(days, Day{Date: date, Kind: WorkingDay})
+			}
+		}
+	}
+
+	return days, nil
+}
@@ -0,0 +1,100 @@
+package prodcal
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// weekendCell возвращает ячейку месяца с обычными выходными, кроме skip, и
+// дополнительными днями extra.
+func weekendCell(year int, month time.Month, skip map[int]bool, extra ...string) string {
+	var items []string
+	for date := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC); date.Month() == month; date = date.AddDate(0, 0, 1) {
+		weekend := date.Weekday() == time.Saturday || date.Weekday() == time.Sunday
+		if weekend && !skip[date.Day()] {
+			items = append(items, strconv.Itoa(date.Day()))
+		}
+	}
+	return `"` + strings.Join(append(items, extra...), ",") + `"`
+}
+
+func yearRow(year int, cells map[time.Month]string) string {
+	row := []string{strconv.Itoa(year)}
+	for month := time.January; month <= time.December; month++ {
+		cell, ok := cells[month]
+		if !ok {
+			cell = weekendCell(year, month, nil)
+		}
+		row = append(row, cell)
+	}
+	// Итоговые колонки файла не разбираются
+	return strings.Join(append(row, "247", "1970"), ",")
+}
+
+func date(month time.Month, day int) time.Time {
+	return time.Date(2026, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseCSV(t *testing.T) {
+	data := strings.Join([]string{
+		"Год/Месяц,Январь,Февраль,Март,Апрель,Май,Июнь,Июль,Август,Сентябрь,Октябрь,Ноябрь,Декабрь,Всего рабочих дней,Всего часов",
+		yearRow(2025, nil),
+		yearRow(2026, map[time.Month]string{
+			time.January:  weekendCell(2026, time.January, nil, "1", "2"),
+			time.February: weekendCell(2026, time.February, map[int]bool{21: true}, "20*", "23"),
+			time.November: weekendCell(2026, time.November, nil, "3*"),
+		}),
+	}, "\n")
+
+	days, err := ParseCSV(strings.NewReader(data), 2026)
+	if err != nil {
+		t.Fatalf("ParseCSV() error = %v", err)
+	}
+
+	want := []Day{
+		{Date: date(time.January, 1), Kind: Holiday},
+		{Date: date(time.January, 2), Kind: Holiday},
+		{Date: date(time.February, 20), Kind: ShortDay},
+		{Date: date(time.February, 21), Kind: WorkingDay},
+		{Date: date(time.February, 23), Kind: Holiday},
+		{Date: date(time.November, 3), Kind: ShortDay},
+	}
+	if !reflect.DeepEqual(days, want) {
+		t.Errorf("ParseCSV() = %v, want %v", days, want)
+	}
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{
+			name: "year not found",
+			data: yearRow(2025, nil),
+		},
+		{
+			name: "missing months",
+			data: "2026,\"1,2\",\"1\"",
+		},
+		{
+			name: "day out of month",
+			data: yearRow(2026, map[time.Month]string{time.February: weekendCell(2026, time.February, nil, "30")}),
+		},
+		{
+			name: "not a number",
+			data: yearRow(2026, map[time.Month]string{time.March: weekendCell(2026, time.March, nil, "8!")}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCSV(strings.NewReader(tt.data), 2026); err == nil {
+				t.Error("ParseCSV() error = nil, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sealedPrefix отличает зашифрованный секрет от записанного открытым
+// текстом до появления шифрования.
+const sealedPrefix = "v1:"
+
+// SecretCipher шифрует секреты для хранения в базе (AES-256-GCM), чтобы
+// утечка дампа не давала генерировать коды пользователей.
+type SecretCipher struct {
+	aead cipher.AEAD
+}
+
+// NewSecretCipher создает шифр из 32-байтного ключа в hex.
+func NewSecretCipher(hexKey string) (*SecretCipher, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, errors.New("secret key must be 32 bytes")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SecretCipher{aead: aead}, nil
+}
+
+// Seal шифрует секрет. additionalData привязывает шифртекст к владельцу:
+// скопированный в чужую строку секрет не расшифруется.
+func (c *SecretCipher) Seal(secret string, additionalData []byte) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(secret), additionalData)
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open расшифровывает секрет, записанный Seal. Значение без префикса
+// сохранено до включения шифрования и возвращается как есть.
+func (c *SecretCipher) Open(value string, additionalData []byte) (string, error) {
+	if !strings.HasPrefix(value, sealedPrefix) {
+		return value, nil
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, sealedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("invalid sealed secret: %w", err)
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return "", errors.New("invalid sealed secret")
+	}
+
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	secret, err := c.aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+
+	return string(secret), nil
+}
@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN DEFAULT False;
+
+UPDATE users SET is_admin = True WHERE role = 'admin';
+
+ALTER TABLE users DROP COLUMN role;
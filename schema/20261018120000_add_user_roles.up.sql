@@ -0,0 +1,8 @@
+-- Роли пользователей вместо флага is_admin
+ALTER TABLE users
+    ADD COLUMN role TEXT NOT NULL DEFAULT 'client'
+        CHECK (role IN ('client', 'receptionist', 'mechanic', 'manager', 'admin'));
+
+UPDATE users SET role = 'admin' WHERE is_admin;
+
+ALTER TABLE users DROP COLUMN is_admin;
@@ -0,0 +1,4 @@
+-- Подтверждение контактов пользователя
+ALTER TABLE users
+    ADD COLUMN email_verified_at TIMESTAMP,
+    ADD COLUMN phone_verified_at TIMESTAMP;
@@ -0,0 +1,15 @@
+-- Сессии пользователей (устройства, на которых выданы токены)
+CREATE TABLE sessions
+(
+    id           UUID PRIMARY KEY,
+    user_id      UUID      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    device_name  TEXT,
+    ip           TEXT,
+    user_agent   TEXT,
+    created_at   TIMESTAMP DEFAULT NOW(),
+    last_used_at TIMESTAMP DEFAULT NOW(),
+    expires_at   TIMESTAMP NOT NULL,
+    revoked_at   TIMESTAMP
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id) WHERE revoked_at IS NULL;
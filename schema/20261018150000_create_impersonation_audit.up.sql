@@ -0,0 +1,16 @@
+-- Журнал действий администраторов, выполненных от имени клиентов
+CREATE TABLE impersonation_audit
+(
+    id         UUID PRIMARY KEY,
+    actor_id   UUID NOT NULL REFERENCES users (id),
+    user_id    UUID NOT NULL REFERENCES users (id),
+    action     TEXT NOT NULL,
+    method     TEXT,
+    path       TEXT,
+    status     INT,
+    ip         TEXT,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX impersonation_audit_user_id_idx ON impersonation_audit (user_id, created_at DESC);
+CREATE INDEX impersonation_audit_actor_id_idx ON impersonation_audit (actor_id, created_at DESC);
@@ -0,0 +1,14 @@
+-- API-ключи партнеров и внутренних сервисов. Сам ключ не хранится, только его SHA-256
+CREATE TABLE api_keys
+(
+    id           UUID PRIMARY KEY,
+    name         TEXT   NOT NULL,
+    prefix       TEXT   NOT NULL,
+    key_hash     TEXT   NOT NULL UNIQUE,
+    permissions  TEXT[] NOT NULL DEFAULT '{}',
+    created_by   UUID REFERENCES users (id) ON DELETE SET NULL,
+    created_at   TIMESTAMP DEFAULT NOW(),
+    expires_at   TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at   TIMESTAMP
+);
@@ -0,0 +1,17 @@
+-- TOTP: секрет, момент включения и последний принятый шаг (защита от повторного использования кода)
+ALTER TABLE users
+    ADD COLUMN totp_secret     TEXT,
+    ADD COLUMN totp_enabled_at TIMESTAMP,
+    ADD COLUMN totp_last_step  BIGINT NOT NULL DEFAULT 0;
+
+-- Одноразовые коды восстановления, хранятся только их хеши
+CREATE TABLE totp_recovery_codes
+(
+    id         UUID PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash  TEXT NOT NULL,
+    used_at    TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX totp_recovery_codes_user_id_idx ON totp_recovery_codes (user_id);
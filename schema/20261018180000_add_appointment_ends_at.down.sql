@@ -0,0 +1,4 @@
+ALTER TABLE appointments
+    DROP CONSTRAINT IF EXISTS appointments_no_overlap,
+    DROP CONSTRAINT IF EXISTS appointments_ends_after_start,
+    DROP COLUMN IF EXISTS ends_at;
@@ -0,0 +1,25 @@
+-- Запись занимает интервал [appointment_time, ends_at), длительность — сумма duration_min услуг
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+ALTER TABLE appointments
+    ADD COLUMN ends_at TIMESTAMP;
+
+UPDATE appointments a
+SET ends_at = a.appointment_time + GREATEST(COALESCE((
+    SELECT SUM(s.duration_min)
+    FROM appointment_services as_link
+    JOIN services s ON s.id = as_link.service_id
+    WHERE as_link.appointment_id = a.id
+), 0), 1) * INTERVAL '1 minute';
+
+ALTER TABLE appointments
+    ALTER COLUMN ends_at SET NOT NULL,
+    ADD CONSTRAINT appointments_ends_after_start CHECK (ends_at > appointment_time);
+
+-- Пересекающиеся активные записи запрещены на уровне БД, поэтому параллельные
+-- запросы не могут занять одно и то же время. Существующие пересечения нужно
+-- разрешить до применения миграции.
+ALTER TABLE appointments
+    ADD CONSTRAINT appointments_no_overlap
+        EXCLUDE USING gist (tsrange(appointment_time, ends_at) WITH &&)
+        WHERE (deleted_at IS NULL AND status <> 'cancelled');
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS appointment_resources;
+DROP TABLE IF EXISTS service_resource_types;
+DROP TABLE IF EXISTS resources;
+
+-- Параллельные записи, созданные после миграции, нужно разрешить до отката
+ALTER TABLE appointments
+    ADD CONSTRAINT appointments_no_overlap
+        EXCLUDE USING gist (tsrange(appointment_time, ends_at) WITH &&)
+        WHERE (deleted_at IS NULL AND status <> 'cancelled');
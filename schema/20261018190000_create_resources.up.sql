@@ -0,0 +1,59 @@
+-- Посты и оборудование мастерской: подъемники, стенд сход-развала, покрасочная камера.
+-- capacity — сколько машин ресурс принимает одновременно
+CREATE TABLE resources
+(
+    id         UUID PRIMARY KEY,
+    name       TEXT NOT NULL,
+    type       TEXT NOT NULL,
+    capacity   INT  NOT NULL DEFAULT 1 CHECK (capacity > 0),
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX idx_resources_type ON resources (type) WHERE deleted_at IS NULL;
+
+-- Типы ресурсов, которые нужны услуге. Запись занимает по одному ресурсу каждого типа
+-- на все свое время; услуга без типов ресурсов не занимает ничего
+CREATE TABLE service_resource_types
+(
+    service_id    UUID NOT NULL REFERENCES services (id) ON DELETE CASCADE,
+    resource_type TEXT NOT NULL,
+    PRIMARY KEY (service_id, resource_type)
+);
+
+-- Назначенные записи ресурсы. unit — номер места в пределах capacity ресурса,
+-- интервал повторяет время записи, active снимается при отмене и удалении
+CREATE TABLE appointment_resources
+(
+    appointment_id UUID      NOT NULL REFERENCES appointments (id) ON DELETE CASCADE,
+    resource_id    UUID      NOT NULL REFERENCES resources (id),
+    unit           INT       NOT NULL CHECK (unit > 0),
+    starts_at      TIMESTAMP NOT NULL,
+    ends_at        TIMESTAMP NOT NULL,
+    active         BOOLEAN   NOT NULL DEFAULT TRUE,
+    PRIMARY KEY (appointment_id, resource_id),
+    -- Одно место ресурса не может быть занято двумя записями одновременно
+    CONSTRAINT appointment_resources_no_overlap
+        EXCLUDE USING gist (resource_id WITH =, unit WITH =, tsrange(starts_at, ends_at) WITH &&)
+        WHERE (active)
+);
+
+-- До этой миграции сервис принимал одну машину за раз. Чтобы поведение не изменилось,
+-- все услуги получают тип lift, а существующие записи — единственный подъемник
+INSERT INTO resources (id, name, type, capacity)
+VALUES (gen_random_uuid(), 'Подъемник 1', 'lift', 1);
+
+INSERT INTO service_resource_types (service_id, resource_type)
+SELECT id, 'lift'
+FROM services
+WHERE deleted_at IS NULL;
+
+INSERT INTO appointment_resources (appointment_id, resource_id, unit, starts_at, ends_at, active)
+SELECT a.id, r.id, 1, a.appointment_time, a.ends_at, a.deleted_at IS NULL AND a.status <> 'cancelled'
+FROM appointments a
+CROSS JOIN resources r;
+
+-- Пересечения теперь проверяются по каждому ресурсу отдельно
+ALTER TABLE appointments
+    DROP CONSTRAINT appointments_no_overlap;
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS appointment_mechanics;
+DROP TABLE IF EXISTS staff_schedule_exceptions;
+DROP TABLE IF EXISTS staff_working_hours;
+DROP TABLE IF EXISTS staff_profiles;
+
+ALTER TABLE services
+    DROP COLUMN IF EXISTS category;
@@ -0,0 +1,69 @@
+-- Категория услуги, по ней подбираются механики с нужными навыками.
+-- Услугу без категории может выполнить любой механик
+ALTER TABLE services
+    ADD COLUMN category TEXT;
+
+-- Профиль сотрудника, который выполняет работы. skills — категории услуг
+CREATE TABLE staff_profiles
+(
+    user_id    UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    skills     TEXT[]  NOT NULL DEFAULT '{}',
+    active     BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Недельный график: одна смена на день недели, weekday как в Go (0 — воскресенье)
+CREATE TABLE staff_working_hours
+(
+    user_id    UUID     NOT NULL REFERENCES staff_profiles (user_id) ON DELETE CASCADE,
+    weekday    SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    start_time TIME     NOT NULL,
+    end_time   TIME     NOT NULL,
+    PRIMARY KEY (user_id, weekday),
+    CHECK (end_time > start_time)
+);
+
+-- Отсутствия поверх графика, даты включительно
+CREATE TABLE staff_schedule_exceptions
+(
+    id         UUID PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES staff_profiles (user_id) ON DELETE CASCADE,
+    kind       TEXT NOT NULL CHECK (kind IN ('vacation', 'sick_leave', 'day_off')),
+    starts_on  DATE NOT NULL,
+    ends_on    DATE NOT NULL,
+    note       TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    CHECK (ends_on >= starts_on)
+);
+
+CREATE INDEX idx_staff_schedule_exceptions_user_id ON staff_schedule_exceptions (user_id, starts_on);
+
+-- Механики, назначенные на запись. Интервал повторяет время записи,
+-- active снимается при отмене и удалении
+CREATE TABLE appointment_mechanics
+(
+    appointment_id UUID      NOT NULL REFERENCES appointments (id) ON DELETE CASCADE,
+    user_id        UUID      NOT NULL REFERENCES users (id),
+    starts_at      TIMESTAMP NOT NULL,
+    ends_at        TIMESTAMP NOT NULL,
+    active         BOOLEAN   NOT NULL DEFAULT TRUE,
+    PRIMARY KEY (appointment_id, user_id),
+    -- Механик не может работать над двумя записями одновременно
+    CONSTRAINT appointment_mechanics_no_overlap
+        EXCLUDE USING gist (user_id WITH =, tsrange(starts_at, ends_at) WITH &&)
+        WHERE (active)
+);
+
+CREATE INDEX idx_appointment_mechanics_user_id ON appointment_mechanics (user_id, starts_at);
+
+-- Существующие механики получают профиль и график пн–пт с 9 до 18
+INSERT INTO staff_profiles (user_id)
+SELECT id
+FROM users
+WHERE role = 'mechanic' AND deleted_at IS NULL;
+
+INSERT INTO staff_working_hours (user_id, weekday, start_time, end_time)
+SELECT p.user_id, d.weekday, '09:00', '18:00'
+FROM staff_profiles p
+CROSS JOIN generate_series(1, 5) AS d(weekday);
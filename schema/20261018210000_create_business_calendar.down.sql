@@ -0,0 +1,26 @@
+ALTER TABLE appointment_resources
+    DROP CONSTRAINT appointment_resources_no_overlap;
+ALTER TABLE appointment_mechanics
+    DROP CONSTRAINT appointment_mechanics_no_overlap;
+
+ALTER TABLE appointments
+    ALTER COLUMN appointment_time TYPE TIMESTAMP USING appointment_time AT TIME ZONE 'Europe/Moscow',
+    ALTER COLUMN ends_at TYPE TIMESTAMP USING ends_at AT TIME ZONE 'Europe/Moscow';
+
+ALTER TABLE appointment_resources
+    ALTER COLUMN starts_at TYPE TIMESTAMP USING starts_at AT TIME ZONE 'Europe/Moscow',
+    ALTER COLUMN ends_at TYPE TIMESTAMP USING ends_at AT TIME ZONE 'Europe/Moscow',
+    ADD CONSTRAINT appointment_resources_no_overlap
+        EXCLUDE USING gist (resource_id WITH =, unit WITH =, tsrange(starts_at, ends_at) WITH &&)
+        WHERE (active);
+
+ALTER TABLE appointment_mechanics
+    ALTER COLUMN starts_at TYPE TIMESTAMP USING starts_at AT TIME ZONE 'Europe/Moscow',
+    ALTER COLUMN ends_at TYPE TIMESTAMP USING ends_at AT TIME ZONE 'Europe/Moscow',
+    ADD CONSTRAINT appointment_mechanics_no_overlap
+        EXCLUDE USING gist (user_id WITH =, tsrange(starts_at, ends_at) WITH &&)
+        WHERE (active);
+
+DROP TABLE IF EXISTS calendar_days;
+DROP TABLE IF EXISTS business_hours;
+DROP TABLE IF EXISTS workshop_settings;
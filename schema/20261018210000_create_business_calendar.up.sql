@@ -0,0 +1,68 @@
+-- Настройки мастерской. Таблица всегда содержит ровно одну строку
+CREATE TABLE workshop_settings
+(
+    id         BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    time_zone  TEXT NOT NULL,
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+INSERT INTO workshop_settings (time_zone)
+VALUES ('Europe/Moscow');
+
+-- Часы работы по дням недели, weekday как в Go (0 — воскресенье).
+-- Дня нет в таблице — мастерская в этот день не работает
+CREATE TABLE business_hours
+(
+    weekday     SMALLINT PRIMARY KEY CHECK (weekday BETWEEN 0 AND 6),
+    open_time   TIME NOT NULL,
+    close_time  TIME NOT NULL,
+    break_start TIME,
+    break_end   TIME,
+    CHECK (close_time > open_time),
+    CHECK ((break_start IS NULL) = (break_end IS NULL)),
+    CHECK (break_end > break_start AND break_start > open_time AND break_end < close_time)
+);
+
+-- Прежние константы: будни с 9 до 18 без перерыва
+INSERT INTO business_hours (weekday, open_time, close_time)
+SELECT d, '09:00', '18:00'
+FROM generate_series(1, 5) AS d;
+
+-- Отступления от недельного графика: праздники, сокращенные дни и рабочие выходные.
+-- source = import у дней из производственного календаря, ручные правки его не теряют
+CREATE TABLE calendar_days
+(
+    date       DATE PRIMARY KEY,
+    kind       TEXT NOT NULL CHECK (kind IN ('holiday', 'short_day', 'working_day')),
+    open_time  TIME,
+    close_time TIME,
+    name       TEXT,
+    source     TEXT NOT NULL DEFAULT 'manual' CHECK (source IN ('manual', 'import')),
+    created_at TIMESTAMP DEFAULT NOW(),
+    CHECK (close_time IS NULL OR open_time IS NULL OR close_time > open_time)
+);
+
+-- Время записей хранится с часовым поясом. Старые значения записаны по местному
+-- времени мастерской; если она не в Europe/Moscow, поправьте пояс ниже перед применением
+ALTER TABLE appointment_resources
+    DROP CONSTRAINT appointment_resources_no_overlap;
+ALTER TABLE appointment_mechanics
+    DROP CONSTRAINT appointment_mechanics_no_overlap;
+
+ALTER TABLE appointments
+    ALTER COLUMN appointment_time TYPE TIMESTAMPTZ USING appointment_time AT TIME ZONE 'Europe/Moscow',
+    ALTER COLUMN ends_at TYPE TIMESTAMPTZ USING ends_at AT TIME ZONE 'Europe/Moscow';
+
+ALTER TABLE appointment_resources
+    ALTER COLUMN starts_at TYPE TIMESTAMPTZ USING starts_at AT TIME ZONE 'Europe/Moscow',
+    ALTER COLUMN ends_at TYPE TIMESTAMPTZ USING ends_at AT TIME ZONE 'Europe/Moscow',
+    ADD CONSTRAINT appointment_resources_no_overlap
+        EXCLUDE USING gist (resource_id WITH =, unit WITH =, tstzrange(starts_at, ends_at) WITH &&)
+        WHERE (active);
+
+ALTER TABLE appointment_mechanics
+    ALTER COLUMN starts_at TYPE TIMESTAMPTZ USING starts_at AT TIME ZONE 'Europe/Moscow',
+    ALTER COLUMN ends_at TYPE TIMESTAMPTZ USING ends_at AT TIME ZONE 'Europe/Moscow',
+    ADD CONSTRAINT appointment_mechanics_no_overlap
+        EXCLUDE USING gist (user_id WITH =, tstzrange(starts_at, ends_at) WITH &&)
+        WHERE (active);
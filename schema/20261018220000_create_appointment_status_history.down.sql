@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS appointment_status_history;
+
+-- Новые статусы сводятся к ближайшим из прежнего набора
+UPDATE appointments SET status = 'scheduled' WHERE status = 'checked_in';
+UPDATE appointments SET status = 'in_progress' WHERE status IN ('awaiting_parts', 'ready');
+UPDATE appointments SET status = 'cancelled' WHERE status = 'no_show';
+
+ALTER TABLE appointments
+    DROP CONSTRAINT IF EXISTS appointments_status_check,
+    ADD CONSTRAINT appointments_status_check
+        CHECK (status IN ('scheduled', 'in_progress', 'completed', 'cancelled'));
@@ -0,0 +1,22 @@
+-- Полный набор статусов записи. Переходы между ними проверяет сервис
+ALTER TABLE appointments
+    DROP CONSTRAINT IF EXISTS appointments_status_check,
+    ADD CONSTRAINT appointments_status_check
+        CHECK (status IN ('scheduled', 'checked_in', 'in_progress', 'awaiting_parts', 'ready',
+                          'completed', 'cancelled', 'no_show'));
+
+-- История статусов записи. Автор — пользователь или API-ключ,
+-- from_status пуст у записи о создании
+CREATE TABLE appointment_status_history
+(
+    id             UUID PRIMARY KEY,
+    appointment_id UUID NOT NULL REFERENCES appointments (id) ON DELETE CASCADE,
+    from_status    TEXT,
+    to_status      TEXT NOT NULL,
+    changed_by     UUID REFERENCES users (id),
+    api_key_id     UUID REFERENCES api_keys (id),
+    reason         TEXT,
+    created_at     TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX appointment_status_history_appointment_id_idx ON appointment_status_history (appointment_id, created_at);
@@ -0,0 +1,14 @@
+-- История переносов записи: прежнее и новое время, автор и причина
+CREATE TABLE appointment_time_history
+(
+    id             UUID PRIMARY KEY,
+    appointment_id UUID        NOT NULL REFERENCES appointments (id) ON DELETE CASCADE,
+    from_time      TIMESTAMPTZ NOT NULL,
+    to_time        TIMESTAMPTZ NOT NULL,
+    changed_by     UUID REFERENCES users (id),
+    api_key_id     UUID REFERENCES api_keys (id),
+    reason         TEXT,
+    created_at     TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX appointment_time_history_appointment_id_idx ON appointment_time_history (appointment_id, created_at);
@@ -0,0 +1,17 @@
+DROP TABLE IF EXISTS waitlist_offers;
+DROP TABLE IF EXISTS waitlist_entries;
+
+-- Неподтвержденные резервы отменяются вместе с листом ожидания
+UPDATE appointment_resources
+SET active = FALSE
+WHERE appointment_id IN (SELECT id FROM appointments WHERE status = 'reserved');
+UPDATE appointment_mechanics
+SET active = FALSE
+WHERE appointment_id IN (SELECT id FROM appointments WHERE status = 'reserved');
+UPDATE appointments SET status = 'cancelled' WHERE status = 'reserved';
+
+ALTER TABLE appointments
+    DROP CONSTRAINT IF EXISTS appointments_status_check,
+    ADD CONSTRAINT appointments_status_check
+        CHECK (status IN ('scheduled', 'checked_in', 'in_progress', 'awaiting_parts', 'ready',
+                          'completed', 'cancelled', 'no_show'));
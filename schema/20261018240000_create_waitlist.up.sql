@@ -0,0 +1,44 @@
+-- reserved: время удержано под предложение из листа ожидания
+ALTER TABLE appointments
+    DROP CONSTRAINT IF EXISTS appointments_status_check,
+    ADD CONSTRAINT appointments_status_check
+        CHECK (status IN ('reserved', 'scheduled', 'checked_in', 'in_progress', 'awaiting_parts', 'ready',
+                          'completed', 'cancelled', 'no_show'));
+
+-- Заявки клиентов на случай, если на нужные даты освободится время
+CREATE TABLE waitlist_entries
+(
+    id          UUID PRIMARY KEY,
+    user_id     UUID   NOT NULL REFERENCES users (id),
+    vehicle_id  UUID   NOT NULL REFERENCES vehicles (id),
+    service_ids UUID[] NOT NULL,
+    date_from   DATE   NOT NULL,
+    date_to     DATE   NOT NULL CHECK (date_to >= date_from),
+    status      TEXT   NOT NULL DEFAULT 'waiting'
+        CHECK (status IN ('waiting', 'offered', 'booked', 'expired', 'cancelled')),
+    created_at  TIMESTAMPTZ DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX waitlist_entries_waiting_idx ON waitlist_entries (date_from, date_to, created_at)
+    WHERE status = 'waiting';
+CREATE INDEX waitlist_entries_user_id_idx ON waitlist_entries (user_id, created_at DESC);
+
+-- Предложения освободившегося времени. Запись в статусе reserved держит
+-- посты и механиков, пока клиент не ответит или не истечет expires_at
+CREATE TABLE waitlist_offers
+(
+    id               UUID PRIMARY KEY,
+    entry_id         UUID        NOT NULL REFERENCES waitlist_entries (id),
+    appointment_id   UUID        NOT NULL REFERENCES appointments (id),
+    appointment_time TIMESTAMPTZ NOT NULL,
+    status           TEXT        NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'accepted', 'declined', 'expired')),
+    expires_at       TIMESTAMPTZ NOT NULL,
+    created_at       TIMESTAMPTZ DEFAULT NOW(),
+    resolved_at      TIMESTAMPTZ
+);
+
+CREATE INDEX waitlist_offers_pending_idx ON waitlist_offers (expires_at) WHERE status = 'pending';
+CREATE UNIQUE INDEX waitlist_offers_pending_entry_idx ON waitlist_offers (entry_id) WHERE status = 'pending';
+CREATE INDEX waitlist_offers_appointment_id_idx ON waitlist_offers (appointment_id);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS appointments_scheduled_time_idx;
+
+ALTER TABLE appointments
+    DROP COLUMN IF EXISTS prepaid_at,
+    DROP COLUMN IF EXISTS prepayment_required;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS no_show_count;
@@ -0,0 +1,25 @@
+-- Сколько раз клиент не пришел на запись. Сбрасывается сотрудником,
+-- когда он снимает ограничения на запись
+ALTER TABLE users
+    ADD COLUMN no_show_count INT NOT NULL DEFAULT 0;
+
+-- Запись клиента, который уже не приходил, может требовать предоплату
+ALTER TABLE appointments
+    ADD COLUMN prepayment_required BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN prepaid_at          TIMESTAMPTZ;
+
+-- Прошедшие записи, которые до сих пор оставались в scheduled, закрываются
+-- как выполненные: иначе первый запуск отметки неявок засчитал бы их клиентам
+WITH closed AS (
+    UPDATE appointments
+    SET status = 'completed', updated_at = NOW()
+    WHERE status = 'scheduled' AND appointment_time < NOW() AND deleted_at IS NULL
+    RETURNING id
+)
+INSERT INTO appointment_status_history (id, appointment_id, from_status, to_status, reason)
+SELECT gen_random_uuid(), id, 'scheduled', 'completed', 'closed when no-show tracking was introduced'
+FROM closed;
+
+-- Поиск просроченных записей для отметки неявок
+CREATE INDEX appointments_scheduled_time_idx ON appointments (appointment_time)
+    WHERE status = 'scheduled' AND deleted_at IS NULL;
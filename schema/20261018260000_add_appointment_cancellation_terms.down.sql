@@ -0,0 +1,3 @@
+ALTER TABLE appointments
+    DROP COLUMN IF EXISTS cancellation_fee,
+    DROP COLUMN IF EXISTS cancellation_terms;
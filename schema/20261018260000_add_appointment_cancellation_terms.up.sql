@@ -0,0 +1,6 @@
+-- Условия, на которых отменена запись: бесплатно, с платой за позднюю
+-- отмену или с решением сотрудника вопреки правилам
+ALTER TABLE appointments
+    ADD COLUMN cancellation_terms TEXT
+        CHECK (cancellation_terms IN ('free', 'late', 'overridden')),
+    ADD COLUMN cancellation_fee   NUMERIC(10, 2) CHECK (cancellation_fee >= 0);
@@ -0,0 +1,40 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS location_id;
+
+ALTER TABLE waitlist_entries
+    DROP COLUMN IF EXISTS location_id;
+
+DROP INDEX IF EXISTS appointments_location_id_idx;
+ALTER TABLE appointments
+    DROP COLUMN IF EXISTS location_id;
+
+DROP INDEX IF EXISTS services_location_id_idx;
+ALTER TABLE services
+    DROP COLUMN IF EXISTS location_id;
+
+DROP INDEX IF EXISTS resources_location_id_idx;
+ALTER TABLE resources
+    DROP COLUMN IF EXISTS location_id;
+
+-- Остаются часы работы и часовой пояс первого филиала
+CREATE TABLE workshop_settings
+(
+    id         BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    time_zone  TEXT NOT NULL,
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+INSERT INTO workshop_settings (time_zone)
+SELECT time_zone
+FROM locations
+ORDER BY created_at
+LIMIT 1;
+
+DELETE FROM business_hours
+WHERE location_id <> (SELECT id FROM locations ORDER BY created_at LIMIT 1);
+ALTER TABLE business_hours
+    DROP CONSTRAINT business_hours_pkey,
+    DROP COLUMN location_id,
+    ADD PRIMARY KEY (weekday);
+
+DROP TABLE IF EXISTS locations;
@@ -0,0 +1,64 @@
+-- Филиалы мастерской. Часовой пояс переезжает сюда из workshop_settings
+CREATE TABLE locations
+(
+    id         UUID PRIMARY KEY,
+    name       TEXT NOT NULL,
+    address    TEXT NOT NULL,
+    time_zone  TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    deleted_at TIMESTAMPTZ
+);
+
+-- Все, что было до филиалов, относится к единственной мастерской.
+-- Адрес заполните после применения
+INSERT INTO locations (id, name, address, time_zone)
+SELECT gen_random_uuid(), 'Основной филиал', '', time_zone
+FROM workshop_settings;
+
+DROP TABLE workshop_settings;
+
+-- Часы работы у каждого филиала свои, праздничный календарь общий
+ALTER TABLE business_hours
+    ADD COLUMN location_id UUID REFERENCES locations (id);
+UPDATE business_hours SET location_id = (SELECT id FROM locations);
+ALTER TABLE business_hours
+    ALTER COLUMN location_id SET NOT NULL,
+    DROP CONSTRAINT business_hours_pkey,
+    ADD PRIMARY KEY (location_id, weekday);
+
+-- Посты, услуги с их ценами, записи и лист ожидания принадлежат филиалу
+ALTER TABLE resources
+    ADD COLUMN location_id UUID REFERENCES locations (id);
+UPDATE resources SET location_id = (SELECT id FROM locations);
+ALTER TABLE resources
+    ALTER COLUMN location_id SET NOT NULL;
+CREATE INDEX resources_location_id_idx ON resources (location_id, type) WHERE deleted_at IS NULL;
+
+ALTER TABLE services
+    ADD COLUMN location_id UUID REFERENCES locations (id);
+UPDATE services SET location_id = (SELECT id FROM locations);
+ALTER TABLE services
+    ALTER COLUMN location_id SET NOT NULL;
+CREATE INDEX services_location_id_idx ON services (location_id) WHERE deleted_at IS NULL;
+
+ALTER TABLE appointments
+    ADD COLUMN location_id UUID REFERENCES locations (id);
+UPDATE appointments SET location_id = (SELECT id FROM locations);
+ALTER TABLE appointments
+    ALTER COLUMN location_id SET NOT NULL;
+CREATE INDEX appointments_location_id_idx ON appointments (location_id, appointment_time);
+
+ALTER TABLE waitlist_entries
+    ADD COLUMN location_id UUID REFERENCES locations (id);
+UPDATE waitlist_entries SET location_id = (SELECT id FROM locations);
+ALTER TABLE waitlist_entries
+    ALTER COLUMN location_id SET NOT NULL;
+
+-- Филиал сотрудника: там он работает и только там действуют его права.
+-- NULL — права во всех филиалах, как у администратора
+ALTER TABLE users
+    ADD COLUMN location_id UUID REFERENCES locations (id);
+UPDATE users
+SET location_id = (SELECT id FROM locations)
+WHERE role NOT IN ('client', 'admin');
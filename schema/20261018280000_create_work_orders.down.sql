@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS work_order_lines;
+DROP TABLE IF EXISTS work_orders;
@@ -0,0 +1,33 @@
+-- Заказ-наряд записи. Закрытый заказ-наряд больше не меняется
+CREATE TABLE work_orders
+(
+    id             UUID PRIMARY KEY,
+    appointment_id UUID NOT NULL UNIQUE REFERENCES appointments (id),
+    status         TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'closed')),
+    closed_at      TIMESTAMPTZ,
+    closed_by      UUID REFERENCES users (id),
+    created_at     TIMESTAMPTZ DEFAULT NOW(),
+    updated_at     TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Строки заказа-наряда: работы (нормо-часы × ставка), запчасти и расходники
+-- (количество × цена за единицу). quantity — оценка, actual_quantity — факт,
+-- пока он не внесен, в фактическую сумму идет оценка. Работы по услугам записи
+-- создаются из снимка цены appointment_services.price
+CREATE TABLE work_order_lines
+(
+    id                     UUID PRIMARY KEY,
+    work_order_id          UUID           NOT NULL REFERENCES work_orders (id) ON DELETE CASCADE,
+    kind                   TEXT           NOT NULL CHECK (kind IN ('labor', 'part', 'consumable')),
+    appointment_service_id UUID REFERENCES appointment_services (id) ON DELETE SET NULL,
+    description            TEXT           NOT NULL,
+    part_number            TEXT,
+    quantity               NUMERIC(10, 2) NOT NULL CHECK (quantity >= 0),
+    actual_quantity        NUMERIC(10, 2) CHECK (actual_quantity >= 0),
+    unit_price             NUMERIC(10, 2) NOT NULL CHECK (unit_price >= 0),
+    discount_percent       NUMERIC(5, 2)  NOT NULL DEFAULT 0 CHECK (discount_percent BETWEEN 0 AND 100),
+    created_at             TIMESTAMPTZ DEFAULT NOW(),
+    updated_at             TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX work_order_lines_work_order_id_idx ON work_order_lines (work_order_id, created_at);